@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/auth"
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/password"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+const adminUsage = `usage: chirpy admin [-output table|json] <command>
+
+commands:
+  users create -email <email> -password <password> [-red]
+  users suspend <user>
+  users unsuspend <user>
+  users delete <user>
+  users undelete <user>
+  users grant-red <user>
+  users grant-moderator <user>
+  users revoke-moderator <user>
+  tokens revoke <user>
+  chirps purge (-user <user> | -all)
+  keys rotate
+  stats
+
+<user> is either the user ID or the email`
+
+// adminCLI runs administrative commands against the store of the
+// configured backend
+type adminCLI struct {
+	store     store.Store
+	hasher    *auth.Hasher
+	passwords password.Policy
+	out       io.Writer
+	output    string
+}
+
+// runAdmin implements `chirpy admin`, the operator tool that replaces
+// raw SQL and unauthenticated endpoints for administrative tasks
+func runAdmin(ctx context.Context, cfg config.Config, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output %q, expected table or json", *output)
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(adminUsage)
+	}
+
+	passwords, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	cli := adminCLI{
+		store:     newStore(cfg.DatabaseBackend, db),
+		hasher:    newHasher(cfg.Password),
+		passwords: passwords,
+		out:       out,
+		output:    *output,
+	}
+
+	result, err := cli.run(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	return cli.print(result)
+}
+
+func (cli adminCLI) run(ctx context.Context, args []string) (any, error) {
+	command := strings.Join(args[:min(2, len(args))], " ")
+
+	switch command {
+	case "users create":
+		return cli.createUser(ctx, args[2:])
+	case "users suspend":
+		return cli.withUser(ctx, args[2:], cli.suspendUser)
+	case "users unsuspend":
+		return cli.withUser(ctx, args[2:], cli.unsuspendUser)
+	case "users delete":
+		return cli.withUser(ctx, args[2:], cli.deleteUser)
+	case "users undelete":
+		return cli.withUser(ctx, args[2:], cli.undeleteUser)
+	case "users grant-red":
+		return cli.withUser(ctx, args[2:], cli.grantRed)
+	case "users grant-moderator":
+		return cli.withUser(ctx, args[2:], cli.setModerator(true))
+	case "users revoke-moderator":
+		return cli.withUser(ctx, args[2:], cli.setModerator(false))
+	case "tokens revoke":
+		return cli.withUser(ctx, args[2:], cli.revokeTokens)
+	case "chirps purge":
+		return cli.purgeChirps(ctx, args[2:])
+	case "keys rotate":
+		return cli.rotateKey(ctx)
+	}
+
+	if args[0] == "stats" {
+		return cli.stats(ctx)
+	}
+
+	return nil, fmt.Errorf("unknown command %q\n\n%v", strings.Join(args, " "), adminUsage)
+}
+
+type adminUser struct {
+	ID          uuid.UUID  `json:"id"`
+	Email       string     `json:"email"`
+	IsChirpyRed bool       `json:"is_chirpy_red"`
+	IsModerator bool       `json:"is_moderator"`
+	Suspended   bool       `json:"suspended"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+func newAdminUser(user store.User) adminUser {
+	return adminUser{
+		ID:          user.ID,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		IsModerator: user.IsModerator,
+		Suspended:   user.Suspended(),
+		CreatedAt:   user.CreatedAt,
+		DeletedAt:   user.DeletedAt,
+	}
+}
+
+func (cli adminCLI) createUser(ctx context.Context, args []string) (any, error) {
+	flags := flag.NewFlagSet("users create", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the new user")
+	plain := flags.String("password", "", "password of the new user")
+	red := flags.Bool("red", false, "grant Chirpy Red to the new user")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *email == "" || *plain == "" {
+		return nil, errors.New("users create: -email and -password are required")
+	}
+
+	if err := cli.passwords.Check(ctx, *plain); err != nil {
+		return nil, fmt.Errorf("users create: %w", err)
+	}
+
+	hashedPassword, err := cli.hasher.Hash(ctx, *plain)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	var user store.User
+	err = cli.store.InTx(ctx, func(tx store.Store) error {
+		user, err = tx.CreateUser(ctx, *email, hashedPassword)
+		if err != nil {
+			return fmt.Errorf("error creating user: %w", err)
+		}
+
+		if *red {
+			if err := tx.UpgradeUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("error granting Chirpy Red: %w", err)
+			}
+			user.IsChirpyRed = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newAdminUser(user), nil
+}
+
+// withUser resolves the single <user> argument before running fn
+func (cli adminCLI) withUser(ctx context.Context, args []string, fn func(context.Context, store.User) (any, error)) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected exactly one <user>, either an ID or an email")
+	}
+
+	user, err := cli.findUser(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(ctx, user)
+}
+
+func (cli adminCLI) findUser(ctx context.Context, ref string) (store.User, error) {
+	var user store.User
+	var err error
+
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = cli.store.GetUserByID(ctx, id)
+	} else {
+		user, err = cli.store.GetUserByEmail(ctx, ref)
+	}
+	if err != nil {
+		return store.User{}, fmt.Errorf("user %q not found: %w", ref, err)
+	}
+
+	return user, nil
+}
+
+// suspendUser blocks new logins and revokes every refresh token, the
+// current access tokens expire within accessTokenLifetime
+func (cli adminCLI) suspendUser(ctx context.Context, user store.User) (any, error) {
+	err := cli.store.InTx(ctx, func(tx store.Store) error {
+		if err := tx.SuspendUser(ctx, user.ID); err != nil {
+			return fmt.Errorf("error suspending user: %w", err)
+		}
+
+		if _, err := tx.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+			return fmt.Errorf("error revoking refresh tokens: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	suspendedAt := time.Now()
+	user.SuspendedAt = &suspendedAt
+	return newAdminUser(user), nil
+}
+
+func (cli adminCLI) unsuspendUser(ctx context.Context, user store.User) (any, error) {
+	if err := cli.store.UnsuspendUser(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("error unsuspending user: %w", err)
+	}
+
+	user.SuspendedAt = nil
+	return newAdminUser(user), nil
+}
+
+func (cli adminCLI) deleteUser(ctx context.Context, user store.User) (any, error) {
+	if err := cli.store.DeleteUser(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("error deleting user: %w", err)
+	}
+
+	return newAdminUser(user), nil
+}
+
+// undeleteUser cancels an account deletion requested by the user,
+// before the grace period ends and the account is purged
+func (cli adminCLI) undeleteUser(ctx context.Context, user store.User) (any, error) {
+	restored, err := cli.store.RestoreUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error restoring user: %w", err)
+	}
+
+	return newAdminUser(restored), nil
+}
+
+func (cli adminCLI) grantRed(ctx context.Context, user store.User) (any, error) {
+	if err := cli.store.UpgradeUser(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("error granting Chirpy Red: %w", err)
+	}
+
+	user.IsChirpyRed = true
+	return newAdminUser(user), nil
+}
+
+// setModerator grants or revokes the review of chirp reports, access
+// tokens carry no role so the change applies to the next request
+func (cli adminCLI) setModerator(moderator bool) func(context.Context, store.User) (any, error) {
+	return func(ctx context.Context, user store.User) (any, error) {
+		if err := cli.store.SetModerator(ctx, user.ID, moderator); err != nil {
+			return nil, fmt.Errorf("error updating the moderator role: %w", err)
+		}
+
+		user.IsModerator = moderator
+		return newAdminUser(user), nil
+	}
+}
+
+func (cli adminCLI) revokeTokens(ctx context.Context, user store.User) (any, error) {
+	revoked, err := cli.store.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+
+	return struct {
+		UserID  uuid.UUID `json:"user_id"`
+		Revoked int64     `json:"revoked"`
+	}{user.ID, revoked}, nil
+}
+
+func (cli adminCLI) purgeChirps(ctx context.Context, args []string) (any, error) {
+	flags := flag.NewFlagSet("chirps purge", flag.ContinueOnError)
+	userRef := flags.String("user", "", "only purge the chirps of this user")
+	all := flags.Bool("all", false, "purge the chirps of every user")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if (*userRef == "") == !*all {
+		return nil, errors.New("chirps purge: pass either -user or -all")
+	}
+
+	var deleted int64
+	var err error
+
+	if *all {
+		deleted, err = cli.store.DeleteAllChirps(ctx)
+	} else {
+		user, findErr := cli.findUser(ctx, *userRef)
+		if findErr != nil {
+			return nil, findErr
+		}
+		deleted, err = cli.store.DeleteUserChirps(ctx, user.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error purging chirps: %w", err)
+	}
+
+	return struct {
+		Deleted int64 `json:"deleted"`
+	}{deleted}, nil
+}
+
+// rotateKey stores a new signing key and retires the previous ones,
+// running servers pick it up within signingKeyRefreshInterval, or as
+// soon as they receive a token it signed. The first rotation also
+// retires SECRET, whose tokens are rejected one accessTokenLifetime
+// later.
+func (cli adminCLI) rotateKey(ctx context.Context) (any, error) {
+	key, err := auth.NewSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// servers never see two active keys, nor none
+	var created store.SigningKey
+	err = cli.store.InTx(ctx, func(tx store.Store) error {
+		created, err = tx.RotateSigningKey(ctx, key.ID, key.Secret)
+		if err != nil {
+			return fmt.Errorf("error rotating signing key: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		KeyID     string    `json:"key_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}{created.ID, created.CreatedAt}, nil
+}
+
+func (cli adminCLI) stats(ctx context.Context) (any, error) {
+	stats, err := cli.store.GetStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading statistics: %w", err)
+	}
+
+	return struct {
+		Users               int64 `json:"users"`
+		ChirpyRedUsers      int64 `json:"chirpy_red_users"`
+		SuspendedUsers      int64 `json:"suspended_users"`
+		Chirps              int64 `json:"chirps"`
+		DeletedChirps       int64 `json:"deleted_chirps"`
+		ActiveRefreshTokens int64 `json:"active_refresh_tokens"`
+	}(stats), nil
+}
+
+// print writes result as indented JSON, or as a table with one row per
+// field named after its json tag
+func (cli adminCLI) print(result any) error {
+	if cli.output == "json" {
+		enc := json.NewEncoder(cli.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	w := tabwriter.NewWriter(cli.out, 0, 0, 2, ' ', 0)
+
+	v := reflect.ValueOf(result)
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		fmt.Fprintf(w, "%v\t%v\n", strings.ToUpper(name), v.Field(i).Interface())
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// errAccountDeleted aborts the login of an account pending deletion
+var errAccountDeleted = errors.New("account deleted")
+
+// Error codes of the account deletion endpoints
+const (
+	// codeAccountDeleted is returned to accounts pending deletion
+	codeAccountDeleted = "account_deleted"
+	// codeAccountNotDeleted is returned when restoring an account that
+	// is not pending deletion
+	codeAccountNotDeleted = "account_not_deleted"
+)
+
+// AccountDeletion tells the user when the account will be purged
+type AccountDeletion struct {
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// handlerDeleteUser deletes the account of the authenticated user once
+// the password is confirmed. The account is hidden and signed out at
+// once, then purged with its chirps and tokens after the grace period
+// unless the user restores it with handlerRestoreUser.
+//
+// Returns 400 if JSON decoding or validation fails
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the password is wrong
+// Returns 404 if the account is already pending deletion
+// Returns 202 with the purge date on success
+func (cfg *apiConfig) handlerDeleteUser() http.Handler {
+	type reqParams struct {
+		Password string `json:"password" validate:"required"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var params reqParams
+
+		if err := request.Decode(w, r, &params); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		user, err := cfg.store.GetUserByID(r.Context(), userID)
+		if err == nil && user.Deleted() {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		match, _, err := cfg.hasher.Check(r.Context(), params.Password, user.HashedPassword)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error checking password: %w", err)))
+			return
+		}
+		if !match {
+			apierror.Write(w, r, apierror.Forbidden(codeInvalidCurrentPassword, "the password is wrong"))
+			return
+		}
+
+		// refresh tokens are revoked with the deletion, access tokens
+		// expire within the hour
+		var deleted store.User
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			deleted, err = tx.SoftDeleteUser(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.RevokeUserRefreshTokens(r.Context(), userID)
+			return err
+		})
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "account deleted", "user_id", userID)
+
+		// the purge measures the grace period from deleted_at with the
+		// clock that set it
+		writeJSON(w, http.StatusAccepted, AccountDeletion{
+			DeletedAt:  *deleted.DeletedAt,
+			PurgeAfter: deleted.DeletedAt.Add(cfg.config.Accounts.DeletionGrace),
+		})
+	})
+}
+
+// handlerRestoreUser cancels the deletion of an account during its
+// grace period, its chirps are listed again. The deletion revoked the
+// refresh tokens of the account, so the user proves it with the email
+// and password instead of a JWT, then logs in again.
+//
+// Returns 400 if JSON decoding or validation fails
+// Returns 401 if the email or password is wrong
+// Returns 404 if the account is not pending deletion
+// Returns 200 with the restored user on success
+func (cfg *apiConfig) handlerRestoreUser() http.Handler {
+	type reqParams struct {
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params reqParams
+
+		if err := request.Decode(w, r, &params); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		invalidCredentials := apierror.Unauthorized(codeInvalidCredentials, "incorrect email or password")
+
+		user, err := cfg.store.GetUserByEmail(r.Context(), params.Email)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, invalidCredentials)
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		match, _, err := cfg.hasher.Check(r.Context(), params.Password, user.HashedPassword)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error checking password: %w", err)))
+			return
+		}
+		if !match {
+			apierror.Write(w, r, invalidCredentials)
+			return
+		}
+
+		restored, err := cfg.store.RestoreUser(r.Context(), user.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound(codeAccountNotDeleted, "the account is not pending deletion"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error restoring the account: %w", err)))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "account restored", "user_id", user.ID)
+		writeJSON(w, http.StatusOK, newUser(restored))
+	})
+}
+
+// purgeDeletedUsers deletes the accounts past their grace period every
+// interval until ctx is canceled, ON DELETE CASCADE removes their data
+func (cfg *apiConfig) purgeDeletedUsers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.store.PurgeDeletedUsers(ctx, cfg.config.Accounts.DeletionGrace)
+			if err != nil {
+				slog.ErrorContext(ctx, "error purging deleted accounts", "err", err)
+				continue
+			}
+
+			if purged > 0 {
+				slog.InfoContext(ctx, "deleted accounts purged", "count", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/fixtures"
+	"github.com/luis-octavius/chirpy/internal/sqlitedb"
+	"github.com/luis-octavius/chirpy/internal/store"
+	"github.com/luis-octavius/chirpy/internal/tracing"
+)
+
+// handlerReset deletes every row of the data tables in a single
+// transaction, so integration tests start from an empty database
+//
+// Returns 500 if any table cannot be emptied, nothing is deleted then
+// Returns 200 on success
+func (cfg *apiConfig) handlerReset() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := cfg.fixturesTx(r.Context(), func(q fixtures.Queries) error {
+			return fixtures.Reset(r.Context(), q)
+		})
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error resetting database: %w", err)))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// codeInvalidFixtures is returned when the seed fixtures cannot be read
+// or parsed
+const codeInvalidFixtures = "invalid_fixtures"
+
+// handlerSeed resets the database and loads fixtures in a single
+// transaction. The fixtures come from the request body, as JSON or
+// YAML depending on its Content-Type, or from SEED_FILE when the body
+// is empty.
+//
+// Returns 400 if the fixtures cannot be parsed or are inconsistent
+// Returns 500 if the fixtures cannot be stored, nothing is changed then
+// Returns 200 with the number of rows loaded on success
+func (cfg *apiConfig) handlerSeed() http.Handler {
+	type seedResponse struct {
+		Users         int `json:"users"`
+		Chirps        int `json:"chirps"`
+		Follows       int `json:"follows"`
+		RefreshTokens int `json:"refresh_tokens"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, format, err := cfg.readFixtures(r)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest(codeInvalidFixtures, err.Error()))
+			return
+		}
+
+		f, err := fixtures.Parse(data, format)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest(codeInvalidFixtures, err.Error()))
+			return
+		}
+
+		err = cfg.fixturesTx(r.Context(), func(q fixtures.Queries) error {
+			if err := fixtures.Reset(r.Context(), q); err != nil {
+				return err
+			}
+			return fixtures.Load(r.Context(), q, cfg.hasher, f)
+		})
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error seeding database: %w", err)))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, seedResponse{
+			Users:         len(f.Users),
+			Chirps:        len(f.Chirps),
+			Follows:       len(f.Follows),
+			RefreshTokens: len(f.RefreshTokens),
+		})
+	})
+}
+
+// readFixtures returns the raw fixtures of a seed request and their
+// format
+func (cfg *apiConfig) readFixtures(r *http.Request) ([]byte, string, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading request body: %w", err)
+	}
+
+	if len(data) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/json":
+			return data, fixtures.FormatJSON, nil
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return data, fixtures.FormatYAML, nil
+		default:
+			return nil, "", fmt.Errorf("unsupported Content-Type %q, expected JSON or YAML", mediaType)
+		}
+	}
+
+	if cfg.config.SeedFile == "" {
+		return nil, "", fmt.Errorf("empty request body and no SEED_FILE configured")
+	}
+
+	data, err = os.ReadFile(cfg.config.SeedFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading SEED_FILE: %w", err)
+	}
+
+	switch filepath.Ext(cfg.config.SeedFile) {
+	case ".yaml", ".yml":
+		return data, fixtures.FormatYAML, nil
+	default:
+		return data, fixtures.FormatJSON, nil
+	}
+}
+
+// fixturesTx runs fn with the fixture queries of the configured backend
+// bound to a transaction of the database
+func (cfg *apiConfig) fixturesTx(ctx context.Context, fn func(q fixtures.Queries) error) error {
+	return store.Tx(ctx, cfg.db, func(tx *sql.Tx) error {
+		if cfg.config.DatabaseBackend == config.BackendSQLite {
+			return fn(fixtures.SQLite(sqlitedb.New(tracing.WrapDB(tx, tracing.SystemSQLite))))
+		}
+		return fn(fixtures.Postgres(database.New(tracing.WrapDB(tx, tracing.SystemPostgreSQL))))
+	})
+}
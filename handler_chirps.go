@@ -1,131 +1,249 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/luis-octavius/chirpy/internal/auth"
-	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/chirptext"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
 )
 
-// handlerAddChirps adds a chirp on the database
+// Error codes of the chirp endpoints
+const (
+	codeInvalidAuthorID = "invalid_author_id"
+	codeInvalidChirpID  = "invalid_chirp_id"
+	codeChirpNotFound   = "chirp_not_found"
+	codeNotChirpAuthor  = "not_chirp_author"
+	codeChirpDeleted    = "chirp_deleted"
+)
+
+// CreateChirpRequest is a new chirp. The body is checked by
+// prepareChirp, the reply target and attachments like those of a draft.
+type CreateChirpRequest struct {
+	Body        string     `json:"body"`
+	ReplyToID   uuid.UUID  `json:"reply_to_id"`
+	Attachments []string   `json:"attachments"`
+	PublishAt   *time.Time `json:"publish_at"`
+}
+
+func (req CreateChirpRequest) Validate() []apierror.FieldError {
+	return validateAttachments(req.Attachments)
+}
+
+// handlerAddChirps adds a chirp on the database. With a publish_at in
+// the future the chirp is scheduled instead, it is not listed until
+// the scheduler publishes it. Scheduled chirps have no reply target nor
+// attachments, those are posted now or saved as a draft.
 //
-// Returns 400 if JSON decoding fails or chirp exceeds length limit
+// Returns 401 if the request has no valid JWT
+// Returns 400 if JSON decoding fails, chirp exceeds the length limit of
+// the plan of the user, an attachment is invalid, the chirp replied to
+// does not exist or publish_at is not in the future or set on a reply
+// or a chirp with attachments
+// Returns 403 if the account is suspended or pending deletion
 // Returns 500 if the chirp creation fails on the database
-// Returns 201 with created chirp data on success
+// Returns 201 with created chirp data, or the scheduled chirp, on success
 func (cfg *apiConfig) handlerAddChirps() http.Handler {
-	type CreateChirpRequest struct {
-		Body string `json:"body"`
-	}
-
-	type validateChirpResponse struct {
-		Error       string `json:"error,omitempty"`
-		Valid       bool   `json:"valid,omitempty"`
-		CleanedBody string `json:"cleaned_body,omitempty"`
-	}
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
 		var req CreateChirpRequest
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			resp := validateChirpResponse{Error: "Something went wrong"}
-			writeJSON(w, http.StatusBadRequest, resp)
+		if err := request.Decode(w, r, &req); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		bearerToken, err := auth.GetBearerToken(r.Header)
+		user, err := cfg.store.GetUserByID(r.Context(), userID)
 		if err != nil {
-			log.Printf("error getting the Bearer token: %v", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error fetching the author: %w", err)))
 			return
 		}
 
-		userID, err := auth.ValidateJWT(bearerToken, cfg.secret)
+		filteredMessage, err := cfg.prepareChirp(user, req.Body)
 		if err != nil {
-			log.Printf("error authorizing user: %v", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, err)
 			return
 		}
 
-		chirpLength := 140
+		if req.PublishAt != nil {
+			if !req.PublishAt.After(time.Now()) {
+				apierror.Write(w, r, apierror.BadRequest(codeInvalidPublishAt, "publish_at must be in the future"))
+				return
+			}
+			if req.ReplyToID != uuid.Nil || len(req.Attachments) > 0 {
+				apierror.Write(w, r, apierror.BadRequest(codeInvalidPublishAt, "replies and chirps with attachments cannot be scheduled"))
+				return
+			}
+
+			// TIMESTAMP columns drop the offset of the client, the time is
+			// stored in UTC so it reads back as the instant it was sent
+			publishAt := req.PublishAt.UTC()
+
+			scheduled, err := cfg.store.ScheduleChirp(r.Context(), userID, filteredMessage, publishAt)
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal(fmt.Errorf("error scheduling the chirp: %w", err)))
+				return
+			}
 
-		// the size of the body cannot be greater than the size of a chirp
-		if len(req.Body) > chirpLength {
-			resp := validateChirpResponse{Error: "Chirp is too long"}
-			writeJSON(w, http.StatusBadRequest, resp)
+			writeJSON(w, http.StatusCreated, newScheduledChirp(scheduled))
 			return
 		}
 
-		// filter message to block prohibited words
-		filteredMessage := validateMessage(req.Body)
+		if err := checkReplyTo(r.Context(), cfg.store, req.ReplyToID, userID); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
 
-		chirp, err := cfg.queries.CreateChirp(r.Context(), database.CreateChirpParams{
-			Body:   filteredMessage,
-			UserID: userID,
+		chirp, err := cfg.store.CreateChirp(r.Context(), store.Chirp{
+			UserID:      userID,
+			Body:        filteredMessage,
+			ReplyToID:   req.ReplyToID,
+			Attachments: req.Attachments,
 		})
 		if err != nil {
-			log.Printf("error creating the chirp: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error creating the chirp: %w", err)))
 			return
 		}
 
-		resp := Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		}
+		cfg.metrics.ChirpCreated()
+		cfg.requestLinkPreviews(r.Context(), chirp)
 
-		writeJSON(w, http.StatusCreated, resp)
+		writeJSON(w, http.StatusCreated, newChirp(chirp))
 	})
 }
 
-// handlerGetAllChirps retrieves all chirps from the database
+// prepareChirp normalizes the body of a new chirp of user, posted or
+// published from a draft, checks its length against the limit of the
+// plan of user and filters its prohibited words. The access token of a
+// suspended or deleted user stays valid until it expires, so the
+// account is checked here rather than trusted from the token.
 //
+// Returns a 403 error if the account is suspended or pending deletion
+// Returns a 400 validation_failed error if the body is empty, too long
+// or too large
+func (cfg *apiConfig) prepareChirp(user store.User, body string) (string, error) {
+	if user.Suspended() {
+		return "", userError(errAccountSuspended)
+	}
+	if user.Deleted() {
+		return "", userError(errAccountDeleted)
+	}
+
+	body = chirptext.Normalize(body)
+	if body == "" {
+		return "", apierror.Validation(apierror.FieldError{Field: "body", Code: request.CodeRequired, Message: "is required"})
+	}
+	if err := cfg.checkBodySize(body); err != nil {
+		return "", err
+	}
+
+	limit := cfg.chirpMaxLength(user)
+	if chirptext.Length(body, cfg.config.Chirps.URLLength) > limit {
+		return "", apierror.Validation(apierror.FieldError{
+			Field:   "body",
+			Code:    request.CodeTooLong,
+			Message: fmt.Sprintf("must be at most %v characters", limit),
+		})
+	}
+
+	// filter message to block prohibited words
+	return validateMessage(body), nil
+}
+
+// checkBodySize caps the body of a chirp or a draft in bytes, and the
+// size of its URLs, which chirptext.Length counts as URLLength however
+// long they are
+//
+// Returns a 400 validation_failed error if either is too large
+func (cfg *apiConfig) checkBodySize(body string) error {
+	if len(body) > cfg.config.Chirps.MaxBodyBytes {
+		return apierror.Validation(apierror.FieldError{
+			Field:   "body",
+			Code:    request.CodeTooLong,
+			Message: fmt.Sprintf("must be at most %v bytes", cfg.config.Chirps.MaxBodyBytes),
+		})
+	}
+	if chirptext.LongestURL(body) > chirptext.MaxURLLength {
+		return apierror.Validation(apierror.FieldError{
+			Field:   "body",
+			Code:    request.CodeTooLong,
+			Message: fmt.Sprintf("URLs must be at most %v characters", chirptext.MaxURLLength),
+		})
+	}
+
+	return nil
+}
+
+// chirpMaxLength returns the longest chirp user may post, Chirpy Red
+// users get longer chirps
+func (cfg *apiConfig) chirpMaxLength(user store.User) int {
+	if user.IsChirpyRed {
+		return cfg.config.Chirps.MaxLengthRed
+	}
+	return cfg.config.Chirps.MaxLength
+}
+
+// handlerGetAllChirps retrieves all chirps from the database, without
+// the chirps of authors blocking the viewer. The timeline, without
+// author_id, also hides the authors the viewer blocked or muted.
+//
+// Returns 400 if author_id is not a valid UUID
+// Returns 401 if the request has an invalid JWT
 // Returns 500 if the chirps cannot be retrieved from database
-// Returns 200 with all chirps data on success
+// Returns 200 with all chirps data, and the previews of their URLs, on
+// success
 func (cfg *apiConfig) handlerGetAllChirps() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viewerID, err := cfg.viewer(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
 		authorID := r.URL.Query().Get("author_id")
 		sortParam := r.URL.Query().Get("sort")
 
-		log.Println("author id: ", authorID)
-
+		var fetchedChirps []store.Chirp
 		if authorID != "" {
-			parsedID, err := uuid.Parse(authorID)
-			if err != nil {
-				log.Printf("error parsing id: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
+			parsedID, parseErr := uuid.Parse(authorID)
+			if parseErr != nil {
+				apierror.Write(w, r, apierror.BadRequest(codeInvalidAuthorID, "author_id must be a UUID").Wrap(parseErr))
 				return
 			}
-			fetchedChirps, err := cfg.queries.GetChirpsByUserID(r.Context(), parsedID)
-			if err != nil {
-				log.Printf("error fetching chirp by author ID: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			sortChirps(sortParam, fetchedChirps)
-			writeJSON(w, http.StatusOK, fetchedChirps)
-
+			fetchedChirps, err = cfg.store.ListChirpsByUser(r.Context(), parsedID, viewerID)
 		} else {
-			fetchedChirps, err := cfg.queries.GetAllChirps(r.Context())
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-
-			sortChirps(sortParam, fetchedChirps)
-			writeJSON(w, http.StatusOK, fetchedChirps)
+			fetchedChirps, err = cfg.store.ListChirps(r.Context(), viewerID)
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error fetching chirps: %w", err)))
+			return
+		}
 
+		chirps := newChirps(fetchedChirps)
+		if err := cfg.addLinkPreviews(r.Context(), chirps); err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
 		}
+
+		sortChirps(sortParam, chirps)
+		writeJSON(w, http.StatusOK, chirps)
 	})
 }
 
-func sortChirps(param string, chirps []database.Chirp) {
+func sortChirps(param string, chirps []Chirp) {
 	if param == "asc" {
 		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.Before(chirps[j].CreatedAt) })
 		return
@@ -140,71 +258,174 @@ func sortChirps(param string, chirps []database.Chirp) {
 // HandlerGetChirp returns a chirp based on a id path
 //
 // Returns 400 if the chirp ID cannot be parsed as UUID
-// Returns 404 if no chirp exists with the given ID
-// Returns 200 with chirp data on success
+// Returns 401 if the request has an invalid JWT
+// Returns 404 if no chirp exists with the given ID, or its author
+// blocked the viewer
+// Returns 410 if the chirp was deleted
+// Returns 200 with chirp data, and the previews of its URLs, on success
 func (cfg *apiConfig) handlerGetChirp() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		chirpID := r.PathValue("chirpID")
-		parsedChirpID, err := uuid.Parse(chirpID)
+		chirpID, err := parseChirpID(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		viewerID, err := cfg.viewer(r)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest) // 400
+			apierror.Write(w, r, err)
 			return
 		}
 
-		chirp, err := cfg.queries.GetChirpByID(r.Context(), parsedChirpID)
+		chirp, err := cfg.store.GetChirp(r.Context(), chirpID, viewerID)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound) // 404
+			apierror.Write(w, r, chirpError(err))
 			return
 		}
 
-		writeJSON(w, http.StatusOK, chirp) // 200
+		chirps := []Chirp{newChirp(chirp)}
+		if err := cfg.addLinkPreviews(r.Context(), chirps); err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, chirps[0]) // 200
 	})
 }
 
+// errNotAuthor aborts the deletion of a chirp by another user
+var errNotAuthor = errors.New("not the author of the chirp")
+
+// handlerDeleteChirp deletes a chirp of the authenticated user. The
+// chirp is only marked as deleted, its author can restore it during
+// CHIRP_UNDO_WINDOW and it is purged after CHIRP_RETENTION.
+//
+// Returns 400 if the chirp ID cannot be parsed as UUID
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the chirp belongs to another user
+// Returns 404 if no chirp exists with the given ID
+// Returns 410 if the chirp is already deleted
+// Returns 204 on success
 func (cfg *apiConfig) handlerDeleteChirp() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		chirpID := r.PathValue("chirpID")
-		parsedChirpID, err := uuid.Parse(chirpID)
+		chirpID, err := parseChirpID(r)
 		if err != nil {
-			log.Printf("error parsing chirpID into UUID: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, err)
 			return
 		}
 
-		token, err := auth.GetBearerToken(r.Header)
+		userID, err := cfg.authenticate(r)
 		if err != nil {
-			log.Printf("error getting the token from header: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, err)
 			return
 		}
 
-		validatedUser, err := auth.ValidateJWT(token, cfg.secret)
+		// the author check and the delete see the same chirp
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			chirp, err := tx.GetChirp(r.Context(), chirpID, userID)
+			if err != nil {
+				return err
+			}
+
+			if chirp.UserID != userID {
+				return errNotAuthor
+			}
+
+			return tx.DeleteChirp(r.Context(), chirpID, userID)
+		})
 		if err != nil {
-			log.Printf("error validating JWT token from user: %v", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, chirpError(err))
 			return
 		}
 
-		chirp, err := cfg.queries.GetChirpByID(r.Context(), parsedChirpID)
+		slog.InfoContext(r.Context(), "chirp deleted successfully", "chirp_id", chirpID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handlerRestoreChirp undoes the deletion of a chirp by its author
+// during CHIRP_UNDO_WINDOW
+//
+// Returns 400 if the chirp ID cannot be parsed as UUID
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the user deleted no chirp with the given ID within
+// the undo window
+// Returns 200 with the restored chirp on success
+func (cfg *apiConfig) handlerRestoreChirp() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := parseChirpID(r)
 		if err != nil {
-			log.Printf("error getting chirp by ID: %v", err)
+			apierror.Write(w, r, err)
+			return
 		}
 
-		if chirp.UserID != validatedUser {
-			w.WriteHeader(http.StatusForbidden)
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		err = cfg.queries.DeleteChirpByID(r.Context(), database.DeleteChirpByIDParams{
-			ID:   parsedChirpID,
-			ID_2: validatedUser,
-		})
+		chirp, err := cfg.store.RestoreChirp(r.Context(), chirpID, userID, cfg.config.Chirps.UndoWindow)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound(codeChirpNotFound, "no chirp deleted by the user within the undo window exists with the given ID"))
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error restoring the chirp: %w", err)))
 			return
 		}
 
-		log.Printf("chirp with id %v deleted successfully", chirpID)
-		w.WriteHeader(http.StatusNoContent)
+		slog.InfoContext(r.Context(), "chirp restored", "chirp_id", chirpID)
+		writeJSON(w, http.StatusOK, newChirp(chirp))
 	})
 }
+
+// purgeDeletedChirps deletes for good the chirps past their retention
+// every interval until ctx is canceled
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.store.PurgeDeletedChirps(ctx, cfg.config.Chirps.Retention)
+			if err != nil {
+				slog.ErrorContext(ctx, "error purging deleted chirps", "err", err)
+				continue
+			}
+
+			if purged > 0 {
+				slog.InfoContext(ctx, "deleted chirps purged", "count", purged)
+			}
+		}
+	}
+}
+
+// parseChirpID returns the chirp ID of the request path
+//
+// Returns a 400 invalid_chirp_id error if it is not a UUID
+func parseChirpID(r *http.Request) (uuid.UUID, error) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		return uuid.Nil, apierror.BadRequest(codeInvalidChirpID, "the chirp ID must be a UUID").Wrap(err)
+	}
+
+	return chirpID, nil
+}
+
+// chirpError maps the errors of the chirp store methods to API errors
+func chirpError(err error) error {
+	switch {
+	case errors.Is(err, errNotAuthor):
+		return apierror.Forbidden(codeNotChirpAuthor, "the chirp belongs to another user")
+	case errors.Is(err, store.ErrNotFound):
+		return apierror.NotFound(codeChirpNotFound, "no chirp exists with the given ID")
+	case errors.Is(err, store.ErrDeleted):
+		return apierror.New(http.StatusGone, codeChirpDeleted, "the chirp was deleted")
+	default:
+		return apierror.Internal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/luis-octavius/chirpy/internal/chirptext"
+)
+
+// ClientConfig is the configuration clients need to mirror the checks
+// of the server, such as counting the characters left in a chirp
+type ClientConfig struct {
+	Chirps ChirpLimits `json:"chirps"`
+}
+
+// ChirpLimits are the length limits of chirps, in grapheme clusters
+// with every URL counted as url_length. The body and each of its URLs
+// are also capped in bytes.
+type ChirpLimits struct {
+	MaxLength          int    `json:"max_length"`
+	MaxLengthChirpyRed int    `json:"max_length_chirpy_red"`
+	URLLength          int    `json:"url_length"`
+	MaxBodyBytes       int    `json:"max_body_bytes"`
+	MaxURLLength       int    `json:"max_url_length"`
+	Normalization      string `json:"normalization"`
+}
+
+// handlerGetConfig returns the client configuration, it only changes
+// when the server restarts
+//
+// Returns 200 with the configuration
+func (cfg *apiConfig) handlerGetConfig() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, ClientConfig{
+			Chirps: ChirpLimits{
+				MaxLength:          cfg.config.Chirps.MaxLength,
+				MaxLengthChirpyRed: cfg.config.Chirps.MaxLengthRed,
+				URLLength:          cfg.config.Chirps.URLLength,
+				MaxBodyBytes:       cfg.config.Chirps.MaxBodyBytes,
+				MaxURLLength:       chirptext.MaxURLLength,
+				Normalization:      "NFC",
+			},
+		})
+	})
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/chirptext"
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the draft endpoints
+const (
+	codeInvalidDraftID = "invalid_draft_id"
+	codeDraftNotFound  = "draft_not_found"
+)
+
+// Limits of drafts. The body is measured like a chirp, in grapheme
+// clusters with URLs counted as CHIRP_URL_LENGTH, up to
+// config.MaxDraftLength.
+const (
+	maxAttachments   = 4
+	maxAttachmentURL = chirptext.MaxURLLength
+)
+
+// Draft is an unfinished chirp of the authenticated user
+type Draft struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Body        string     `json:"body"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id"`
+	Attachments []string   `json:"attachments"`
+}
+
+func newDraft(d store.Draft) Draft {
+	draft := Draft{
+		ID:          d.ID,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+		Body:        d.Body,
+		ReplyToID:   optionalID(d.ReplyToID),
+		Attachments: d.Attachments,
+	}
+	if draft.Attachments == nil {
+		draft.Attachments = []string{}
+	}
+
+	return draft
+}
+
+// DraftRequest is a draft, unlike a chirp its body may be empty or too
+// long until it is published. ReplyToID is the chirp the draft replies
+// to, Attachments the http or https URLs of its media.
+type DraftRequest struct {
+	Body        string    `json:"body"`
+	ReplyToID   uuid.UUID `json:"reply_to_id"`
+	Attachments []string  `json:"attachments"`
+}
+
+func (req DraftRequest) Validate() []apierror.FieldError {
+	return validateAttachments(req.Attachments)
+}
+
+// validateAttachments checks the attachments of a draft or a chirp, at
+// most maxAttachments http or https URLs
+func validateAttachments(attachments []string) []apierror.FieldError {
+	if len(attachments) > maxAttachments {
+		return []apierror.FieldError{{
+			Field:   "attachments",
+			Code:    request.CodeTooLong,
+			Message: fmt.Sprintf("must have at most %v attachments", maxAttachments),
+		}}
+	}
+
+	var fields []apierror.FieldError
+	for i, attachment := range attachments {
+		name := fmt.Sprintf("attachments.%d", i)
+
+		// url.Parse rejects control characters, so an attachment never
+		// holds the newlines SQLite separates them with
+		u, err := url.Parse(attachment)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fields = append(fields, apierror.FieldError{Field: name, Code: request.CodeInvalidURL, Message: "must be an http or https URL"})
+		} else if len(attachment) > maxAttachmentURL {
+			fields = append(fields, apierror.FieldError{Field: name, Code: request.CodeTooLong, Message: fmt.Sprintf("must be at most %v characters", maxAttachmentURL)})
+		}
+	}
+
+	return fields
+}
+
+// handlerCreateDraft saves an unfinished chirp of the authenticated user
+//
+// Returns 400 if the body is invalid or the chirp replied to does not
+// exist
+// Returns 401 if the request has no valid JWT
+// Returns 201 with the draft on success
+func (cfg *apiConfig) handlerCreateDraft() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		draft, err := cfg.decodeDraft(w, r, userID)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		draft, err = cfg.store.CreateDraft(r.Context(), draft)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error creating the draft: %w", err)))
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, newDraft(draft))
+	})
+}
+
+// handlerListDrafts returns the drafts of the authenticated user, the
+// last updated first
+//
+// Returns 401 if the request has no valid JWT
+// Returns 200 with the drafts on success
+func (cfg *apiConfig) handlerListDrafts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		drafts, err := cfg.store.ListDrafts(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error listing drafts: %w", err)))
+			return
+		}
+
+		result := make([]Draft, 0, len(drafts))
+		for _, draft := range drafts {
+			result = append(result, newDraft(draft))
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// handlerGetDraft returns a draft of the authenticated user
+//
+// Returns 400 if the draft ID is not a UUID
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the user has no draft with the given ID
+// Returns 200 with the draft on success
+func (cfg *apiConfig) handlerGetDraft() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, draftID, err := cfg.parseDraft(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		draft, err := cfg.store.GetDraft(r.Context(), draftID, userID)
+		if err != nil {
+			apierror.Write(w, r, draftError(err))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newDraft(draft))
+	})
+}
+
+// handlerUpdateDraft replaces the body, reply target and attachments of
+// a draft of the authenticated user
+//
+// Returns 400 if the draft ID is not a UUID, the body is invalid or the
+// chirp replied to does not exist
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the user has no draft with the given ID
+// Returns 200 with the updated draft on success
+func (cfg *apiConfig) handlerUpdateDraft() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, draftID, err := cfg.parseDraft(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		draft, err := cfg.decodeDraft(w, r, userID)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		draft.ID = draftID
+		draft, err = cfg.store.UpdateDraft(r.Context(), draft)
+		if err != nil {
+			apierror.Write(w, r, draftError(err))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newDraft(draft))
+	})
+}
+
+// handlerDeleteDraft discards a draft of the authenticated user
+//
+// Returns 400 if the draft ID is not a UUID
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the user has no draft with the given ID
+// Returns 204 on success
+func (cfg *apiConfig) handlerDeleteDraft() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, draftID, err := cfg.parseDraft(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := cfg.store.DeleteDraft(r.Context(), draftID, userID); err != nil {
+			apierror.Write(w, r, draftError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handlerPublishDraft turns a draft of the authenticated user into a
+// chirp with its reply target and attachments. The body goes through
+// prepareChirp like a posted chirp, and the draft is deleted in the
+// same transaction the chirp is created.
+//
+// Returns 400 if the draft ID is not a UUID, the body of the draft is
+// empty or too long for a chirp, or the chirp replied to was deleted
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the account is suspended or pending deletion
+// Returns 404 if the user has no draft with the given ID
+// Returns 201 with the created chirp on success
+func (cfg *apiConfig) handlerPublishDraft() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, draftID, err := cfg.parseDraft(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var chirp store.Chirp
+
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			draft, err := tx.GetDraft(r.Context(), draftID, userID)
+			if err != nil {
+				return err
+			}
+
+			user, err := tx.GetUserByID(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+
+			draft.Body, err = cfg.prepareChirp(user, draft.Body)
+			if err != nil {
+				return err
+			}
+
+			if err := checkReplyTo(r.Context(), tx, draft.ReplyToID, userID); err != nil {
+				return err
+			}
+
+			if err := tx.DeleteDraft(r.Context(), draftID, userID); err != nil {
+				return err
+			}
+
+			chirp, err = tx.PublishDraft(r.Context(), draft)
+			return err
+		})
+		if err != nil {
+			apierror.Write(w, r, draftError(err))
+			return
+		}
+
+		cfg.metrics.ChirpCreated()
+		cfg.requestLinkPreviews(r.Context(), chirp)
+
+		slog.InfoContext(r.Context(), "draft published", "draft_id", draftID, "chirp_id", chirp.ID)
+		writeJSON(w, http.StatusCreated, newChirp(chirp))
+	})
+}
+
+// decodeDraft reads the draft of userID in the request body. Its body
+// is measured and capped like prepareChirp measures chirps.
+//
+// Returns a 400 error if the draft is invalid or the chirp it replies
+// to does not exist
+func (cfg *apiConfig) decodeDraft(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (store.Draft, error) {
+	var req DraftRequest
+	if err := request.Decode(w, r, &req); err != nil {
+		return store.Draft{}, err
+	}
+
+	body := chirptext.Normalize(req.Body)
+	if err := cfg.checkBodySize(body); err != nil {
+		return store.Draft{}, err
+	}
+	if chirptext.Length(body, cfg.config.Chirps.URLLength) > config.MaxDraftLength {
+		return store.Draft{}, apierror.Validation(apierror.FieldError{
+			Field:   "body",
+			Code:    request.CodeTooLong,
+			Message: fmt.Sprintf("must be at most %v characters", config.MaxDraftLength),
+		})
+	}
+
+	if err := checkReplyTo(r.Context(), cfg.store, req.ReplyToID, userID); err != nil {
+		return store.Draft{}, err
+	}
+
+	return store.Draft{
+		UserID:      userID,
+		Body:        body,
+		ReplyToID:   req.ReplyToID,
+		Attachments: req.Attachments,
+	}, nil
+}
+
+// parseDraft authenticates the request and returns the user and the
+// draft ID of the path
+func (cfg *apiConfig) parseDraft(r *http.Request) (userID, draftID uuid.UUID, err error) {
+	draftID, err = uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, apierror.BadRequest(codeInvalidDraftID, "the draft ID must be a UUID").Wrap(err)
+	}
+
+	userID, err = cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return userID, draftID, nil
+}
+
+// draftError maps the errors of the draft store methods to API errors,
+// the API errors of prepareChirp are kept
+func draftError(err error) error {
+	var apiErr *apierror.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, store.ErrNotFound):
+		return apierror.NotFound(codeDraftNotFound, "no draft exists with the given ID")
+	default:
+		return apierror.Internal(err)
+	}
+}
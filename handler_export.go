@@ -0,0 +1,410 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the export endpoints
+const (
+	codeInvalidExportFormat = "invalid_export_format"
+	codeExportNotFound      = "export_not_found"
+	codeExportRunning       = "export_running"
+)
+
+// Formats of the data exports
+const (
+	exportFormatZIP  = "zip"
+	exportFormatJSON = "json"
+)
+
+const (
+	// exportTimeout bounds the generation of a background export
+	exportTimeout = 10 * time.Minute
+	// exportRetention is how long a background export can be downloaded
+	exportRetention = 24 * time.Hour
+)
+
+// Export statuses of a background export
+const (
+	exportPending = "pending"
+	exportReady   = "ready"
+	exportFailed  = "failed"
+)
+
+// AccountExport is every piece of data Chirpy holds about a user.
+// Chirpy has no likes yet, so there are none to export; they belong
+// here once chirps can be liked.
+type AccountExport struct {
+	ExportedAt      time.Time        `json:"exported_at"`
+	Profile         User             `json:"profile"`
+	Chirps          []Chirp          `json:"chirps"`
+	Drafts          []Draft          `json:"drafts"`
+	ScheduledChirps []ScheduledChirp `json:"scheduled_chirps"`
+	Sessions        []Session        `json:"sessions"`
+}
+
+// Session is a refresh token without its secret value
+type Session struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// ExportStatus reports the progress of a background export
+type ExportStatus struct {
+	ID        uuid.UUID `json:"id"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exportJob is an export generated in the background, done is closed
+// once archive or err is set
+type exportJob struct {
+	id        uuid.UUID
+	userID    uuid.UUID
+	format    string
+	createdAt time.Time
+
+	done    chan struct{}
+	archive []byte
+	err     error
+}
+
+func (j *exportJob) status() ExportStatus {
+	status := ExportStatus{ID: j.id, Status: exportPending, Format: j.format, CreatedAt: j.createdAt}
+
+	select {
+	case <-j.done:
+		status.Status = exportReady
+		if j.err != nil {
+			status.Status = exportFailed
+		}
+	default:
+	}
+
+	return status
+}
+
+// errExportRunning rejects a background export while the user has
+// another one pending
+var errExportRunning = errors.New("an export is already running")
+
+// exportJobs holds the background exports of this instance in memory,
+// one per user. They are not shared between replicas: a client polls
+// the instance that started its export, through sticky sessions, or
+// gets a 404 and starts a new one. The zero value is ready to use.
+type exportJobs struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*exportJob
+}
+
+// start returns the pending export of the user in format, or runs
+// build in a new goroutine. The new export replaces the finished one
+// of the user, so a user holds at most one archive in memory.
+//
+// Returns errExportRunning if the user has an export pending in
+// another format
+func (e *exportJobs) start(userID uuid.UUID, format string, build func(context.Context) ([]byte, error)) (*exportJob, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.jobs == nil {
+		e.jobs = make(map[uuid.UUID]*exportJob)
+	}
+
+	for id, job := range e.jobs {
+		if time.Since(job.createdAt) > exportRetention {
+			delete(e.jobs, id)
+			continue
+		}
+		if job.userID != userID {
+			continue
+		}
+
+		if job.status().Status != exportPending {
+			delete(e.jobs, id)
+			continue
+		}
+		if job.format != format {
+			return nil, errExportRunning
+		}
+		return job, nil
+	}
+
+	job := &exportJob{
+		id:        uuid.New(),
+		userID:    userID,
+		format:    format,
+		createdAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	e.jobs[job.id] = job
+
+	go func() {
+		defer close(job.done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		job.archive, job.err = build(ctx)
+		if job.err != nil {
+			slog.Error("error generating export", "export_id", job.id, "user_id", userID, "err", job.err)
+		}
+	}()
+
+	return job, nil
+}
+
+// get returns an export of the user that has not expired
+func (e *exportJobs) get(id, userID uuid.UUID) (*exportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok || job.userID != userID || time.Since(job.createdAt) > exportRetention {
+		return nil, false
+	}
+
+	return job, true
+}
+
+// handlerExportUser exports the profile, chirps and sessions of the
+// authenticated user as a ZIP archive, or a JSON document with
+// ?format=json. Accounts with many chirps are exported in the
+// background, the response then points to the export to poll on the
+// same instance.
+//
+// Returns 400 if the format is neither zip nor json
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the account is pending deletion
+// Returns 409 if a background export in another format is running
+// Returns 202 with the export status and its Location when the export
+// runs in the background
+// Returns 200 with the archive on success
+func (cfg *apiConfig) handlerExportUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportFormatZIP
+		}
+		if format != exportFormatZIP && format != exportFormatJSON {
+			apierror.Write(w, r, apierror.BadRequest(codeInvalidExportFormat, "the format must be zip or json"))
+			return
+		}
+
+		user, err := cfg.store.GetUserByID(r.Context(), userID)
+		if err == nil && user.Deleted() {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		count, err := cfg.store.CountChirpsByUser(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error counting chirps: %w", err)))
+			return
+		}
+
+		build := func(ctx context.Context) ([]byte, error) {
+			export, err := cfg.collectExport(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+			return encodeExport(export, format)
+		}
+
+		if count > int64(cfg.config.Accounts.ExportSyncChirps) {
+			job, err := cfg.exports.start(userID, format, build)
+			if errors.Is(err, errExportRunning) {
+				apierror.Write(w, r, apierror.Conflict(codeExportRunning, "an export in another format is running, try again once it is done"))
+				return
+			}
+
+			w.Header().Set("Location", "/api/users/me/export/"+job.id.String())
+			writeJSON(w, http.StatusAccepted, job.status())
+			return
+		}
+
+		archive, err := build(r.Context())
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error generating export: %w", err)))
+			return
+		}
+
+		writeExport(w, format, time.Now(), archive)
+	})
+}
+
+// handlerGetExport returns a background export of the authenticated
+// user, from the memory of this instance
+//
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the export does not exist on this instance, expired,
+// was replaced by a newer export or belongs to another user
+// Returns 500 if the export failed
+// Returns 202 with the export status while it is pending
+// Returns 200 with the archive once it is ready
+func (cfg *apiConfig) handlerGetExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		notFound := apierror.NotFound(codeExportNotFound, "the export does not exist or expired")
+
+		exportID, err := uuid.Parse(r.PathValue("exportID"))
+		if err != nil {
+			apierror.Write(w, r, notFound)
+			return
+		}
+
+		job, ok := cfg.exports.get(exportID, userID)
+		if !ok {
+			apierror.Write(w, r, notFound)
+			return
+		}
+
+		switch status := job.status(); status.Status {
+		case exportPending:
+			writeJSON(w, http.StatusAccepted, status)
+		case exportFailed:
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error generating export: %w", job.err)))
+		default:
+			writeExport(w, job.format, job.createdAt, job.archive)
+		}
+	})
+}
+
+// collectExport gathers the data of user
+func (cfg *apiConfig) collectExport(ctx context.Context, user store.User) (AccountExport, error) {
+	chirps, err := cfg.store.ListChirpsByUser(ctx, user.ID, user.ID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("error listing chirps: %w", err)
+	}
+
+	drafts, err := cfg.store.ListDrafts(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("error listing drafts: %w", err)
+	}
+
+	scheduled, err := cfg.store.ListScheduledChirps(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("error listing scheduled chirps: %w", err)
+	}
+
+	tokens, err := cfg.store.ListRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("error listing refresh tokens: %w", err)
+	}
+
+	exportedDrafts := make([]Draft, 0, len(drafts))
+	for _, draft := range drafts {
+		exportedDrafts = append(exportedDrafts, newDraft(draft))
+	}
+
+	exportedScheduled := make([]ScheduledChirp, 0, len(scheduled))
+	for _, chirp := range scheduled {
+		exportedScheduled = append(exportedScheduled, newScheduledChirp(chirp))
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, Session{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			RevokedAt: token.RevokedAt,
+		})
+	}
+
+	return AccountExport{
+		ExportedAt:      time.Now().UTC(),
+		Profile:         newUser(user),
+		Chirps:          newChirps(chirps),
+		Drafts:          exportedDrafts,
+		ScheduledChirps: exportedScheduled,
+		Sessions:        sessions,
+	}, nil
+}
+
+// encodeExport returns export as a JSON document, or a ZIP archive
+// holding one JSON file per kind of data
+func encodeExport(export AccountExport, format string) ([]byte, error) {
+	if format == exportFormatJSON {
+		return json.MarshalIndent(export, "", "  ")
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"drafts.json", export.Drafts},
+		{"scheduled_chirps.json", export.ScheduledChirps},
+		{"sessions.json", export.Sessions},
+	}
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error adding %v: %w", file.name, err)
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, fmt.Errorf("error encoding %v: %w", file.name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("error closing archive: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeExport sends an archive as a file download
+func writeExport(w http.ResponseWriter, format string, date time.Time, archive []byte) {
+	contentType := "application/zip"
+	if format == exportFormatJSON {
+		contentType = "application/json"
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%v.%v", date.UTC().Format("2006-01-02"), format)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
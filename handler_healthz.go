@@ -4,6 +4,9 @@ import (
 	"net/http"
 )
 
+// handlerHealthz reports that the process is alive, without looking
+// at any dependency, so orchestrators only restart Chirpy when it is
+// truly stuck
 func handlerHealthz() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -13,3 +16,22 @@ func handlerHealthz() http.Handler {
 		_, _ = w.Write([]byte("OK"))
 	})
 }
+
+// handlerReadiness runs every registered health check and reports the
+// status of each dependency
+//
+// Returns 503 if any dependency is down
+// Returns 200 with the per-dependency report on success
+func (cfg *apiConfig) handlerReadiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := cfg.health.Check(r.Context())
+
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, status, report)
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/chirptext"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// linkPreviewBatch is the number of pending previews read at a time
+const linkPreviewBatch = 20
+
+// LinkPreview is the card of a URL linked by a chirp
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+func newLinkPreview(p store.LinkPreview) LinkPreview {
+	return LinkPreview{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		ImageURL:    p.ImageURL,
+		SiteName:    p.SiteName,
+	}
+}
+
+// requestLinkPreviews queues the URLs of new chirps for the fetcher.
+// Previews are best effort, a failure is logged and the chirps are
+// shown without them.
+func (cfg *apiConfig) requestLinkPreviews(ctx context.Context, chirps ...store.Chirp) {
+	if cfg.previews == nil {
+		return
+	}
+
+	var urls []string
+	for _, chirp := range chirps {
+		urls = append(urls, chirptext.URLs(chirp.Body)...)
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	if err := cfg.store.RequestLinkPreviews(ctx, urls); err != nil {
+		slog.ErrorContext(ctx, "error requesting link previews", "err", err)
+		return
+	}
+
+	// the fetcher is already awake when the channel is full
+	select {
+	case cfg.previewsRequested <- struct{}{}:
+	default:
+	}
+}
+
+// addLinkPreviews adds to the chirps the previews of their URLs that
+// were fetched, in the order the URLs appear in the chirps
+func (cfg *apiConfig) addLinkPreviews(ctx context.Context, chirps []Chirp) error {
+	var urls []string
+	for _, chirp := range chirps {
+		urls = append(urls, chirptext.URLs(chirp.Body)...)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	previews, err := cfg.store.GetLinkPreviews(ctx, urls)
+	if err != nil {
+		return fmt.Errorf("error fetching link previews: %w", err)
+	}
+
+	byURL := make(map[string]store.LinkPreview, len(previews))
+	for _, preview := range previews {
+		byURL[preview.URL] = preview
+	}
+
+	for i, chirp := range chirps {
+		for _, url := range chirptext.URLs(chirp.Body) {
+			if preview, ok := byURL[url]; ok {
+				chirps[i].Previews = append(chirps[i].Previews, newLinkPreview(preview))
+			}
+		}
+	}
+
+	return nil
+}
+
+// fetchLinkPreviews fetches the pending previews at start, when chirps
+// request previews and every interval until ctx is canceled
+func (cfg *apiConfig) fetchLinkPreviews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fetched, err := cfg.fetchPendingLinkPreviews(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "error fetching link previews", "err", err)
+		}
+		if fetched > 0 {
+			slog.InfoContext(ctx, "link previews fetched", "count", fetched)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.previewsRequested:
+		}
+	}
+}
+
+// fetchPendingLinkPreviews fetches every pending preview, in batches of
+// linkPreviewBatch, and returns how many were fetched. A page without
+// a preview is recorded as failed and never fetched again. Replicas
+// may fetch the same preview twice, the last one to finish wins.
+func (cfg *apiConfig) fetchPendingLinkPreviews(ctx context.Context) (int, error) {
+	var fetched int
+
+	for {
+		urls, err := cfg.store.ListPendingLinkPreviews(ctx, linkPreviewBatch)
+		if err != nil {
+			return fetched, err
+		}
+
+		for _, url := range urls {
+			preview := store.LinkPreview{URL: url, Status: store.LinkPreviewReady}
+
+			page, err := cfg.previews.Fetch(ctx, url)
+			if err != nil {
+				if ctx.Err() != nil {
+					return fetched, ctx.Err()
+				}
+
+				slog.InfoContext(ctx, "no link preview", "url", url, "err", err)
+				preview.Status = store.LinkPreviewFailed
+			} else {
+				preview.Title = page.Title
+				preview.Description = page.Description
+				preview.ImageURL = page.ImageURL
+				preview.SiteName = page.SiteName
+				fetched++
+			}
+
+			if err := cfg.store.SaveLinkPreview(ctx, preview); err != nil {
+				return fetched, fmt.Errorf("error saving the preview of %v: %w", url, err)
+			}
+		}
+
+		if len(urls) < linkPreviewBatch {
+			return fetched, nil
+		}
+	}
+}
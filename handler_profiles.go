@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the profile endpoints
+const (
+	codeInvalidHandle          = "invalid_handle"
+	codeInvalidCurrentPassword = "invalid_current_password"
+)
+
+// handlePattern matches the handles users may pick, they cannot be
+// mistaken for a UUID in /api/users/{handleOrID}
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// errCredentialsChanged aborts a patch of the email or password when
+// the password changed since the current password was checked
+var errCredentialsChanged = errors.New("credentials changed concurrently")
+
+// patchUserRequest is a JSON merge patch of the authenticated user,
+// changing the email or password requires the current password
+type patchUserRequest struct {
+	Email           request.Field[string] `json:"email" validate:"email"`
+	Password        request.Field[string] `json:"password"`
+	CurrentPassword string                `json:"current_password"`
+
+	Handle      request.Field[string] `json:"handle"`
+	DisplayName request.Field[string] `json:"display_name" validate:"max=50"`
+	Bio         request.Field[string] `json:"bio" validate:"max=160"`
+	AvatarURL   request.Field[string] `json:"avatar_url" validate:"url,max=2048"`
+	Location    request.Field[string] `json:"location" validate:"max=50"`
+}
+
+func (req patchUserRequest) Validate() []apierror.FieldError {
+	var fields []apierror.FieldError
+
+	if req.Email.Null || req.Email.Set && req.Email.Value == "" {
+		fields = append(fields, apierror.FieldError{Field: "email", Code: request.CodeRequired, Message: "cannot be removed"})
+	}
+	if req.Password.Null || req.Password.Set && req.Password.Value == "" {
+		fields = append(fields, apierror.FieldError{Field: "password", Code: request.CodeRequired, Message: "cannot be removed"})
+	}
+	if req.changesCredentials() && req.CurrentPassword == "" {
+		fields = append(fields, apierror.FieldError{Field: "current_password", Code: request.CodeRequired, Message: "is required to change the email or password"})
+	}
+
+	if req.Handle.Set && !req.Handle.Null && !handlePattern.MatchString(strings.ToLower(req.Handle.Value)) {
+		fields = append(fields, apierror.FieldError{
+			Field:   "handle",
+			Code:    codeInvalidHandle,
+			Message: "must be 3 to 30 letters, digits or underscores",
+		})
+	}
+
+	return fields
+}
+
+func (req patchUserRequest) changesCredentials() bool {
+	return req.Email.Set || req.Password.Set
+}
+
+// handlerPatchUser applies a JSON merge patch (RFC 7386) to the email,
+// password and profile of the authenticated user, members left out are
+// unchanged and null members are removed
+//
+// Returns 400 if JSON decoding or validation fails, or the password
+// breaks the password policy
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the current password is wrong
+// Returns 409 if the email or the handle is used by another user
+// Returns 200 with the updated user on success
+func (cfg *apiConfig) handlerPatchUser() http.Handler {
+	decoder := request.Decoder{MediaTypes: []string{request.MergePatchType, "application/json"}}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var req patchUserRequest
+		if err := decoder.Decode(w, r, &req); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		user, err := cfg.store.GetUserByID(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		// a stolen access token alone cannot take over the account
+		if req.changesCredentials() {
+			match, _, err := cfg.hasher.Check(r.Context(), req.CurrentPassword, user.HashedPassword)
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal(fmt.Errorf("error checking password: %w", err)))
+				return
+			}
+			if !match {
+				apierror.Write(w, r, apierror.Forbidden(codeInvalidCurrentPassword, "the current password is wrong"))
+				return
+			}
+		}
+
+		var newHash string
+		if req.Password.Set {
+			if err := cfg.checkPassword(r.Context(), req.Password.Value); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			newHash, err = cfg.hasher.Hash(r.Context(), req.Password.Value)
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal(fmt.Errorf("error hashing password: %w", err)))
+				return
+			}
+		}
+
+		var updated store.User
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			current, err := tx.GetUserByID(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			if req.changesCredentials() && current.HashedPassword != user.HashedPassword {
+				return errCredentialsChanged
+			}
+
+			req.Email.Apply(&current.Email)
+			req.Handle.Apply(&current.Handle)
+			req.DisplayName.Apply(&current.DisplayName)
+			req.Bio.Apply(&current.Bio)
+			req.AvatarURL.Apply(&current.AvatarURL)
+			req.Location.Apply(&current.Location)
+			current.Handle = strings.ToLower(current.Handle)
+			if newHash != "" {
+				current.HashedPassword = newHash
+			}
+
+			updated, err = tx.UpdateUser(r.Context(), current)
+			return err
+		})
+		if errors.Is(err, errCredentialsChanged) {
+			apierror.Write(w, r, apierror.Forbidden(codeInvalidCurrentPassword, "the password changed, try again with the new one"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newUser(updated))
+	})
+}
+
+// handlerGetProfile returns the public profile of a user, looked up by
+// ID or by handle
+//
+// Returns 404 if no user exists with the given ID or handle, or the
+// user is suspended or pending deletion
+// Returns 200 with the profile on success
+func (cfg *apiConfig) handlerGetProfile() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleOrID := r.PathValue("handleOrID")
+
+		var (
+			user store.User
+			err  error
+		)
+		if id, parseErr := uuid.Parse(handleOrID); parseErr == nil {
+			user, err = cfg.store.GetUserByID(r.Context(), id)
+		} else {
+			user, err = cfg.store.GetUserByHandle(r.Context(), strings.ToLower(handleOrID))
+		}
+		if err == nil && (user.Suspended() || user.Deleted()) {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newProfile(user))
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the block and mute endpoints
+const (
+	codeInvalidUserID  = "invalid_user_id"
+	codeSelfRelation   = "self_relation"
+	codeInvalidReplyTo = "invalid_reply_to"
+)
+
+// relationFunc adds or removes a block or a mute of otherID by userID,
+// such as the BlockUser method of the store
+type relationFunc func(ctx context.Context, userID, otherID uuid.UUID) error
+
+// listRelationFunc lists the users userID blocked or muted, such as
+// the ListBlockedUsers method of the store
+type listRelationFunc func(ctx context.Context, userID uuid.UUID) ([]store.User, error)
+
+// handlerAddRelation blocks or mutes the user of the path for the
+// authenticated user, doing it again is not an error
+//
+// Returns 400 if the user ID is not a UUID or is the authenticated user
+// Returns 401 if the request has no valid JWT
+// Returns 404 if no user exists with the given ID
+// Returns 204 on success
+func (cfg *apiConfig) handlerAddRelation(add relationFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, otherID, err := cfg.parseRelation(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		other, err := cfg.store.GetUserByID(r.Context(), otherID)
+		if err == nil && other.Deleted() {
+			err = store.ErrNotFound
+		}
+		if err == nil {
+			err = add(r.Context(), userID, otherID)
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handlerRemoveRelation unblocks or unmutes the user of the path for
+// the authenticated user, doing it again is not an error
+//
+// Returns 400 if the user ID is not a UUID or is the authenticated user
+// Returns 401 if the request has no valid JWT
+// Returns 204 on success
+func (cfg *apiConfig) handlerRemoveRelation(remove relationFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, otherID, err := cfg.parseRelation(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := remove(r.Context(), userID, otherID); err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handlerListRelations returns the public profiles of the users the
+// authenticated user blocked or muted, oldest first
+//
+// Returns 401 if the request has no valid JWT
+// Returns 200 with the profiles on success
+func (cfg *apiConfig) handlerListRelations(list listRelationFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		users, err := list(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(err))
+			return
+		}
+
+		profiles := make([]Profile, 0, len(users))
+		for _, user := range users {
+			profiles = append(profiles, newProfile(user))
+		}
+
+		writeJSON(w, http.StatusOK, profiles)
+	})
+}
+
+// checkReplyTo checks that userID can reply to the chirp replyToID, if
+// any. GetChirp hides the chirps of the authors who blocked userID, so
+// blocked users cannot reply to the blocker. Chirpy has no mentions
+// yet, blocks have nothing else to reject until it does.
+//
+// Returns a 400 invalid_reply_to error if the chirp does not exist, is
+// deleted or hidden from userID
+func checkReplyTo(ctx context.Context, s store.Store, replyToID, userID uuid.UUID) error {
+	if replyToID == uuid.Nil {
+		return nil
+	}
+
+	_, err := s.GetChirp(ctx, replyToID, userID)
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrDeleted) {
+		return apierror.BadRequest(codeInvalidReplyTo, "the chirp replied to does not exist")
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching the chirp replied to: %w", err)
+	}
+
+	return nil
+}
+
+// parseRelation returns the authenticated user and the user of the
+// path
+//
+// Returns a 401 error without a valid JWT, and a 400 error when the
+// user ID is not a UUID or is the authenticated user
+func (cfg *apiConfig) parseRelation(r *http.Request) (userID, otherID uuid.UUID, err error) {
+	userID, err = cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	otherID, err = uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, apierror.BadRequest(codeInvalidUserID, "the user ID must be a UUID").Wrap(err)
+	}
+
+	if otherID == userID {
+		return uuid.Nil, uuid.Nil, apierror.BadRequest(codeSelfRelation, "users cannot block or mute themselves")
+	}
+
+	return userID, otherID, nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the report and moderation endpoints
+const (
+	codeAlreadyReported     = "already_reported"
+	codeReportOwnChirp      = "own_chirp"
+	codeInvalidReportID     = "invalid_report_id"
+	codeInvalidReportStatus = "invalid_report_status"
+	codeReportNotFound      = "report_not_found"
+	codeReportResolved      = "report_resolved"
+)
+
+// Actions of the audit log, every one but auto_hide is taken by a
+// moderator on a report
+const (
+	actionHideChirp     = "hide_chirp"
+	actionDeleteChirp   = "delete_chirp"
+	actionSuspendAuthor = "suspend_author"
+	actionDismiss       = "dismiss"
+	actionAutoHide      = "auto_hide"
+)
+
+var (
+	// errReportOwnChirp aborts the report of a chirp by its author
+	errReportOwnChirp = errors.New("cannot report own chirp")
+	// errReportNotFound aborts an action on a report that does not exist
+	errReportNotFound = errors.New("report not found")
+	// errReportResolved aborts an action on a report that is not open
+	errReportResolved = errors.New("report already resolved")
+	// errReportedChirpGone aborts an action on a deleted chirp
+	errReportedChirpGone = errors.New("reported chirp deleted")
+)
+
+// Report is a report of a chirp as seen by its reporter and moderators
+type Report struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// ChirpID is null once the chirp is purged, ChirpBody keeps what
+	// was reported
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	AuthorID   uuid.UUID  `json:"author_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	ChirpBody  string     `json:"chirp_body"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// ModerationAction is an entry of the audit log, the moderator is null
+// for the chirps hidden automatically
+type ModerationAction struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	ReportID    *uuid.UUID `json:"report_id"`
+	ChirpID     *uuid.UUID `json:"chirp_id"`
+	UserID      *uuid.UUID `json:"user_id"`
+	Note        string     `json:"note"`
+}
+
+func newReport(r store.Report) Report {
+	return Report{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		ChirpID:    optionalID(r.ChirpID),
+		AuthorID:   r.AuthorID,
+		ReporterID: r.ReporterID,
+		ChirpBody:  r.ChirpBody,
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ResolvedAt: r.ResolvedAt,
+	}
+}
+
+func newModerationAction(a store.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:          a.ID,
+		CreatedAt:   a.CreatedAt,
+		ModeratorID: optionalID(a.ModeratorID),
+		Action:      a.Action,
+		ReportID:    optionalID(a.ReportID),
+		ChirpID:     optionalID(a.ChirpID),
+		UserID:      optionalID(a.UserID),
+		Note:        a.Note,
+	}
+}
+
+// optionalID returns nil for uuid.Nil, so it is written as null
+func optionalID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// handlerReportChirp reports a chirp for review by the moderators. The
+// chirp is hidden, and the action recorded in the audit log, once
+// MODERATION_HIDE_THRESHOLD users reported it.
+//
+// Returns 400 if the chirp ID is not a UUID, the body is invalid or the
+// chirp belongs to the authenticated user
+// Returns 401 if the request has no valid JWT
+// Returns 404 if no chirp the user can see exists with the given ID
+// Returns 409 if the user already reported the chirp
+// Returns 410 if the chirp was deleted
+// Returns 201 with the report on success
+func (cfg *apiConfig) handlerReportChirp() http.Handler {
+	type ReportChirpRequest struct {
+		Reason  string `json:"reason" validate:"required,oneof=spam harassment hate violence sexual self_harm misinformation impersonation other"`
+		Details string `json:"details" validate:"max=1000"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := parseChirpID(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var req ReportChirpRequest
+		if err := request.Decode(w, r, &req); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var report store.Report
+		var hidden bool
+
+		// the report and the count of reporters see the same chirp
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			chirp, err := tx.GetChirp(r.Context(), chirpID, userID)
+			if err != nil {
+				return err
+			}
+			if chirp.UserID == userID {
+				return errReportOwnChirp
+			}
+
+			report, err = tx.CreateReport(r.Context(), store.Report{
+				ChirpID:    chirp.ID,
+				AuthorID:   chirp.UserID,
+				ReporterID: userID,
+				ChirpBody:  chirp.Body,
+				Reason:     req.Reason,
+				Details:    req.Details,
+			})
+			if err != nil {
+				return err
+			}
+
+			reporters, err := tx.CountOpenReports(r.Context(), chirp.ID)
+			if err != nil {
+				return err
+			}
+
+			hidden = chirp.HiddenAt == nil && reporters >= int64(cfg.config.Moderation.HideThreshold)
+			if !hidden {
+				return nil
+			}
+
+			if err := tx.HideChirp(r.Context(), chirp.ID); err != nil {
+				return err
+			}
+
+			_, err = tx.RecordModerationAction(r.Context(), store.ModerationAction{
+				Action:   actionAutoHide,
+				ReportID: report.ID,
+				ChirpID:  chirp.ID,
+				UserID:   chirp.UserID,
+				Note:     fmt.Sprintf("reported by %v users", reporters),
+			})
+			return err
+		})
+		if err != nil {
+			apierror.Write(w, r, reportError(err))
+			return
+		}
+
+		if hidden {
+			slog.InfoContext(r.Context(), "chirp hidden after reports", "chirp_id", chirpID)
+		}
+
+		writeJSON(w, http.StatusCreated, newReport(report))
+	})
+}
+
+// handlerListReports is the moderation queue, the reports with the
+// status of ?status, open by default, oldest first
+//
+// Returns 400 if the status is not open, actioned or dismissed
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the user is not a moderator
+// Returns 200 with the reports on success
+func (cfg *apiConfig) handlerListReports() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := cfg.authenticateModerator(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = store.ReportOpen
+		case store.ReportOpen, store.ReportActioned, store.ReportDismissed:
+		default:
+			apierror.Write(w, r, apierror.BadRequest(codeInvalidReportStatus, "the status must be open, actioned or dismissed"))
+			return
+		}
+
+		reports, err := cfg.store.ListReports(r.Context(), status)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error listing reports: %w", err)))
+			return
+		}
+
+		result := make([]Report, 0, len(reports))
+		for _, report := range reports {
+			result = append(result, newReport(report))
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// handlerModerateReport takes a moderator action on an open report:
+// hide_chirp, delete_chirp, suspend_author, which also revokes the
+// sessions of the author, or dismiss. The decision resolves every
+// open report of the chirp and is recorded in the audit log.
+//
+// Returns 400 if the report ID is not a UUID or the body is invalid
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the user is not a moderator
+// Returns 404 if no report exists with the given ID
+// Returns 409 if the report is already resolved, or the action needs a
+// chirp that was deleted
+// Returns 200 with the resolved report on success
+func (cfg *apiConfig) handlerModerateReport() http.Handler {
+	type ModerateReportRequest struct {
+		Action string `json:"action" validate:"required,oneof=hide_chirp delete_chirp suspend_author dismiss"`
+		Note   string `json:"note" validate:"max=1000"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moderatorID, err := cfg.authenticateModerator(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		reportID, err := uuid.Parse(r.PathValue("reportID"))
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest(codeInvalidReportID, "the report ID must be a UUID").Wrap(err))
+			return
+		}
+
+		var req ModerateReportRequest
+		if err := request.Decode(w, r, &req); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var report store.Report
+
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			var err error
+			report, err = tx.GetReport(r.Context(), reportID)
+			if errors.Is(err, store.ErrNotFound) {
+				return errReportNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			status := store.ReportActioned
+			if req.Action == actionDismiss {
+				status = store.ReportDismissed
+			}
+
+			// only an open report can be acted on, the other open
+			// reports of the chirp get the same decision
+			err = tx.ResolveReports(r.Context(), reportID, status)
+			if errors.Is(err, store.ErrNotFound) {
+				return errReportResolved
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := moderate(r, tx, moderatorID, req.Action, report); err != nil {
+				return err
+			}
+
+			_, err = tx.RecordModerationAction(r.Context(), store.ModerationAction{
+				ModeratorID: moderatorID,
+				Action:      req.Action,
+				ReportID:    report.ID,
+				ChirpID:     report.ChirpID,
+				UserID:      report.AuthorID,
+				Note:        req.Note,
+			})
+			if err != nil {
+				return err
+			}
+
+			report, err = tx.GetReport(r.Context(), reportID)
+			return err
+		})
+		if err != nil {
+			apierror.Write(w, r, reportError(err))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "report resolved", "report_id", reportID, "action", req.Action)
+		writeJSON(w, http.StatusOK, newReport(report))
+	})
+}
+
+// moderate applies the action of a moderator to the chirp or author
+// of report, deleted chirps are kept as evidence until they are purged
+func moderate(r *http.Request, tx store.Store, moderatorID uuid.UUID, action string, report store.Report) error {
+	switch action {
+	case actionHideChirp, actionDeleteChirp:
+		if report.ChirpID == uuid.Nil {
+			return errReportedChirpGone
+		}
+
+		var err error
+		if action == actionHideChirp {
+			err = tx.HideChirp(r.Context(), report.ChirpID)
+		} else {
+			err = tx.DeleteChirp(r.Context(), report.ChirpID, moderatorID)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return errReportedChirpGone
+		}
+		return err
+
+	case actionSuspendAuthor:
+		if err := tx.SuspendUser(r.Context(), report.AuthorID); err != nil {
+			return err
+		}
+		_, err := tx.RevokeUserRefreshTokens(r.Context(), report.AuthorID)
+		return err
+	}
+
+	return nil
+}
+
+// handlerListModerationActions returns the audit log of moderation,
+// newest first
+//
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the user is not a moderator
+// Returns 200 with the actions on success
+func (cfg *apiConfig) handlerListModerationActions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := cfg.authenticateModerator(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		actions, err := cfg.store.ListModerationActions(r.Context())
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error listing moderation actions: %w", err)))
+			return
+		}
+
+		result := make([]ModerationAction, 0, len(actions))
+		for _, action := range actions {
+			result = append(result, newModerationAction(action))
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// reportError maps the errors of reports and moderation to API errors
+func reportError(err error) error {
+	switch {
+	case errors.Is(err, errReportOwnChirp):
+		return apierror.BadRequest(codeReportOwnChirp, "users cannot report their own chirps")
+	case errors.Is(err, store.ErrAlreadyReported):
+		return apierror.Conflict(codeAlreadyReported, "the chirp was already reported by the user")
+	case errors.Is(err, errReportNotFound):
+		return apierror.NotFound(codeReportNotFound, "no report exists with the given ID")
+	case errors.Is(err, errReportResolved):
+		return apierror.Conflict(codeReportResolved, "the report is already resolved")
+	case errors.Is(err, errReportedChirpGone):
+		return apierror.Conflict(codeChirpDeleted, "the reported chirp was deleted")
+	default:
+		return chirpError(err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// Error codes of the scheduled chirp endpoints
+const (
+	codeInvalidPublishAt       = "invalid_publish_at"
+	codeScheduledChirpNotFound = "scheduled_chirp_not_found"
+)
+
+// scheduledChirpBatch is the number of due chirps published in a
+// single transaction
+const scheduledChirpBatch = 100
+
+// ScheduledChirp is a chirp waiting to be published, it keeps its ID
+// once published
+type ScheduledChirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+func newScheduledChirp(s store.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Body:      s.Body,
+		UserID:    s.UserID,
+		PublishAt: s.PublishAt,
+	}
+}
+
+// handlerListScheduledChirps returns the chirps the authenticated user
+// scheduled and are not published yet, the next to be published first
+//
+// Returns 401 if the request has no valid JWT
+// Returns 200 with the scheduled chirps on success
+func (cfg *apiConfig) handlerListScheduledChirps() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		scheduled, err := cfg.store.ListScheduledChirps(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error listing scheduled chirps: %w", err)))
+			return
+		}
+
+		result := make([]ScheduledChirp, 0, len(scheduled))
+		for _, chirp := range scheduled {
+			result = append(result, newScheduledChirp(chirp))
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// handlerCancelScheduledChirp deletes a chirp the authenticated user
+// scheduled before it is published
+//
+// Returns 400 if the chirp ID cannot be parsed as UUID
+// Returns 401 if the request has no valid JWT
+// Returns 404 if the user has no chirp scheduled with the given ID,
+// which includes the ones already published
+// Returns 204 on success
+func (cfg *apiConfig) handlerCancelScheduledChirp() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, err := parseChirpID(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		err = cfg.store.CancelScheduledChirp(r.Context(), chirpID, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.NotFound(codeScheduledChirpNotFound, "no chirp is scheduled with the given ID"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error canceling the scheduled chirp: %w", err)))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "scheduled chirp canceled", "chirp_id", chirpID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// publishScheduledChirps publishes the due chirps at start, catching
+// up on the ones due while the server was down, and then every
+// interval until ctx is canceled
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := cfg.publishDueChirps(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "error publishing scheduled chirps", "err", err)
+		}
+		if published > 0 {
+			slog.InfoContext(ctx, "scheduled chirps published", "count", published)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirps publishes every chirp due now, in batches of
+// scheduledChirpBatch. Each batch is claimed and published in one
+// transaction, so a chirp is published exactly once even with several
+// replicas, and stays scheduled when publishing fails. The replicas
+// claim disjoint batches, so the transaction is a ClaimTx.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) (int, error) {
+	var published int
+
+	for {
+		var claimed []store.Chirp
+
+		err := cfg.store.ClaimTx(ctx, func(tx store.Store) error {
+			due, err := tx.ClaimDueChirps(ctx, scheduledChirpBatch)
+			if err != nil {
+				return err
+			}
+
+			claimed = make([]store.Chirp, 0, len(due))
+			for _, scheduled := range due {
+				chirp, err := tx.PublishScheduledChirp(ctx, scheduled)
+				if err != nil {
+					return fmt.Errorf("error publishing chirp %v: %w", scheduled.ID, err)
+				}
+				claimed = append(claimed, chirp)
+			}
+			return nil
+		})
+		if err != nil {
+			return published, err
+		}
+
+		published += len(claimed)
+		for range claimed {
+			cfg.metrics.ChirpCreated()
+		}
+		cfg.requestLinkPreviews(ctx, claimed...)
+
+		if len(claimed) < scheduledChirpBatch {
+			return published, nil
+		}
+	}
+}
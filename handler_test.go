@@ -0,0 +1,1572 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/auth"
+	"github.com/luis-octavius/chirpy/internal/chirptext"
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/health"
+	"github.com/luis-octavius/chirpy/internal/linkpreview"
+	"github.com/luis-octavius/chirpy/internal/metrics"
+	"github.com/luis-octavius/chirpy/internal/migrate"
+	"github.com/luis-octavius/chirpy/internal/password"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testAPI runs the whole HTTP API against the in-memory store
+type testAPI struct {
+	t      *testing.T
+	server *httptest.Server
+	store  store.Store
+	cfg    *apiConfig
+}
+
+// newTestAPI starts the API with the default configuration, changed
+// by options
+func newTestAPI(t *testing.T, options ...func(*config.Config)) *testAPI {
+	cfg := config.Default()
+	cfg.PolkaKey = "polka-test-key"
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	apiCfg := &apiConfig{
+		store:     store.NewMemory(),
+		keys:      auth.NewKeyring("a-test-secret-that-is-long-enough!"),
+		hasher:    auth.NewHasher(testArgon2Params, 4),
+		passwords: password.Policy{MinLength: cfg.Password.MinLength, MaxLength: cfg.Password.MaxLength},
+		metrics:   metrics.New(nil),
+		health:    health.NewRegistry(time.Second),
+		config:    cfg,
+	}
+
+	server := httptest.NewServer(apiCfg.routes())
+	t.Cleanup(server.Close)
+
+	return &testAPI{t: t, server: server, store: apiCfg.store, cfg: apiCfg}
+}
+
+// testArgon2Params keep hashing cheap in tests
+var testArgon2Params = argon2id.Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// do sends body as JSON with an optional Bearer token and decodes the
+// JSON response into out when it is not nil
+func (api *testAPI) do(method, path, token string, body, out any) int {
+	api.t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			api.t.Fatalf("error encoding request body: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, api.server.URL+path, &reader)
+	if err != nil {
+		api.t.Fatalf("error building request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		api.t.Fatalf("%v %v returned error: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			api.t.Fatalf("error decoding %v %v response: %v", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+// download sends an authenticated GET and returns the response with
+// its body read
+func (api *testAPI) download(path, token string) (*http.Response, []byte) {
+	api.t.Helper()
+
+	req, err := http.NewRequest("GET", api.server.URL+path, nil)
+	if err != nil {
+		api.t.Fatalf("error building request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		api.t.Fatalf("GET %v returned error: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		api.t.Fatalf("error reading GET %v response: %v", path, err)
+	}
+
+	return resp, body
+}
+
+func (api *testAPI) signup(email, password string) User {
+	api.t.Helper()
+
+	credentials := map[string]string{"email": email, "password": password}
+
+	if status := api.do("POST", "/api/users", "", credentials, nil); status != http.StatusCreated {
+		api.t.Fatalf("expected %v creating user, got %v", http.StatusCreated, status)
+	}
+
+	var user User
+	if status := api.do("POST", "/api/login", "", credentials, &user); status != http.StatusOK {
+		api.t.Fatalf("expected %v logging in, got %v", http.StatusOK, status)
+	}
+
+	return user
+}
+
+func TestUsersAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	if walt.Token == "" || walt.RefreshToken == "" {
+		t.Fatalf("expected login to return tokens, got %+v", walt)
+	}
+
+	duplicate := map[string]string{"email": "walt@example.com", "password": "another-secret"}
+	if status := api.do("POST", "/api/users", "", duplicate, nil); status != http.StatusConflict {
+		t.Errorf("expected %v for a duplicate email, got %v", http.StatusConflict, status)
+	}
+
+	wrong := map[string]string{"email": "walt@example.com", "password": "wrong"}
+	if status := api.do("POST", "/api/login", "", wrong, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v for a wrong password, got %v", http.StatusUnauthorized, status)
+	}
+
+	// the access token alone cannot change the credentials
+	var problem apierror.Problem
+	update := map[string]string{"email": "heisenberg@example.com", "password": "blue-crystal", "current_password": "wrong"}
+	if status := api.do("PUT", "/api/users", walt.Token, update, &problem); status != http.StatusForbidden || problem.Code != codeInvalidCurrentPassword {
+		t.Errorf("expected %v with a wrong current password, got %v %v", codeInvalidCurrentPassword, status, problem.Code)
+	}
+	delete(update, "current_password")
+	if status := api.do("PUT", "/api/users", walt.Token, update, nil); status != http.StatusBadRequest {
+		t.Errorf("expected %v without the current password, got %v", http.StatusBadRequest, status)
+	}
+
+	var updated User
+	update["current_password"] = "say-my-name"
+	if status := api.do("PUT", "/api/users", walt.Token, update, &updated); status != http.StatusOK {
+		t.Fatalf("expected %v updating user, got %v", http.StatusOK, status)
+	}
+	if updated.Email != "heisenberg@example.com" {
+		t.Errorf("expected %v, got %v", "heisenberg@example.com", updated.Email)
+	}
+
+	webhook := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": walt.ID.String()}}
+	req, _ := http.NewRequest("POST", api.server.URL+"/api/polka/webhooks", bytes.NewBufferString(mustJSON(t, webhook)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "ApiKey polka-test-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("webhook returned error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected %v, got %v", http.StatusNoContent, resp.StatusCode)
+	}
+
+	var upgraded User
+	login := map[string]string{"email": "heisenberg@example.com", "password": "blue-crystal"}
+	if status := api.do("POST", "/api/login", "", login, &upgraded); status != http.StatusOK {
+		t.Fatalf("expected %v logging in with the new credentials, got %v", http.StatusOK, status)
+	}
+	if !upgraded.IsChirpyRed {
+		t.Errorf("expected user to be Chirpy Red after the webhook")
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("say-my-name"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error hashing with bcrypt: %v", err)
+	}
+	outdated, err := auth.NewHasher(argon2id.Params{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, 1).Hash(ctx, "yeah-science")
+	if err != nil {
+		t.Fatalf("error hashing with outdated parameters: %v", err)
+	}
+
+	cases := map[string]struct {
+		email    string
+		password string
+		hash     string
+	}{
+		"bcrypt":            {"walt@example.com", "say-my-name", string(bcryptHash)},
+		"outdated argon2id": {"jesse@example.com", "yeah-science", outdated},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			user, err := api.store.CreateUser(ctx, c.email, c.hash)
+			if err != nil {
+				t.Fatalf("CreateUser returned error: %v", err)
+			}
+
+			login := map[string]string{"email": c.email, "password": c.password}
+			if status := api.do("POST", "/api/login", "", login, nil); status != http.StatusOK {
+				t.Fatalf("expected %v logging in, got %v", http.StatusOK, status)
+			}
+
+			user, err = api.store.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID returned error: %v", err)
+			}
+			_, params, err := argon2id.CheckHash(c.password, user.HashedPassword)
+			if err != nil {
+				t.Fatalf("expected an argon2id hash, got %v: %v", user.HashedPassword, err)
+			}
+			if *params != testArgon2Params {
+				t.Errorf("expected %+v, got %+v", testArgon2Params, *params)
+			}
+
+			// the new hash still logs in
+			if status := api.do("POST", "/api/login", "", login, nil); status != http.StatusOK {
+				t.Errorf("expected %v logging in after the rehash, got %v", http.StatusOK, status)
+			}
+		})
+	}
+}
+
+func TestChirpsAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var chirp Chirp
+	status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "I am the kerfuffle"}, &chirp)
+	if status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	if chirp.Body != "I am the ****" || chirp.UserID != walt.ID {
+		t.Errorf("unexpected chirp %+v", chirp)
+	}
+
+	if status := api.do("POST", "/api/chirps", "", map[string]string{"body": "anonymous"}, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v without a token, got %v", http.StatusUnauthorized, status)
+	}
+
+	api.do("POST", "/api/chirps", jesse.Token, map[string]string{"body": "yeah science"}, nil)
+
+	var chirps []Chirp
+	if status := api.do("GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps); status != http.StatusOK {
+		t.Fatalf("expected %v listing chirps, got %v", http.StatusOK, status)
+	}
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("expected only the chirp of walt, got %+v", chirps)
+	}
+
+	if status := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), jesse.Token, nil, nil); status != http.StatusForbidden {
+		t.Errorf("expected %v deleting another user's chirp, got %v", http.StatusForbidden, status)
+	}
+
+	if status := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected %v deleting own chirp, got %v", http.StatusNoContent, status)
+	}
+
+	var problem apierror.Problem
+	if status := api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, &problem); status != http.StatusGone || problem.Code != codeChirpDeleted {
+		t.Errorf("expected %v for a deleted chirp, got %v %v", http.StatusGone, status, problem.Code)
+	}
+
+	if status := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil); status != http.StatusGone {
+		t.Errorf("expected %v deleting a deleted chirp, got %v", http.StatusGone, status)
+	}
+
+	if status := api.do("GET", "/api/chirps?author_id="+walt.ID.String(), "", nil, &chirps); status != http.StatusOK || len(chirps) != 0 {
+		t.Errorf("expected no chirps of walt, got %v %+v", status, chirps)
+	}
+}
+
+func TestChirpsAPI_Replies(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var replyTo Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &replyTo); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+
+	// replies and attachments are checked like those of drafts
+	attachments := []string{"https://example.com/blue.png"}
+	var reply Chirp
+	body := map[string]any{"body": "yeah science", "reply_to_id": replyTo.ID, "attachments": attachments}
+	if status := api.do("POST", "/api/chirps", jesse.Token, body, &reply); status != http.StatusCreated {
+		t.Fatalf("expected %v creating reply, got %v", http.StatusCreated, status)
+	}
+	if reply.ReplyToID == nil || *reply.ReplyToID != replyTo.ID || !slices.Equal(reply.Attachments, attachments) {
+		t.Errorf("expected the reply target and attachments, got %+v", reply)
+	}
+	var got Chirp
+	if status := api.do("GET", "/api/chirps/"+reply.ID.String(), "", nil, &got); status != http.StatusOK || got.ReplyToID == nil || *got.ReplyToID != replyTo.ID || !slices.Equal(got.Attachments, attachments) {
+		t.Errorf("expected the stored reply target and attachments, got %v %+v", status, got)
+	}
+
+	publishAt := time.Now().Add(time.Hour)
+	cases := []struct {
+		name       string
+		body       any
+		expectCode string
+	}{
+		{"reply to a missing chirp", map[string]any{"body": "yeah science", "reply_to_id": uuid.New()}, codeInvalidReplyTo},
+		{"invalid attachment", map[string]any{"body": "yeah science", "attachments": []string{"javascript:alert(1)"}}, apierror.CodeValidationFailed},
+		{"too many attachments", map[string]any{"body": "yeah science", "attachments": strings.Split("https://a.io https://b.io https://c.io https://d.io https://e.io", " ")}, apierror.CodeValidationFailed},
+		{"scheduled reply", map[string]any{"body": "yeah science", "reply_to_id": replyTo.ID, "publish_at": publishAt}, codeInvalidPublishAt},
+		{"scheduled attachments", map[string]any{"body": "yeah science", "attachments": attachments, "publish_at": publishAt}, codeInvalidPublishAt},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do("POST", "/api/chirps", jesse.Token, c.body, &problem); status != http.StatusBadRequest {
+				t.Errorf("expected %v, got %v", http.StatusBadRequest, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+}
+
+func TestChirpsAPI_SuspendedAuthor(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var draft Draft
+	if status := api.do("POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": "say my name"}, &draft); status != http.StatusCreated {
+		t.Fatalf("expected %v creating draft, got %v", http.StatusCreated, status)
+	}
+
+	// the access tokens outlive the suspension and the deletion
+	if err := api.store.SuspendUser(context.Background(), walt.ID); err != nil {
+		t.Fatalf("SuspendUser returned error: %v", err)
+	}
+	if _, err := api.store.SoftDeleteUser(context.Background(), jesse.ID); err != nil {
+		t.Fatalf("SoftDeleteUser returned error: %v", err)
+	}
+
+	chirp := map[string]any{"body": "say my name"}
+	scheduled := map[string]any{"body": "say my name", "publish_at": time.Now().Add(time.Hour)}
+
+	cases := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		expectCode string
+	}{
+		{"suspended chirp", "/api/chirps", walt.Token, chirp, codeAccountSuspended},
+		{"suspended scheduled chirp", "/api/chirps", walt.Token, scheduled, codeAccountSuspended},
+		{"suspended draft publish", "/api/users/me/drafts/" + draft.ID.String() + "/publish", walt.Token, nil, codeAccountSuspended},
+		{"deleted chirp", "/api/chirps", jesse.Token, chirp, codeAccountDeleted},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do("POST", c.path, c.token, c.body, &problem); status != http.StatusForbidden {
+				t.Errorf("expected %v, got %v", http.StatusForbidden, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+
+	if chirps, err := api.store.ListChirps(context.Background(), uuid.Nil); err != nil || len(chirps) != 0 {
+		t.Errorf("expected no chirps, got %+v (err %v)", chirps, err)
+	}
+}
+
+func TestRestoreChirpAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+	gus := api.signup("gus@example.com", "los-pollos")
+
+	if err := api.store.SetModerator(context.Background(), gus.ID, true); err != nil {
+		t.Fatalf("SetModerator returned error: %v", err)
+	}
+
+	var chirp Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+
+	if status := api.do("DELETE", chirpPath, walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected %v deleting own chirp, got %v", http.StatusNoContent, status)
+	}
+
+	var problem apierror.Problem
+	if status := api.do("POST", chirpPath+"/restore", jesse.Token, nil, &problem); status != http.StatusNotFound || problem.Code != codeChirpNotFound {
+		t.Errorf("expected %v restoring another user's chirp, got %v %v", http.StatusNotFound, status, problem.Code)
+	}
+
+	var restored Chirp
+	if status := api.do("POST", chirpPath+"/restore", walt.Token, nil, &restored); status != http.StatusOK || restored.ID != chirp.ID {
+		t.Fatalf("expected %v restoring own chirp, got %v %+v", http.StatusOK, status, restored)
+	}
+	if status := api.do("GET", chirpPath, "", nil, nil); status != http.StatusOK {
+		t.Errorf("expected %v for a restored chirp, got %v", http.StatusOK, status)
+	}
+
+	// the author cannot undo the decision of a moderator
+	var report Report
+	if status := api.do("POST", chirpPath+"/reports", jesse.Token, map[string]string{"reason": "spam"}, &report); status != http.StatusCreated {
+		t.Fatalf("expected %v reporting, got %v", http.StatusCreated, status)
+	}
+	if status := api.do("POST", "/admin/reports/"+report.ID.String()+"/actions", gus.Token, map[string]string{"action": "delete_chirp"}, nil); status != http.StatusOK {
+		t.Fatalf("expected %v moderating, got %v", http.StatusOK, status)
+	}
+	if status := api.do("POST", chirpPath+"/restore", walt.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v restoring a chirp deleted by a moderator, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("POST", chirpPath+"/reports", gus.Token, map[string]string{"reason": "spam"}, nil); status != http.StatusGone {
+		t.Errorf("expected %v reporting a deleted chirp, got %v", http.StatusGone, status)
+	}
+}
+
+func TestRestoreChirpAPI_UndoWindow(t *testing.T) {
+	api := newTestAPI(t, func(c *config.Config) { c.Chirps.UndoWindow = 0 })
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	var chirp Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	if status := api.do("DELETE", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected %v deleting own chirp, got %v", http.StatusNoContent, status)
+	}
+
+	if status := api.do("POST", "/api/chirps/"+chirp.ID.String()+"/restore", walt.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v after the undo window, got %v", http.StatusNotFound, status)
+	}
+}
+
+func TestChirpLengthAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	gus := api.signup("gus@example.com", "los-pollos")
+
+	if err := api.store.UpgradeUser(context.Background(), gus.ID); err != nil {
+		t.Fatalf("UpgradeUser returned error: %v", err)
+	}
+
+	url := "https://example.com/" + strings.Repeat("a", 200)
+	cases := []struct {
+		name         string
+		token        string
+		body         string
+		expectStatus int
+	}{
+		{"140 accented characters", walt.Token, strings.Repeat("ã", 140), http.StatusCreated},
+		{"141 accented characters", walt.Token, strings.Repeat("ã", 141), http.StatusBadRequest},
+		{"140 emoji", walt.Token, strings.Repeat("👍🏽", 140), http.StatusCreated},
+		{"long URL", walt.Token, strings.Repeat("a", 116) + " " + url, http.StatusCreated},
+		{"text past the URL weight", walt.Token, strings.Repeat("a", 117) + " " + url, http.StatusBadRequest},
+		{"chirpy red", gus.Token, strings.Repeat("a", 280), http.StatusCreated},
+		{"past chirpy red limit", gus.Token, strings.Repeat("a", 281), http.StatusBadRequest},
+		// URLs weigh URLLength and combining marks nothing, so the size
+		// is capped apart from the length
+		{"URL past the URL limit", walt.Token, "see https://example.com/" + strings.Repeat("a", chirptext.MaxURLLength), http.StatusBadRequest},
+		{"body past the byte limit", walt.Token, strings.Repeat("a"+strings.Repeat("\u0301", 200), 100), http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			status := api.do("POST", "/api/chirps", c.token, map[string]string{"body": c.body}, &problem)
+			if status != c.expectStatus {
+				t.Fatalf("expected %v, got %v", c.expectStatus, status)
+			}
+			if status == http.StatusBadRequest && (len(problem.Errors) != 1 || problem.Errors[0].Field != "body" || problem.Errors[0].Code != request.CodeTooLong) {
+				t.Errorf("expected a %v error on the body, got %+v", request.CodeTooLong, problem.Errors)
+			}
+		})
+	}
+
+	// bodies are stored in NFC
+	var chirp Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "voce\u0302"}, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	if chirp.Body != "voc\u00ea" {
+		t.Errorf("expected the composed body, got %q", chirp.Body)
+	}
+
+	var clientConfig ClientConfig
+	if status := api.do("GET", "/api/config", "", nil, &clientConfig); status != http.StatusOK {
+		t.Fatalf("expected %v fetching the config, got %v", http.StatusOK, status)
+	}
+	if clientConfig.Chirps.MaxLength != 140 || clientConfig.Chirps.MaxLengthChirpyRed != 280 || clientConfig.Chirps.URLLength != 23 ||
+		clientConfig.Chirps.MaxBodyBytes != 16<<10 || clientConfig.Chirps.MaxURLLength != chirptext.MaxURLLength {
+		t.Errorf("unexpected chirp limits %+v", clientConfig.Chirps)
+	}
+}
+
+func TestScheduledChirpsAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var problem apierror.Problem
+	past := map[string]any{"body": "too late", "publish_at": time.Now().Add(-time.Minute)}
+	if status := api.do("POST", "/api/chirps", walt.Token, past, &problem); status != http.StatusBadRequest || problem.Code != codeInvalidPublishAt {
+		t.Errorf("expected %v scheduling in the past, got %v %v", codeInvalidPublishAt, status, problem.Code)
+	}
+
+	var later ScheduledChirp
+	publishAt := time.Now().Add(time.Hour).In(time.FixedZone("ABQ", -6*60*60))
+	body := map[string]any{"body": "I am the kerfuffle", "publish_at": publishAt}
+	if status := api.do("POST", "/api/chirps", walt.Token, body, &later); status != http.StatusCreated {
+		t.Fatalf("expected %v scheduling a chirp, got %v", http.StatusCreated, status)
+	}
+	if later.Body != "I am the ****" || later.UserID != walt.ID || !later.PublishAt.Equal(publishAt) {
+		t.Errorf("unexpected scheduled chirp %+v", later)
+	}
+
+	var chirps []Chirp
+	if status := api.do("GET", "/api/chirps", "", nil, &chirps); status != http.StatusOK || len(chirps) != 0 {
+		t.Errorf("expected no published chirps, got %v %+v", status, chirps)
+	}
+	if status := api.do("GET", "/api/chirps/"+later.ID.String(), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v for a scheduled chirp, got %v", http.StatusNotFound, status)
+	}
+
+	// the due chirp is published with its ID by the scheduler
+	due, err := api.store.ScheduleChirp(context.Background(), walt.ID, "say my name", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+
+	var scheduled []ScheduledChirp
+	if status := api.do("GET", "/api/users/me/scheduled-chirps", walt.Token, nil, &scheduled); status != http.StatusOK {
+		t.Fatalf("expected %v listing scheduled chirps, got %v", http.StatusOK, status)
+	}
+	if len(scheduled) != 2 || scheduled[0].ID != due.ID || scheduled[1].ID != later.ID {
+		t.Errorf("expected the due chirp before the later one, got %+v", scheduled)
+	}
+
+	if published, err := api.cfg.publishDueChirps(context.Background()); err != nil || published != 1 {
+		t.Fatalf("expected 1 published chirp, got %v (err %v)", published, err)
+	}
+	if status := api.do("GET", "/api/chirps", "", nil, &chirps); status != http.StatusOK || len(chirps) != 1 || chirps[0].ID != due.ID {
+		t.Errorf("expected the published chirp, got %v %+v", status, chirps)
+	}
+
+	if status := api.do("DELETE", "/api/users/me/scheduled-chirps/"+later.ID.String(), jesse.Token, nil, &problem); status != http.StatusNotFound || problem.Code != codeScheduledChirpNotFound {
+		t.Errorf("expected %v canceling another user's chirp, got %v %v", codeScheduledChirpNotFound, status, problem.Code)
+	}
+	if status := api.do("DELETE", "/api/users/me/scheduled-chirps/"+due.ID.String(), walt.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v canceling a published chirp, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("DELETE", "/api/users/me/scheduled-chirps/"+later.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected %v canceling a scheduled chirp, got %v", http.StatusNoContent, status)
+	}
+	if status := api.do("GET", "/api/users/me/scheduled-chirps", walt.Token, nil, &scheduled); status != http.StatusOK || len(scheduled) != 0 {
+		t.Errorf("expected no scheduled chirps, got %v %+v", status, scheduled)
+	}
+}
+
+func TestScheduledChirpsAPI_SuspendedAuthor(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	if _, err := api.store.ScheduleChirp(context.Background(), walt.ID, "say my name", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+
+	// a chirp scheduled before the suspension is not published after it
+	if err := api.store.SuspendUser(context.Background(), walt.ID); err != nil {
+		t.Fatalf("SuspendUser returned error: %v", err)
+	}
+	if published, err := api.cfg.publishDueChirps(context.Background()); err != nil || published != 0 {
+		t.Fatalf("expected no published chirp, got %v (err %v)", published, err)
+	}
+	var chirps []Chirp
+	if status := api.do("GET", "/api/chirps", "", nil, &chirps); status != http.StatusOK || len(chirps) != 0 {
+		t.Errorf("expected no chirps, got %v %+v", status, chirps)
+	}
+}
+
+func TestDraftsAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var draft Draft
+	if status := api.do("POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": ""}, &draft); status != http.StatusCreated {
+		t.Fatalf("expected %v creating an empty draft, got %v", http.StatusCreated, status)
+	}
+	draftPath := "/api/users/me/drafts/" + draft.ID.String()
+
+	var long Draft
+	if status := api.do("POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": strings.Repeat("a", 141)}, &long); status != http.StatusCreated {
+		t.Fatalf("expected %v creating a long draft, got %v", http.StatusCreated, status)
+	}
+
+	// drafts are measured in grapheme clusters like chirps
+	accents := map[string]string{"body": strings.Repeat("e\u0301", config.MaxDraftLength)}
+	if status := api.do("POST", "/api/users/me/drafts", walt.Token, accents, nil); status != http.StatusCreated {
+		t.Errorf("expected %v for %v combined characters, got %v", http.StatusCreated, config.MaxDraftLength, status)
+	}
+
+	var replyTo Chirp
+	if status := api.do("POST", "/api/chirps", jesse.Token, map[string]string{"body": "yeah science"}, &replyTo); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+
+	cases := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		body         any
+		expectStatus int
+		expectCode   string
+	}{
+		{"invalid draft ID", "GET", "/api/users/me/drafts/heisenberg", walt.Token, nil, http.StatusBadRequest, codeInvalidDraftID},
+		{"missing token", "GET", draftPath, "", nil, http.StatusUnauthorized, apierror.CodeMissingToken},
+		{"another user's draft", "GET", draftPath, jesse.Token, nil, http.StatusNotFound, codeDraftNotFound},
+		{"update another user's draft", "PUT", draftPath, jesse.Token, map[string]string{"body": "yeah science"}, http.StatusNotFound, codeDraftNotFound},
+		{"publish another user's draft", "POST", draftPath + "/publish", jesse.Token, nil, http.StatusNotFound, codeDraftNotFound},
+		{"publish empty draft", "POST", draftPath + "/publish", walt.Token, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"publish long draft", "POST", "/api/users/me/drafts/" + long.ID.String() + "/publish", walt.Token, nil, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"draft too long", "POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": strings.Repeat("a", config.MaxDraftLength+1)}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"draft URL too long", "POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": "https://example.com/" + strings.Repeat("a", chirptext.MaxURLLength)}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"reply to a missing chirp", "POST", "/api/users/me/drafts", walt.Token, map[string]any{"reply_to_id": uuid.New()}, http.StatusBadRequest, codeInvalidReplyTo},
+		{"invalid attachment", "POST", "/api/users/me/drafts", walt.Token, map[string]any{"attachments": []string{"javascript:alert(1)"}}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"too many attachments", "POST", "/api/users/me/drafts", walt.Token, map[string]any{"attachments": strings.Split("https://a.io https://b.io https://c.io https://d.io https://e.io", " ")}, http.StatusBadRequest, apierror.CodeValidationFailed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do(c.method, c.path, c.token, c.body, &problem); status != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+
+	attachments := []string{"https://example.com/blue.png"}
+	update := map[string]any{"body": "I am the kerfuffle", "reply_to_id": replyTo.ID, "attachments": attachments}
+	if status := api.do("PUT", draftPath, walt.Token, update, &draft); status != http.StatusOK || draft.Body != "I am the kerfuffle" {
+		t.Fatalf("expected %v updating the draft, got %v %+v", http.StatusOK, status, draft)
+	}
+	if draft.ReplyToID == nil || *draft.ReplyToID != replyTo.ID || !slices.Equal(draft.Attachments, attachments) {
+		t.Errorf("expected the reply target and attachments, got %+v", draft)
+	}
+
+	var drafts []Draft
+	if status := api.do("GET", "/api/users/me/drafts", walt.Token, nil, &drafts); status != http.StatusOK || len(drafts) != 3 || drafts[0].ID != draft.ID {
+		t.Errorf("expected the updated draft first, got %v %+v", status, drafts)
+	}
+
+	// publishing filters the body like posting a chirp
+	var chirp Chirp
+	if status := api.do("POST", draftPath+"/publish", walt.Token, nil, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v publishing the draft, got %v", http.StatusCreated, status)
+	}
+	if chirp.Body != "I am the ****" || chirp.UserID != walt.ID {
+		t.Errorf("unexpected chirp %+v", chirp)
+	}
+	if chirp.ReplyToID == nil || *chirp.ReplyToID != replyTo.ID || !slices.Equal(chirp.Attachments, attachments) {
+		t.Errorf("expected the chirp to keep the reply target and attachments, got %+v", chirp)
+	}
+	if status := api.do("GET", draftPath, walt.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected the published draft to be %v, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("POST", draftPath+"/publish", walt.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v publishing twice, got %v", http.StatusNotFound, status)
+	}
+
+	var chirps []Chirp
+	if status := api.do("GET", "/api/chirps", "", nil, &chirps); status != http.StatusOK || len(chirps) != 2 || chirps[1].ID != chirp.ID {
+		t.Errorf("expected the published chirp, got %v %+v", status, chirps)
+	}
+
+	// the draft that failed to publish is kept
+	if status := api.do("GET", "/api/users/me/drafts", walt.Token, nil, &drafts); status != http.StatusOK || len(drafts) != 2 || !slices.ContainsFunc(drafts, func(d Draft) bool { return d.ID == long.ID }) {
+		t.Errorf("expected the long draft to be kept, got %v %+v", status, drafts)
+	}
+	if status := api.do("DELETE", "/api/users/me/drafts/"+long.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected %v deleting the draft, got %v", http.StatusNoContent, status)
+	}
+}
+
+func TestLinkPreviewsAPI(t *testing.T) {
+	var fetches atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/menu" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<html><head>
+<meta property="og:title" content="Los Pollos Hermanos">
+<meta property="og:description" content="The chicken brothers">
+<meta property="og:image" content="/logo.png">
+</head><body></body></html>`)
+	}))
+	t.Cleanup(site.Close)
+
+	api := newTestAPI(t)
+	// the site runs on loopback, which the fetcher refuses by default
+	api.cfg.previews = linkpreview.NewHTTPFetcher(linkpreview.Options{
+		Timeout:   time.Second,
+		MaxBytes:  32 << 10,
+		AllowAddr: func(netip.Addr) bool { return true },
+	})
+	gus := api.signup("gus@example.com", "los-pollos")
+
+	menu := site.URL + "/menu"
+	var chirp Chirp
+	body := map[string]string{"body": "try our chicken " + menu + ", or not " + site.URL + "/missing"}
+	if status := api.do("POST", "/api/chirps", gus.Token, body, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v, got %v", http.StatusCreated, status)
+	}
+	if len(chirp.Previews) != 0 {
+		t.Errorf("expected previews to be fetched in the background, got %+v", chirp.Previews)
+	}
+
+	fetched, err := api.cfg.fetchPendingLinkPreviews(context.Background())
+	if err != nil || fetched != 1 {
+		t.Fatalf("expected 1 preview fetched, got %v (err %v)", fetched, err)
+	}
+
+	expect := LinkPreview{
+		URL:         menu,
+		Title:       "Los Pollos Hermanos",
+		Description: "The chicken brothers",
+		ImageURL:    site.URL + "/logo.png",
+	}
+
+	var got Chirp
+	if status := api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, &got); status != http.StatusOK {
+		t.Fatalf("expected %v, got %v", http.StatusOK, status)
+	}
+	if len(got.Previews) != 1 || got.Previews[0] != expect {
+		t.Errorf("expected only the preview of %v, got %+v", menu, got.Previews)
+	}
+
+	// the cached preview is shared with the next chirps linking to it
+	var again Chirp
+	if status := api.do("POST", "/api/chirps", gus.Token, map[string]string{"body": "still " + menu}, &again); status != http.StatusCreated {
+		t.Fatalf("expected %v, got %v", http.StatusCreated, status)
+	}
+	if _, err := api.cfg.fetchPendingLinkPreviews(context.Background()); err != nil {
+		t.Fatalf("fetchPendingLinkPreviews returned error: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected each URL to be fetched once, got %v fetches", n)
+	}
+
+	var chirps []Chirp
+	if status := api.do("GET", "/api/chirps?sort=asc", "", nil, &chirps); status != http.StatusOK {
+		t.Fatalf("expected %v, got %v", http.StatusOK, status)
+	}
+	if len(chirps) != 2 || len(chirps[0].Previews) != 1 || len(chirps[1].Previews) != 1 || chirps[1].Previews[0] != expect {
+		t.Errorf("expected both chirps with the preview, got %+v", chirps)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	cases := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		body         any
+		expectStatus int
+		expectCode   string
+	}{
+		{"invalid author_id", "GET", "/api/chirps?author_id=heisenberg", "", nil, http.StatusBadRequest, codeInvalidAuthorID},
+		{"invalid chirp ID", "GET", "/api/chirps/heisenberg", "", nil, http.StatusBadRequest, codeInvalidChirpID},
+		{"missing chirp", "DELETE", "/api/chirps/" + uuid.NewString(), walt.Token, nil, http.StatusNotFound, codeChirpNotFound},
+		{"missing token", "POST", "/api/chirps", "", map[string]string{"body": "hi"}, http.StatusUnauthorized, apierror.CodeMissingToken},
+		{"invalid token", "POST", "/api/chirps", "not-a-jwt", map[string]string{"body": "hi"}, http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"chirp too long", "POST", "/api/chirps", walt.Token, map[string]string{"body": strings.Repeat("a", 141)}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"duplicate email", "POST", "/api/users", "", map[string]string{"email": "walt@example.com", "password": "another-secret"}, http.StatusConflict, codeEmailTaken},
+		{"wrong password", "POST", "/api/login", "", map[string]string{"email": "walt@example.com", "password": "x"}, http.StatusUnauthorized, codeInvalidCredentials},
+		{"empty signup", "POST", "/api/users", "", map[string]string{"email": "", "password": ""}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"short password", "POST", "/api/users", "", map[string]string{"email": "jesse@example.com", "password": "science"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"common password", "POST", "/api/users", "", map[string]string{"email": "jesse@example.com", "password": "Password123"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"empty new password", "PUT", "/api/users", walt.Token, map[string]string{"email": "walt@example.com"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"unknown field", "POST", "/api/users", "", map[string]string{"email": "jesse@example.com", "password": "x", "admin": "true"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"unknown refresh token", "POST", "/api/refresh", "unknown", nil, http.StatusUnauthorized, codeInvalidRefreshToken},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do(c.method, c.path, c.token, c.body, &problem); status != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+}
+
+func TestProblemDetails_InvalidJSON(t *testing.T) {
+	api := newTestAPI(t)
+
+	resp, err := http.Post(api.server.URL+"/api/users", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatalf("POST /api/users returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != apierror.ContentType {
+		t.Errorf("expected %v, got %v", apierror.ContentType, ct)
+	}
+
+	var problem apierror.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatalf("error decoding problem: %v", err)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Code != apierror.CodeInvalidJSON {
+		t.Errorf("expected %v %v, got %v %v", http.StatusBadRequest, apierror.CodeInvalidJSON, problem.Status, problem.Code)
+	}
+}
+
+func TestSigningKeys_RetireSecret(t *testing.T) {
+	api := newTestAPI(t)
+	userID := uuid.New()
+	memory := api.store.(*store.Memory)
+
+	legacyToken, err := api.cfg.keys.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	// a key rotated in just now leaves SECRET valid for its tokens
+	first := time.Now().Add(-time.Minute)
+	memory.AddSigningKey(store.SigningKey{ID: "first", Secret: "first-secret", CreatedAt: first})
+	if err := api.cfg.loadSigningKeys(context.Background()); err != nil {
+		t.Fatalf("loadSigningKeys returned error: %v", err)
+	}
+	if _, err := api.cfg.keys.ValidateJWT(legacyToken); err != nil {
+		t.Errorf("expected the legacy token to be valid right after the rotation, got %v", err)
+	}
+
+	// once the first key is older than a token lifetime, even when it
+	// was itself retired since, SECRET is no longer accepted
+	retired := time.Now().Add(-2 * accessTokenLifetime)
+	memory.AddSigningKey(store.SigningKey{ID: "older", Secret: "older-secret", CreatedAt: retired.Add(-time.Hour), RetiredAt: &retired})
+	second := time.Now().Add(-accessTokenLifetime - time.Minute)
+	memory.AddSigningKey(store.SigningKey{ID: "second", Secret: "second-secret", CreatedAt: second})
+	if err := api.cfg.loadSigningKeys(context.Background()); err != nil {
+		t.Fatalf("loadSigningKeys returned error: %v", err)
+	}
+	if _, err := api.cfg.keys.ValidateJWT(legacyToken); err == nil {
+		t.Error("expected the legacy token to be rejected a token lifetime after the first rotation")
+	}
+}
+
+func TestSeedAPI_SQLite(t *testing.T) {
+	db, err := sql.Open(store.SQLiteDriver, store.SQLiteDSN(filepath.Join(t.TempDir(), "chirpy.db")))
+	if err != nil {
+		t.Fatalf("error opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate.Up(context.Background(), db, config.BackendSQLite); err != nil {
+		t.Fatalf("error migrating SQLite database: %v", err)
+	}
+
+	api := newTestAPI(t, func(c *config.Config) {
+		c.DatabaseBackend = config.BackendSQLite
+		c.Platform = config.PlatformDev
+		c.AdminToken = "admin"
+	})
+	api.cfg.db = db
+	api.cfg.store = store.NewSQLite(db)
+	api.store = api.cfg.store
+
+	walt, jesse := uuid.New(), uuid.New()
+	seed := map[string]any{
+		"users": []map[string]any{
+			{"id": walt, "email": "walt@example.com", "password": "say-my-name"},
+			{"id": jesse, "email": "jesse@example.com", "password": "yeah-science"},
+		},
+		"chirps":         []map[string]any{{"id": uuid.New(), "user_id": walt, "body": "I am the one who knocks"}},
+		"follows":        []map[string]any{{"follower_id": jesse, "followee_id": walt}},
+		"refresh_tokens": []map[string]any{{"token": "blue-sky", "user_id": walt, "expires_at": time.Now().Add(time.Hour)}},
+	}
+	if status := api.do("POST", "/admin/seed", "admin", seed, nil); status != http.StatusOK {
+		t.Fatalf("expected %v seeding, got %v", http.StatusOK, status)
+	}
+
+	// seeded passwords are hashed with the configured parameters, so
+	// logins do not rehash them
+	user, err := api.store.GetUserByID(context.Background(), walt)
+	if err != nil {
+		t.Fatalf("GetUserByID returned error: %v", err)
+	}
+	if _, rehash, err := api.cfg.hasher.Check(context.Background(), "say-my-name", user.HashedPassword); err != nil || rehash {
+		t.Errorf("expected a hash with the configured parameters, got rehash %v (err %v)", rehash, err)
+	}
+
+	credentials := map[string]string{"email": "walt@example.com", "password": "say-my-name"}
+	if status := api.do("POST", "/api/login", "", credentials, nil); status != http.StatusOK {
+		t.Errorf("expected %v logging in as a seeded user, got %v", http.StatusOK, status)
+	}
+
+	var chirps []Chirp
+	if api.do("GET", "/api/chirps", "", nil, &chirps); len(chirps) != 1 {
+		t.Errorf("expected the seeded chirp, got %+v", chirps)
+	}
+
+	if status := api.do("POST", "/admin/reset", "admin", nil, nil); status != http.StatusOK {
+		t.Fatalf("expected %v resetting, got %v", http.StatusOK, status)
+	}
+	if api.do("GET", "/api/chirps", "", nil, &chirps); len(chirps) != 0 {
+		t.Errorf("expected no chirps after the reset, got %+v", chirps)
+	}
+}
+
+func TestAdminCLI_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chirpy.db")
+	db, err := sql.Open(store.SQLiteDriver, store.SQLiteDSN(path))
+	if err != nil {
+		t.Fatalf("error opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate.Up(context.Background(), db, config.BackendSQLite); err != nil {
+		t.Fatalf("error migrating SQLite database: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.DatabaseBackend = config.BackendSQLite
+	cfg.DatabaseURL = path
+	cfg.Password.Argon2Memory = int(testArgon2Params.Memory)
+	cfg.Password.Argon2Iterations = int(testArgon2Params.Iterations)
+	cfg.Password.Argon2Parallelism = int(testArgon2Params.Parallelism)
+
+	admin := func(args ...string) {
+		t.Helper()
+		if err := runAdmin(context.Background(), cfg, append([]string{"-output", "json"}, args...), io.Discard); err != nil {
+			t.Fatalf("chirpy admin %v returned error: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	admin("users", "create", "-email", "gus@example.com", "-password", "los-pollos-hermanos", "-red")
+	admin("users", "grant-moderator", "gus@example.com")
+	admin("users", "suspend", "gus@example.com")
+	admin("keys", "rotate")
+
+	s := store.NewSQLite(db)
+	gus, err := s.GetUserByEmail(context.Background(), "gus@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail returned error: %v", err)
+	}
+	if !gus.IsChirpyRed || !gus.IsModerator || !gus.Suspended() {
+		t.Errorf("expected a suspended Chirpy Red moderator, got %+v", gus)
+	}
+	if keys, err := s.ListSigningKeys(context.Background(), 0); err != nil || len(keys) != 1 {
+		t.Errorf("expected the rotated signing key, got %+v (err %v)", keys, err)
+	}
+
+	if _, err := s.SoftDeleteUser(context.Background(), gus.ID); err != nil {
+		t.Fatalf("SoftDeleteUser returned error: %v", err)
+	}
+	admin("users", "undelete", gus.ID.String())
+	if gus, err := s.GetUserByID(context.Background(), gus.ID); err != nil || gus.Deleted() {
+		t.Errorf("expected gus not to be pending deletion, got %+v (err %v)", gus, err)
+	}
+}
+
+func TestTokensAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	if status := api.do("POST", "/api/refresh", walt.RefreshToken, nil, &refreshed); status != http.StatusOK {
+		t.Fatalf("expected %v refreshing, got %v", http.StatusOK, status)
+	}
+	if refreshed.Token == "" {
+		t.Errorf("expected a new access token")
+	}
+
+	if status := api.do("POST", "/api/revoke", walt.RefreshToken, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected %v revoking, got %v", http.StatusNoContent, status)
+	}
+
+	if status := api.do("POST", "/api/refresh", walt.RefreshToken, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v with a revoked token, got %v", http.StatusUnauthorized, status)
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("error encoding JSON: %v", err)
+	}
+	return string(data)
+}
+
+func TestProfilesAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	var updated User
+	patch := map[string]any{"handle": "Heisenberg", "display_name": "Walter White", "bio": "I am the one who knocks"}
+	if status := api.do("PATCH", "/api/users/me", walt.Token, patch, &updated); status != http.StatusOK {
+		t.Fatalf("expected %v patching profile, got %v", http.StatusOK, status)
+	}
+	if updated.Handle != "heisenberg" || updated.DisplayName != "Walter White" || updated.Email != "walt@example.com" {
+		t.Errorf("unexpected user %+v", updated)
+	}
+
+	// members left out are unchanged, null members are removed
+	patch = map[string]any{"bio": nil, "location": "Albuquerque"}
+	if status := api.do("PATCH", "/api/users/me", walt.Token, patch, &updated); status != http.StatusOK {
+		t.Fatalf("expected %v patching profile, got %v", http.StatusOK, status)
+	}
+	if updated.Bio != "" || updated.Location != "Albuquerque" || updated.DisplayName != "Walter White" {
+		t.Errorf("unexpected user %+v", updated)
+	}
+
+	for _, path := range []string{"/api/users/heisenberg", "/api/users/" + walt.ID.String()} {
+		var profile map[string]any
+		if status := api.do("GET", path, "", nil, &profile); status != http.StatusOK {
+			t.Fatalf("expected %v getting %v, got %v", http.StatusOK, path, status)
+		}
+		if _, ok := profile["email"]; ok {
+			t.Errorf("expected the public profile not to expose the email, got %v", profile)
+		}
+		if profile["handle"] != "heisenberg" {
+			t.Errorf("expected %v, got %v", "heisenberg", profile["handle"])
+		}
+	}
+
+	cases := []struct {
+		name         string
+		token        string
+		patch        map[string]any
+		expectStatus int
+		expectCode   string
+	}{
+		{"taken handle", jesse.Token, map[string]any{"handle": "heisenberg"}, http.StatusConflict, codeHandleTaken},
+		{"invalid handle", jesse.Token, map[string]any{"handle": "cap'n cook"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"invalid avatar", jesse.Token, map[string]any{"avatar_url": "javascript:alert(1)"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"email without current password", jesse.Token, map[string]any{"email": "pinkman@example.com"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"wrong current password", jesse.Token, map[string]any{"email": "pinkman@example.com", "current_password": "wrong"}, http.StatusForbidden, codeInvalidCurrentPassword},
+		{"null email", jesse.Token, map[string]any{"email": nil, "current_password": "yeah-science"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"no token", "", map[string]any{"bio": "yo"}, http.StatusUnauthorized, apierror.CodeMissingToken},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do("PATCH", "/api/users/me", c.token, c.patch, &problem); status != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+
+	patch = map[string]any{"email": "pinkman@example.com", "password": "magnets-bitch", "current_password": "yeah-science"}
+	if status := api.do("PATCH", "/api/users/me", jesse.Token, patch, nil); status != http.StatusOK {
+		t.Fatalf("expected %v changing credentials, got %v", http.StatusOK, status)
+	}
+
+	login := map[string]string{"email": "pinkman@example.com", "password": "magnets-bitch"}
+	if status := api.do("POST", "/api/login", "", login, nil); status != http.StatusOK {
+		t.Errorf("expected %v logging in with the new credentials, got %v", http.StatusOK, status)
+	}
+
+	if status := api.do("GET", "/api/users/capncook", "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v for an unknown handle, got %v", http.StatusNotFound, status)
+	}
+}
+
+func TestDeleteAccountAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+
+	chirp := map[string]string{"body": "say my name"}
+	var created Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, chirp, &created); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+
+	var problem apierror.Problem
+	wrong := map[string]string{"password": "wrong"}
+	if status := api.do("DELETE", "/api/users/me", walt.Token, wrong, &problem); status != http.StatusForbidden {
+		t.Errorf("expected %v with a wrong password, got %v", http.StatusForbidden, status)
+	}
+	if problem.Code != codeInvalidCurrentPassword {
+		t.Errorf("expected %v, got %v", codeInvalidCurrentPassword, problem.Code)
+	}
+
+	var deletion AccountDeletion
+	confirm := map[string]string{"password": "say-my-name"}
+	grace := config.Default().Accounts.DeletionGrace
+	if status := api.do("DELETE", "/api/users/me", walt.Token, confirm, &deletion); status != http.StatusAccepted {
+		t.Fatalf("expected %v deleting account, got %v", http.StatusAccepted, status)
+	}
+	if !deletion.PurgeAfter.Equal(deletion.DeletedAt.Add(grace)) {
+		t.Errorf("expected the purge %v after %v, got %v", grace, deletion.DeletedAt, deletion.PurgeAfter)
+	}
+
+	// the account is hidden and signed out until it is purged
+	if status := api.do("GET", "/api/chirps/"+created.ID.String(), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v getting a chirp of a deleted account, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("GET", "/api/users/"+walt.ID.String(), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v getting a deleted profile, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("POST", "/api/refresh", walt.RefreshToken, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v refreshing, got %v", http.StatusUnauthorized, status)
+	}
+
+	login := map[string]string{"email": "walt@example.com", "password": "say-my-name"}
+	problem = apierror.Problem{}
+	if status := api.do("POST", "/api/login", "", login, &problem); status != http.StatusForbidden {
+		t.Errorf("expected %v logging in, got %v", http.StatusForbidden, status)
+	}
+	if problem.Code != codeAccountDeleted {
+		t.Errorf("expected %v, got %v", codeAccountDeleted, problem.Code)
+	}
+
+	if status := api.do("DELETE", "/api/users/me", walt.Token, confirm, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v deleting twice, got %v", http.StatusNotFound, status)
+	}
+
+	// the user can cancel the deletion during the grace period
+	wrongLogin := map[string]string{"email": "walt@example.com", "password": "wrong"}
+	if status := api.do("POST", "/api/users/restore", "", wrongLogin, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v restoring with a wrong password, got %v", http.StatusUnauthorized, status)
+	}
+	var restored User
+	if status := api.do("POST", "/api/users/restore", "", login, &restored); status != http.StatusOK || restored.ID != walt.ID {
+		t.Fatalf("expected %v restoring the account, got %v %+v", http.StatusOK, status, restored)
+	}
+	if status := api.do("GET", "/api/chirps/"+created.ID.String(), "", nil, nil); status != http.StatusOK {
+		t.Errorf("expected %v getting a chirp of a restored account, got %v", http.StatusOK, status)
+	}
+	problem = apierror.Problem{}
+	if status := api.do("POST", "/api/users/restore", "", login, &problem); status != http.StatusNotFound || problem.Code != codeAccountNotDeleted {
+		t.Errorf("expected %v %v restoring twice, got %v %v", http.StatusNotFound, codeAccountNotDeleted, status, problem.Code)
+	}
+
+	var relogin User
+	if status := api.do("POST", "/api/login", "", login, &relogin); status != http.StatusOK {
+		t.Fatalf("expected %v logging in after the restore, got %v", http.StatusOK, status)
+	}
+	if status := api.do("DELETE", "/api/users/me", relogin.Token, confirm, nil); status != http.StatusAccepted {
+		t.Fatalf("expected %v deleting the account again, got %v", http.StatusAccepted, status)
+	}
+
+	if purged, err := api.store.PurgeDeletedUsers(context.Background(), grace); err != nil || purged != 0 {
+		t.Errorf("expected no account purged within the grace period, got %v (err %v)", purged, err)
+	}
+	purged, err := api.store.PurgeDeletedUsers(context.Background(), 0)
+	if err != nil || purged != 1 {
+		t.Fatalf("expected 1 account purged, got %v (err %v)", purged, err)
+	}
+	if _, err := api.store.GetUserByID(context.Background(), walt.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected %v, got %v", store.ErrNotFound, err)
+	}
+}
+
+func TestExportAPI(t *testing.T) {
+	api := newTestAPI(t, func(cfg *config.Config) { cfg.Accounts.ExportSyncChirps = 1 })
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+
+	chirp := map[string]string{"body": "say my name"}
+	if status := api.do("POST", "/api/chirps", walt.Token, chirp, nil); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	if status := api.do("POST", "/api/users/me/drafts", walt.Token, map[string]string{"body": "unfinished"}, nil); status != http.StatusCreated {
+		t.Fatalf("expected %v creating draft, got %v", http.StatusCreated, status)
+	}
+	scheduled := map[string]any{"body": "later", "publish_at": time.Now().Add(time.Hour)}
+	if status := api.do("POST", "/api/chirps", walt.Token, scheduled, nil); status != http.StatusCreated {
+		t.Fatalf("expected %v scheduling chirp, got %v", http.StatusCreated, status)
+	}
+
+	resp, body := api.download("/api/users/me/export?format=json", walt.Token)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %v exporting, got %v", http.StatusOK, resp.StatusCode)
+	}
+
+	var export AccountExport
+	if err := json.Unmarshal(body, &export); err != nil {
+		t.Fatalf("error decoding export: %v", err)
+	}
+	if export.Profile.Email != "walt@example.com" || len(export.Chirps) != 1 || len(export.Drafts) != 1 || len(export.ScheduledChirps) != 1 || len(export.Sessions) != 1 {
+		t.Errorf("unexpected export %+v", export)
+	}
+	if strings.Contains(string(body), walt.RefreshToken) {
+		t.Errorf("expected the export not to hold refresh tokens")
+	}
+
+	resp, body = api.download("/api/users/me/export", walt.Token)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("expected a ZIP archive, got %v %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "profile.json,chirps.json,drafts.json,scheduled_chirps.json,sessions.json" {
+		t.Errorf("unexpected archive files %v", names)
+	}
+
+	if resp, _ := api.download("/api/users/me/export?format=csv", walt.Token); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %v for an unknown format, got %v", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// past ExportSyncChirps the export runs in the background
+	if status := api.do("POST", "/api/chirps", walt.Token, chirp, nil); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+
+	resp, body = api.download("/api/users/me/export?format=json", walt.Token)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected %v starting a background export, got %v", http.StatusAccepted, resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+
+	if resp, _ := api.download(location, jesse.Token); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %v getting the export of another user, got %v", http.StatusNotFound, resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for resp.StatusCode == http.StatusAccepted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		resp, body = api.download(location, walt.Token)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %v once the export is ready, got %v", http.StatusOK, resp.StatusCode)
+	}
+
+	export = AccountExport{}
+	if err := json.Unmarshal(body, &export); err != nil {
+		t.Fatalf("error decoding export: %v", err)
+	}
+	if len(export.Chirps) != 2 {
+		t.Errorf("expected 2 chirps, got %v", len(export.Chirps))
+	}
+}
+
+func TestExportJobs(t *testing.T) {
+	var exports exportJobs
+	userID := uuid.New()
+
+	release := make(chan struct{})
+	build := func(ctx context.Context) ([]byte, error) {
+		<-release
+		return []byte("{}"), nil
+	}
+
+	job, err := exports.start(userID, exportFormatJSON, build)
+	if err != nil {
+		t.Fatalf("start returned error: %v", err)
+	}
+	if again, err := exports.start(userID, exportFormatJSON, build); err != nil || again != job {
+		t.Errorf("expected the pending export, got %v (err %v)", again, err)
+	}
+	if _, err := exports.start(userID, exportFormatZIP, build); !errors.Is(err, errExportRunning) {
+		t.Errorf("expected %v with an export pending, got %v", errExportRunning, err)
+	}
+
+	close(release)
+	<-job.done
+
+	// a new export replaces the finished one
+	next, err := exports.start(userID, exportFormatZIP, build)
+	if err != nil || next == job {
+		t.Fatalf("expected a new export, got %v (err %v)", next, err)
+	}
+	if _, ok := exports.get(job.id, userID); ok {
+		t.Errorf("expected the finished export to be replaced")
+	}
+	<-next.done
+}
+
+func TestBlocksAPI(t *testing.T) {
+	api := newTestAPI(t)
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+	hank := api.signup("hank@example.com", "minerals-marie")
+
+	chirps := map[uuid.UUID]Chirp{}
+	for _, author := range []User{walt, jesse, hank} {
+		var chirp Chirp
+		body := map[string]string{"body": "chirp of " + author.Email}
+		if status := api.do("POST", "/api/chirps", author.Token, body, &chirp); status != http.StatusCreated {
+			t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+		}
+		chirps[author.ID] = chirp
+	}
+
+	if status := api.do("PUT", "/api/users/me/blocks/"+hank.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected %v blocking, got %v", http.StatusNoContent, status)
+	}
+	if status := api.do("PUT", "/api/users/me/mutes/"+jesse.ID.String(), walt.Token, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected %v muting, got %v", http.StatusNoContent, status)
+	}
+
+	var blocked []Profile
+	if status := api.do("GET", "/api/users/me/blocks", walt.Token, nil, &blocked); status != http.StatusOK {
+		t.Fatalf("expected %v listing blocks, got %v", http.StatusOK, status)
+	}
+	if len(blocked) != 1 || blocked[0].ID != hank.ID {
+		t.Errorf("expected hank to be blocked, got %+v", blocked)
+	}
+
+	var timeline []Chirp
+	if status := api.do("GET", "/api/chirps", walt.Token, nil, &timeline); status != http.StatusOK {
+		t.Fatalf("expected %v getting chirps, got %v", http.StatusOK, status)
+	}
+	if len(timeline) != 1 || timeline[0].UserID != walt.ID {
+		t.Errorf("expected only the chirp of walt, got %+v", timeline)
+	}
+
+	// the blocked user cannot read the blocker's chirps
+	if status := api.do("GET", "/api/chirps/"+chirps[walt.ID].ID.String(), hank.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected %v, got %v", http.StatusNotFound, status)
+	}
+	if status := api.do("GET", "/api/chirps?author_id="+walt.ID.String(), hank.Token, nil, &timeline); status != http.StatusOK || len(timeline) != 0 {
+		t.Errorf("expected no chirps, got %v %+v", status, timeline)
+	}
+
+	// nor reply to them
+	reply := map[string]any{"body": "you're goddamn right", "reply_to_id": chirps[walt.ID].ID}
+	var problem apierror.Problem
+	if status := api.do("POST", "/api/users/me/drafts", hank.Token, reply, &problem); status != http.StatusBadRequest || problem.Code != codeInvalidReplyTo {
+		t.Errorf("expected %v %v replying to the blocker, got %v %v", http.StatusBadRequest, codeInvalidReplyTo, status, problem.Code)
+	}
+	if status := api.do("POST", "/api/chirps", hank.Token, reply, &problem); status != http.StatusBadRequest || problem.Code != codeInvalidReplyTo {
+		t.Errorf("expected %v %v replying to the blocker, got %v %v", http.StatusBadRequest, codeInvalidReplyTo, status, problem.Code)
+	}
+
+	if status := api.do("GET", "/api/chirps", "not-a-jwt", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected %v with an invalid JWT, got %v", http.StatusUnauthorized, status)
+	}
+
+	cases := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		expectStatus int
+		expectCode   string
+	}{
+		{"block self", "PUT", "/api/users/me/blocks/" + walt.ID.String(), walt.Token, http.StatusBadRequest, codeSelfRelation},
+		{"invalid user ID", "PUT", "/api/users/me/mutes/heisenberg", walt.Token, http.StatusBadRequest, codeInvalidUserID},
+		{"unknown user", "PUT", "/api/users/me/blocks/" + uuid.NewString(), walt.Token, http.StatusNotFound, codeUserNotFound},
+		{"no token", "GET", "/api/users/me/mutes", "", http.StatusUnauthorized, apierror.CodeMissingToken},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do(c.method, c.path, c.token, nil, &problem); status != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+
+	for _, path := range []string{"/api/users/me/blocks/" + hank.ID.String(), "/api/users/me/mutes/" + jesse.ID.String()} {
+		if status := api.do("DELETE", path, walt.Token, nil, nil); status != http.StatusNoContent {
+			t.Errorf("expected %v deleting %v, got %v", http.StatusNoContent, path, status)
+		}
+	}
+	if status := api.do("GET", "/api/chirps", walt.Token, nil, &timeline); status != http.StatusOK || len(timeline) != 3 {
+		t.Errorf("expected 3 chirps, got %v %+v", status, timeline)
+	}
+}
+
+func TestReportsAPI(t *testing.T) {
+	api := newTestAPI(t, func(c *config.Config) { c.Moderation.HideThreshold = 2 })
+	walt := api.signup("walt@example.com", "say-my-name")
+	jesse := api.signup("jesse@example.com", "yeah-science")
+	hank := api.signup("hank@example.com", "minerals-marie")
+	gus := api.signup("gus@example.com", "los-pollos")
+
+	if err := api.store.SetModerator(context.Background(), gus.ID, true); err != nil {
+		t.Fatalf("SetModerator returned error: %v", err)
+	}
+
+	var chirp Chirp
+	if status := api.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp); status != http.StatusCreated {
+		t.Fatalf("expected %v creating chirp, got %v", http.StatusCreated, status)
+	}
+	reportsPath := "/api/chirps/" + chirp.ID.String() + "/reports"
+
+	var report Report
+	body := map[string]string{"reason": "harassment", "details": "threatening"}
+	if status := api.do("POST", reportsPath, jesse.Token, body, &report); status != http.StatusCreated {
+		t.Fatalf("expected %v reporting, got %v", http.StatusCreated, status)
+	}
+	if report.Status != store.ReportOpen || report.AuthorID != walt.ID || report.ChirpBody != chirp.Body {
+		t.Errorf("expected an open report of the chirp, got %+v", report)
+	}
+
+	cases := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		body         any
+		expectStatus int
+		expectCode   string
+	}{
+		{"report twice", "POST", reportsPath, jesse.Token, body, http.StatusConflict, codeAlreadyReported},
+		{"own chirp", "POST", reportsPath, walt.Token, body, http.StatusBadRequest, codeReportOwnChirp},
+		{"unknown reason", "POST", reportsPath, hank.Token, map[string]string{"reason": "boring"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{"unknown chirp", "POST", "/api/chirps/" + uuid.NewString() + "/reports", hank.Token, body, http.StatusNotFound, codeChirpNotFound},
+		{"queue without role", "GET", "/admin/reports", jesse.Token, nil, http.StatusForbidden, codeNotModerator},
+		{"queue without token", "GET", "/admin/reports", "", nil, http.StatusUnauthorized, apierror.CodeMissingToken},
+		{"unknown status", "GET", "/admin/reports?status=closed", gus.Token, nil, http.StatusBadRequest, codeInvalidReportStatus},
+		{"unknown report", "POST", "/admin/reports/" + uuid.NewString() + "/actions", gus.Token, map[string]string{"action": "dismiss"}, http.StatusNotFound, codeReportNotFound},
+		{"unknown action", "POST", "/admin/reports/" + report.ID.String() + "/actions", gus.Token, map[string]string{"action": "ban"}, http.StatusBadRequest, apierror.CodeValidationFailed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var problem apierror.Problem
+			if status := api.do(c.method, c.path, c.token, c.body, &problem); status != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, status)
+			}
+			if problem.Code != c.expectCode {
+				t.Errorf("expected %v, got %v", c.expectCode, problem.Code)
+			}
+		})
+	}
+
+	// the second reporter reaches the threshold and hides the chirp
+	if status := api.do("POST", reportsPath, hank.Token, map[string]string{"reason": "spam"}, nil); status != http.StatusCreated {
+		t.Fatalf("expected %v reporting, got %v", http.StatusCreated, status)
+	}
+	if status := api.do("GET", "/api/chirps/"+chirp.ID.String(), jesse.Token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected the hidden chirp to be %v, got %v", http.StatusNotFound, status)
+	}
+	var own Chirp
+	if status := api.do("GET", "/api/chirps/"+chirp.ID.String(), walt.Token, nil, &own); status != http.StatusOK || own.HiddenAt == nil {
+		t.Errorf("expected the author to see the chirp as hidden, got %v %+v", status, own)
+	}
+
+	var queue []Report
+	if status := api.do("GET", "/admin/reports", gus.Token, nil, &queue); status != http.StatusOK {
+		t.Fatalf("expected %v listing reports, got %v", http.StatusOK, status)
+	}
+	if len(queue) != 2 || queue[0].ID != report.ID {
+		t.Errorf("expected 2 open reports, got %+v", queue)
+	}
+
+	// suspending the author resolves every report of the chirp
+	var resolved Report
+	action := map[string]string{"action": "suspend_author", "note": "threats"}
+	if status := api.do("POST", "/admin/reports/"+report.ID.String()+"/actions", gus.Token, action, &resolved); status != http.StatusOK {
+		t.Fatalf("expected %v moderating, got %v", http.StatusOK, status)
+	}
+	if resolved.Status != store.ReportActioned || resolved.ResolvedAt == nil {
+		t.Errorf("expected the report to be actioned, got %+v", resolved)
+	}
+	if status := api.do("GET", "/admin/reports", gus.Token, nil, &queue); status != http.StatusOK || len(queue) != 0 {
+		t.Errorf("expected an empty queue, got %v %+v", status, queue)
+	}
+	if status := api.do("GET", "/admin/reports?status=actioned", gus.Token, nil, &queue); status != http.StatusOK || len(queue) != 2 {
+		t.Fatalf("expected both reports to be actioned, got %v %+v", status, queue)
+	}
+	var problem apierror.Problem
+	if status := api.do("POST", "/admin/reports/"+queue[1].ID.String()+"/actions", gus.Token, map[string]string{"action": "dismiss"}, &problem); status != http.StatusConflict || problem.Code != codeReportResolved {
+		t.Errorf("expected %v acting on a resolved report, got %v %v", codeReportResolved, status, problem.Code)
+	}
+	if status := api.do("POST", "/api/login", "", map[string]string{"email": walt.Email, "password": "say-my-name"}, nil); status != http.StatusForbidden {
+		t.Errorf("expected the suspended author not to log in, got %v", status)
+	}
+
+	var log []ModerationAction
+	if status := api.do("GET", "/admin/audit-log", gus.Token, nil, &log); status != http.StatusOK {
+		t.Fatalf("expected %v listing the audit log, got %v", http.StatusOK, status)
+	}
+	if len(log) != 2 || log[0].Action != actionSuspendAuthor || log[1].Action != actionAutoHide {
+		t.Fatalf("expected the suspension after the automatic hide, got %+v", log)
+	}
+	if log[0].ModeratorID == nil || *log[0].ModeratorID != gus.ID || log[0].Note != "threats" {
+		t.Errorf("expected the action of gus, got %+v", log[0])
+	}
+	if log[1].ModeratorID != nil {
+		t.Errorf("expected no moderator for the automatic hide, got %v", *log[1].ModeratorID)
+	}
+	if status := api.do("GET", "/admin/audit-log", hank.Token, nil, nil); status != http.StatusForbidden {
+		t.Errorf("expected %v, got %v", http.StatusForbidden, status)
+	}
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
 
+	"github.com/luis-octavius/chirpy/internal/apierror"
 	"github.com/luis-octavius/chirpy/internal/auth"
+	"github.com/luis-octavius/chirpy/internal/logging"
+	"github.com/luis-octavius/chirpy/internal/store"
 )
 
+// codeInvalidRefreshToken is returned for unknown, expired or revoked
+// refresh tokens
+const codeInvalidRefreshToken = "invalid_refresh_token"
+
+// handlerRefreshToken returns a new access token for the refresh token
+// sent as Bearer token
+//
+// Returns 401 if the refresh token is missing, invalid or its user is
+// suspended
+// Returns 200 with the access token on success
 func (cfg *apiConfig) handlerRefreshToken() http.Handler {
 	type respToken struct {
 		Token string `json:"token"`
@@ -16,20 +29,25 @@ func (cfg *apiConfig) handlerRefreshToken() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		refreshToken, err := auth.GetBearerToken(r.Header)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized(apierror.CodeMissingToken, "missing Bearer token").Wrap(err))
 			return
 		}
 
-		user, err := cfg.queries.GetUserByRefreshToken(r.Context(), refreshToken)
+		user, err := cfg.store.GetUserByRefreshToken(r.Context(), refreshToken)
+		if errors.Is(err, store.ErrNotFound) || err == nil && user.Suspended() {
+			apierror.Write(w, r, apierror.Unauthorized(codeInvalidRefreshToken, "invalid, expired or revoked refresh token"))
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
-		accToken, err := auth.MakeJWT(user.ID, cfg.secret, 1*time.Hour)
+		logging.SetUserID(r.Context(), user.ID)
+
+		accToken, err := cfg.keys.MakeJWT(user.ID, accessTokenLifetime)
 		if err != nil {
-			log.Printf("error creating JWT access token: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error creating JWT access token: %w", err)))
 			return
 		}
 
@@ -41,17 +59,23 @@ func (cfg *apiConfig) handlerRefreshToken() http.Handler {
 	})
 }
 
+// handlerRevokeToken revokes the refresh token sent as Bearer token
+//
+// Returns 401 if the request has no Bearer token
+// Returns 204 on success, revoking an unknown token is not an error
 func (cfg *apiConfig) handlerRevokeToken() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token, err := auth.GetBearerToken(r.Header)
-
-		err = cfg.queries.RevokeRefreshToken(r.Context(), token)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			apierror.Write(w, r, apierror.Unauthorized(apierror.CodeMissingToken, "missing Bearer token").Wrap(err))
+			return
+		}
+
+		if err := cfg.store.RevokeRefreshToken(r.Context(), token); err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error revoking refresh token: %w", err)))
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	})
-
 }
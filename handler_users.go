@@ -1,221 +1,373 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
 	"github.com/luis-octavius/chirpy/internal/auth"
-	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/logging"
+	"github.com/luis-octavius/chirpy/internal/password"
+	"github.com/luis-octavius/chirpy/internal/request"
+	"github.com/luis-octavius/chirpy/internal/store"
 )
 
+// errAccountSuspended aborts the login of a suspended user
+var errAccountSuspended = errors.New("account suspended")
+
+// Error codes of the user endpoints
+const (
+	codeEmailTaken         = "email_taken"
+	codeHandleTaken        = "handle_taken"
+	codeInvalidCredentials = "invalid_credentials"
+	codeAccountSuspended   = "account_suspended"
+	codeUserNotFound       = "user_not_found"
+	codeInvalidAPIKey      = "invalid_api_key"
+)
+
+// handlerCreateUser signs up a user
+//
+// Returns 400 if JSON decoding or validation fails, or the password
+// breaks the password policy
+// Returns 409 if the email is used by another user
+// Returns 201 with the created user on success
 func (cfg *apiConfig) handlerCreateUser() http.Handler {
-	type validateParams struct {
-		Email    string `json:"email,omitempty"`
-		Password string `json:"password"`
-		Error    string `json:"error,omitempty"`
+	type reqParams struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var req validateParams
+		var req reqParams
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			resp := validateParams{Error: "something went wrong with JSON decoding"}
-			writeJSON(w, http.StatusBadRequest, resp)
+		if err := request.Decode(w, r, &req); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		hashPassword, err := auth.HashPassword(req.Password)
-		if err != nil {
-			resp := validateParams{Error: "error hashing the password"}
-			writeJSON(w, http.StatusInternalServerError, resp)
+		if err := cfg.checkPassword(r.Context(), req.Password); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		user, err := cfg.queries.CreateUser(r.Context(), database.CreateUserParams{
-			Email:          req.Email,
-			HashedPassword: hashPassword,
-		})
+		hashPassword, err := cfg.hasher.Hash(r.Context(), req.Password)
 		if err != nil {
-			resp := validateParams{Error: "something went wrong creating user in database"}
-			writeJSON(w, http.StatusInternalServerError, resp)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error hashing the password: %w", err)))
 			return
 		}
 
-		resp := User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
+		user, err := cfg.store.CreateUser(r.Context(), req.Email, hashPassword)
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
 		}
 
-		writeJSON(w, http.StatusCreated, resp)
+		writeJSON(w, http.StatusCreated, newUser(user))
 	})
 }
 
+// handlerUserLogin returns an access and a refresh token
+//
+// Returns 400 if JSON decoding or validation fails
+// Returns 401 if the email or the password is wrong
+// Returns 403 if the account is suspended or pending deletion
+// Returns 200 with the user and its tokens on success
 func (cfg *apiConfig) handlerUserLogin() http.Handler {
 	type reqParams struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string `json:"password" validate:"required"`
+		Email    string `json:"email" validate:"required"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var params reqParams
 
-		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if err := request.Decode(w, r, &params); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
+		invalidCredentials := apierror.Unauthorized(codeInvalidCredentials, "incorrect email or password")
+
 		// retrieves user from db - fails if user doesn't exist or use wrong password
-		user, err := cfg.queries.GetUserByEmail(r.Context(), params.Email)
+		user, err := cfg.store.GetUserByEmail(r.Context(), params.Email)
+		if errors.Is(err, store.ErrNotFound) {
+			cfg.metrics.LoginFailed()
+			apierror.Write(w, r, invalidCredentials)
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Incorrect email and password"))
+			apierror.Write(w, r, apierror.Internal(err))
 			return
 		}
 
 		// check password hash against input password
-		checkPassword, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
-		if err != nil || !checkPassword {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Incorrect email or password"))
+		match, rehash, err := cfg.hasher.Check(r.Context(), params.Password, user.HashedPassword)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error checking password: %w", err)))
+			return
+		}
+		if !match {
+			cfg.metrics.LoginFailed()
+			apierror.Write(w, r, invalidCredentials)
 			return
 		}
 
-		// expires cannot be greather than 1 hour
-		// default is 1 hour
-		expiresAccToken := 1 * time.Hour
-		refreshToken, _ := auth.MakeRefreshToken()
+		// hashes from bcrypt or with outdated parameters are replaced while
+		// the plain password is known, a failure only delays the upgrade
+		var newHash string
+		if rehash {
+			newHash, err = cfg.hasher.Hash(r.Context(), params.Password)
+			if err != nil {
+				slog.WarnContext(r.Context(), "error rehashing password", "err", err)
+			}
+		}
 
-		token, err := auth.MakeJWT(user.ID, cfg.secret, expiresAccToken)
+		refreshToken, err := auth.MakeRefreshToken()
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error creating refresh token: %w", err)))
+			return
+		}
 
-		newRefreshToken, err := cfg.queries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-			Token:     refreshToken,
-			UserID:    user.ID,
-			ExpiresAt: time.Now().AddDate(0, 0, 60),
+		// suspended accounts keep their data but cannot get new tokens,
+		// the suspension is read in the transaction storing the refresh
+		// token so a concurrent `chirpy admin users suspend` cannot miss it
+		var rehashed bool
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			current, err := tx.GetUserByID(r.Context(), user.ID)
+			if err != nil {
+				return err
+			}
+			if current.Suspended() {
+				return errAccountSuspended
+			}
+			if current.Deleted() {
+				return errAccountDeleted
+			}
+
+			// a password changed since it was checked is not overwritten
+			rehashed = newHash != "" && current.HashedPassword == user.HashedPassword
+			if rehashed {
+				if err := tx.UpdateUserPassword(r.Context(), user.ID, newHash); err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.CreateRefreshToken(r.Context(), refreshToken, user.ID, time.Now().AddDate(0, 0, 60))
+			return err
 		})
+		if errors.Is(err, errAccountSuspended) || errors.Is(err, errAccountDeleted) {
+			cfg.metrics.LoginFailed()
+		}
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
+		if rehashed {
+			cfg.metrics.PasswordRehashed()
+		}
 
-		// create JSON answer
-		resp := User{
-			ID:           user.ID,
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
-			Email:        user.Email,
-			Token:        token,
-			RefreshToken: newRefreshToken.Token,
-			IsChirpyRed:  user.IsChirpyRed,
+		// the access token is only minted once its refresh token is stored,
+		// expires cannot be greather than 1 hour
+		token, err := cfg.keys.MakeJWT(user.ID, accessTokenLifetime)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error creating JWT access token: %w", err)))
+			return
 		}
 
+		cfg.metrics.LoginSucceeded()
+		logging.SetUserID(r.Context(), user.ID)
+
+		// create JSON answer
+		resp := newUser(user)
+		resp.Token = token
+		resp.RefreshToken = refreshToken
+
 		writeJSON(w, http.StatusOK, resp)
 	})
 }
 
+// handlerUpdateUser changes the email and password of the
+// authenticated user, like handlerPatchUser it requires the current
+// password
+//
+// Returns 400 if JSON decoding or validation fails, or the password
+// breaks the password policy
+// Returns 401 if the request has no valid JWT
+// Returns 403 if the current password is wrong
+// Returns 404 if the user has been deleted
+// Returns 409 if the email is used by another user
+// Returns 200 with the updated user on success
 func (cfg *apiConfig) handlerUpdateUser() http.Handler {
 	type reqParams struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password        string `json:"password" validate:"required"`
+		Email           string `json:"email" validate:"required,email"`
+		CurrentPassword string `json:"current_password" validate:"required"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.authenticate(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
 		var params reqParams
 
-		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+		if err := request.Decode(w, r, &params); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
-		token, err := auth.GetBearerToken(r.Header)
+		user, err := cfg.store.GetUserByID(r.Context(), userID)
 		if err != nil {
-			log.Printf("error getting token from Authentication Header: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, userError(err))
 			return
 		}
 
-		validatedUser, err := auth.ValidateJWT(token, cfg.secret)
+		// a stolen access token alone cannot take over the account
+		match, _, err := cfg.hasher.Check(r.Context(), params.CurrentPassword, user.HashedPassword)
 		if err != nil {
-			log.Printf("error authenticating token: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error checking password: %w", err)))
+			return
+		}
+		if !match {
+			apierror.Write(w, r, apierror.Forbidden(codeInvalidCurrentPassword, "the current password is wrong"))
 			return
 		}
 
-		user, err := cfg.queries.GetUserByID(r.Context(), validatedUser)
-		if err != nil {
-			log.Printf("error getting user by ID: %v", err)
+		if err := cfg.checkPassword(r.Context(), params.Password); err != nil {
+			apierror.Write(w, r, err)
+			return
 		}
 
-		hashedPassword, err := auth.HashPassword(params.Password)
+		hashedPassword, err := cfg.hasher.Hash(r.Context(), params.Password)
 		if err != nil {
-			log.Printf("error hashing password: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Internal(fmt.Errorf("error hashing password: %w", err)))
 			return
 		}
 
-		err = cfg.queries.UpdateUserEmailAndPass(r.Context(), database.UpdateUserEmailAndPassParams{
-			HashedPassword: hashedPassword,
-			Email:          params.Email,
-			ID:             user.ID,
+		var updated store.User
+		err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+			current, err := tx.GetUserByID(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			if current.HashedPassword != user.HashedPassword {
+				return errCredentialsChanged
+			}
+
+			updated, err = tx.UpdateUserEmailAndPassword(r.Context(), userID, params.Email, hashedPassword)
+			return err
 		})
-		if err != nil {
-			log.Printf("error updating user email and password: %v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+		if errors.Is(err, errCredentialsChanged) {
+			apierror.Write(w, r, apierror.Forbidden(codeInvalidCurrentPassword, "the password changed, try again with the new one"))
 			return
 		}
-
-		resp := User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       params.Email,
-			IsChirpyRed: user.IsChirpyRed,
+		if err != nil {
+			apierror.Write(w, r, userError(err))
+			return
 		}
-		fmt.Println("resp: ", resp)
 
-		writeJSON(w, http.StatusOK, resp)
+		writeJSON(w, http.StatusOK, newUser(updated))
 	})
 }
 
+// handlerUpgradeUser receives the Polka webhooks, upgrading users to
+// Chirpy Red
+//
+// Returns 401 if the request has not the Polka API key
+// Returns 400 if JSON decoding fails or the user ID is not a UUID
+// Returns 404 if no user exists with the given ID
+// Returns 204 on success and for ignored events
 func (cfg *apiConfig) handlerUpgradeUser() http.Handler {
-
 	type reqParams struct {
-		Event string `json:"event"`
+		Event string `json:"event" validate:"required"`
 		Data  struct {
-			UserID string `json:"user_id"`
+			UserID string `json:"user_id" validate:"uuid"`
 		} `json:"data"`
 	}
 
+	// Polka may add fields to its events at any time
+	decoder := request.Decoder{AllowUnknownFields: true}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		apiKey, err := auth.GetAPIKey(r.Header)
-		if err != nil || apiKey != cfg.apiKey {
-			w.WriteHeader(http.StatusUnauthorized)
+		if err != nil || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.config.PolkaKey)) != 1 {
+			apierror.Write(w, r, apierror.Unauthorized(codeInvalidAPIKey, "missing or invalid API key").Wrap(err))
 			return
 		}
 
 		var params reqParams
 
-		err = json.NewDecoder(r.Body).Decode(&params)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if err := decoder.Decode(w, r, &params); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
+		cfg.metrics.WebhookReceived(params.Event)
+
 		if params.Event != "user.upgraded" {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
 		userID, err := uuid.Parse(params.Data.UserID)
-		err = cfg.queries.UpgradeUserByID(r.Context(), userID)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
+			apierror.Write(w, r, apierror.Validation(apierror.FieldError{
+				Field:   "data.user_id",
+				Code:    request.CodeRequired,
+				Message: "is required for user.upgraded events",
+			}).Wrap(err))
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		if err := cfg.store.UpgradeUser(r.Context(), userID); err != nil {
+			apierror.Write(w, r, userError(err))
+			return
+		}
 
+		w.WriteHeader(http.StatusNoContent)
 	})
 }
+
+// checkPassword enforces the password policy on a new password
+//
+// Returns a 400 validation_failed error naming the broken rule
+func (cfg *apiConfig) checkPassword(ctx context.Context, plain string) error {
+	err := cfg.passwords.Check(ctx, plain)
+
+	var violation *password.Violation
+	if errors.As(err, &violation) {
+		return apierror.Validation(apierror.FieldError{
+			Field:   "password",
+			Code:    violation.Code,
+			Message: violation.Message,
+		})
+	}
+
+	return err
+}
+
+// userError maps the errors of the user store methods to API errors
+func userError(err error) error {
+	switch {
+	case errors.Is(err, errAccountSuspended):
+		return apierror.Forbidden(codeAccountSuspended, "the account is suspended")
+	case errors.Is(err, errAccountDeleted):
+		return apierror.Forbidden(codeAccountDeleted, "the account is pending deletion")
+	case errors.Is(err, store.ErrEmailTaken):
+		return apierror.Conflict(codeEmailTaken, "the email is used by another user")
+	case errors.Is(err, store.ErrHandleTaken):
+		return apierror.Conflict(codeHandleTaken, "the handle is used by another user")
+	case errors.Is(err, store.ErrNotFound):
+		return apierror.NotFound(codeUserNotFound, "the user does not exist")
+	default:
+		return apierror.Internal(err)
+	}
+}
@@ -0,0 +1,165 @@
+// Package apierror defines the errors returned by the HTTP API and
+// writes them as RFC 9457 application/problem+json documents.
+//
+// Every error carries a stable machine-readable code, clients should
+// branch on it rather than on the human-readable title and detail.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/luis-octavius/chirpy/internal/logging"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Codes shared by every endpoint, endpoint specific codes are declared
+// next to their handler
+const (
+	CodeInvalidJSON      = "invalid_json"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeValidationFailed = "validation_failed"
+	CodeMissingToken     = "missing_token"
+	CodeInvalidToken     = "invalid_token"
+	CodeInternal         = "internal_error"
+)
+
+// Error is an API error with the status and code it is returned with.
+// Err is the underlying cause, it is logged but never sent to clients.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	Err    error
+}
+
+// FieldError describes why one field of the request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %v", e.Status, e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error with the given status, code and detail
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Wrap attaches the cause of the error, for the logs
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Internal hides err behind a generic 500 internal_error
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "").Wrap(err)
+}
+
+// Validation returns a 400 validation_failed listing every invalid field
+func Validation(fields ...FieldError) *Error {
+	return &Error{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: "the request has invalid fields",
+		Fields: fields,
+	}
+}
+
+// InvalidJSON reports a request body that cannot be decoded, a body
+// over the server limit is reported as 413 body_too_large
+func InvalidJSON(err error) *Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		detail := fmt.Sprintf("the request body exceeds %d bytes", maxBytesErr.Limit)
+		return New(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, detail).Wrap(err)
+	}
+
+	return BadRequest(CodeInvalidJSON, "the request body is not valid JSON").Wrap(err)
+}
+
+// Problem is the RFC 9457 problem details document, with the code,
+// request_id and errors extension members
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Write sends err as problem details. Errors that are not an *Error
+// become a 500 internal_error, 5xx errors are logged with their cause.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal(err)
+	}
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "code", apiErr.Code, "err", apiErr.Err)
+	} else if apiErr.Err != nil {
+		slog.WarnContext(r.Context(), "request rejected", "code", apiErr.Code, "err", apiErr.Err)
+	}
+
+	// the codes are the stable identifiers, so the type is left to
+	// about:blank and the title is the status phrase as RFC 9457 asks
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Detail,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		RequestID: logging.RequestID(r.Context()),
+		Errors:    apiErr.Fields,
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(problem)
+}
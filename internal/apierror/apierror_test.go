@@ -0,0 +1,101 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luis-octavius/chirpy/internal/logging"
+)
+
+func TestWrite(t *testing.T) {
+	cases := map[string]struct {
+		err          error
+		expectStatus int
+		expectCode   string
+	}{
+		"api error": {
+			err:          NotFound("chirp_not_found", "no chirp"),
+			expectStatus: http.StatusNotFound,
+			expectCode:   "chirp_not_found",
+		},
+		"wrapped api error": {
+			err:          errors.Join(errors.New("context"), Conflict("email_taken", "taken")),
+			expectStatus: http.StatusConflict,
+			expectCode:   "email_taken",
+		},
+		"plain error": {
+			err:          errors.New("connection refused"),
+			expectStatus: http.StatusInternalServerError,
+			expectCode:   CodeInternal,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/chirps/123", nil)
+			req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+			rec := httptest.NewRecorder()
+
+			Write(rec, req, c.err)
+
+			if rec.Code != c.expectStatus {
+				t.Errorf("expected %v, got %v", c.expectStatus, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+				t.Errorf("expected %v, got %v", ContentType, ct)
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("error decoding problem: %v", err)
+			}
+			if problem.Code != c.expectCode || problem.Status != c.expectStatus {
+				t.Errorf("expected %v %v, got %v %v", c.expectStatus, c.expectCode, problem.Status, problem.Code)
+			}
+			if problem.Instance != "/api/chirps/123" || problem.RequestID != "req-1" {
+				t.Errorf("unexpected instance or request ID in %+v", problem)
+			}
+		})
+	}
+}
+
+func TestWrite_HidesCause(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, httptest.NewRequest("GET", "/", nil), Internal(errors.New("password=hunter2")))
+
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("expected the cause of the error not to be sent, got %v", rec.Body.String())
+	}
+}
+
+func TestValidation(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, httptest.NewRequest("POST", "/api/chirps", nil), Validation(FieldError{
+		Field:   "body",
+		Code:    "too_long",
+		Message: "must be at most 140 characters",
+	}))
+
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("error decoding problem: %v", err)
+	}
+	if problem.Code != CodeValidationFailed || len(problem.Errors) != 1 || problem.Errors[0].Field != "body" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+}
+
+func TestInvalidJSON(t *testing.T) {
+	if err := InvalidJSON(errors.New("unexpected EOF")); err.Status != http.StatusBadRequest || err.Code != CodeInvalidJSON {
+		t.Errorf("expected %v %v, got %v %v", http.StatusBadRequest, CodeInvalidJSON, err.Status, err.Code)
+	}
+
+	tooLarge := InvalidJSON(&http.MaxBytesError{Limit: 1024})
+	if tooLarge.Status != http.StatusRequestEntityTooLarge || tooLarge.Code != CodeBodyTooLarge {
+		t.Errorf("expected %v %v, got %v %v", http.StatusRequestEntityTooLarge, CodeBodyTooLarge, tooLarge.Status, tooLarge.Code)
+	}
+}
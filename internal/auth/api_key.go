@@ -2,7 +2,6 @@ package auth
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 )
@@ -15,7 +14,5 @@ func GetAPIKey(headers http.Header) (string, error) {
 	}
 
 	apiKey := strings.TrimSpace(strings.ReplaceAll(auth, "ApiKey", ""))
-	log.Printf("apiKey: %v ", apiKey)
-
 	return apiKey, nil
 }
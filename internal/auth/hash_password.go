@@ -1,36 +1,128 @@
 package auth
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 
 	"github.com/alexedwards/argon2id"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a plain text password using Argon2id.
+// tracer records spans around hashing, which dominates login latency
+var tracer = otel.Tracer("github.com/luis-octavius/chirpy/internal/auth")
+
+// ErrUnknownHashFormat is returned for stored hashes that are neither
+// Argon2id nor bcrypt
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher hashes passwords with Argon2id.
+//
 // Argon2id is a memory-hard password hashing algorithm that provides
-// protection against GPU and side-channel attacks.
+// protection against GPU and side-channel attacks. Each hash holds
+// Params.Memory KiB while it runs, so at most Workers hashes run at
+// once and the others wait for a free worker.
+type Hasher struct {
+	params  argon2id.Params
+	workers chan struct{}
+}
+
+// NewHasher returns a Hasher creating hashes with params and running
+// at most workers of them at once
+func NewHasher(params argon2id.Params, workers int) *Hasher {
+	return &Hasher{
+		params:  params,
+		workers: make(chan struct{}, max(workers, 1)),
+	}
+}
+
+// defaultHasher backs HashPassword and CheckPasswordHash
+var defaultHasher = NewHasher(*argon2id.DefaultParams, runtime.NumCPU())
+
+// HashPassword hashes a password with the default Argon2id parameters.
 //
 // Returns the hashed password or an error if hashing fails.
-func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+func HashPassword(ctx context.Context, password string) (string, error) {
+	return defaultHasher.Hash(ctx, password)
+}
+
+// CheckPasswordHash compares a plain text password with a hashed password.
+//
+// Returns true if the password matches the hash, false otherwise.
+// Returns an error if the comparison process fails.
+func CheckPasswordHash(ctx context.Context, password, hash string) (bool, error) {
+	match, _, err := defaultHasher.Check(ctx, password, hash)
+	return match, err
+}
+
+// Hash hashes password with the parameters of h, waiting for a free
+// worker until ctx is done
+func (h *Hasher) Hash(ctx context.Context, password string) (string, error) {
+	ctx, span := tracer.Start(ctx, "auth.HashPassword")
+	defer span.End()
+
+	release, err := h.acquire(ctx)
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
+	defer release()
+
+	hash, err := argon2id.CreateHash(password, &h.params)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
 
-	return hash, err
+	return hash, nil
 }
 
-// CheckPasswordHash compares a plain text password with a hashed password.
+// Check compares password with a stored hash. Besides Argon2id hashes
+// it accepts the bcrypt hashes of earlier versions of Chirpy.
 //
-// Returns true if the password matches the hash, false otherwise.
-// Returns an error if the comparison process fails.
-func CheckPasswordHash(password, hash string) (bool, error) {
-	match, err := argon2id.ComparePasswordAndHash(password, hash)
+// rehash reports that the password matches but the hash uses a legacy
+// algorithm or other parameters than h, so it should be replaced with
+// a new hash while the plain password is known.
+func (h *Hasher) Check(ctx context.Context, password, hash string) (match, rehash bool, err error) {
+	ctx, span := tracer.Start(ctx, "auth.CheckPasswordHash")
+	defer span.End()
+
+	release, err := h.acquire(ctx)
 	if err != nil {
-		log.Fatal(err)
-		return match, err
+		return false, false, err
 	}
+	defer release()
 
-	return match, err
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		match, params, err := argon2id.CheckHash(password, hash)
+		if err != nil {
+			return false, false, fmt.Errorf("error checking password: %w", err)
+		}
+		return match, match && *params != h.params, nil
+
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("error checking password: %w", err)
+		}
+		return true, true, nil
+
+	default:
+		return false, false, ErrUnknownHashFormat
+	}
+}
+
+// acquire waits for a free worker, the returned func releases it
+func (h *Hasher) acquire(ctx context.Context) (func(), error) {
+	select {
+	case h.workers <- struct{}{}:
+		return func() { <-h.workers }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error waiting for a hashing worker: %w", ctx.Err())
+	}
 }
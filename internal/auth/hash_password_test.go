@@ -1,18 +1,24 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCheckPasswordHash_Success(t *testing.T) {
 	password := "hakuna matata"
 
-	hashedPassword, err := HashPassword(password)
+	hashedPassword, err := HashPassword(context.Background(), password)
 	if err != nil {
 		t.Fatalf("HashPassword returned error: %v", err)
 	}
 
-	match, err := CheckPasswordHash(password, hashedPassword)
+	match, err := CheckPasswordHash(context.Background(), password, hashedPassword)
 	if err != nil {
 		t.Fatalf("CheckPasswordHash returned error: %v", err)
 	}
@@ -25,12 +31,12 @@ func TestCheckPasswordHash_Success(t *testing.T) {
 func TestCheckPasswordHash_Fail(t *testing.T) {
 	password := "playstation"
 
-	hashedPassword, err := HashPassword("pumba la pumba")
+	hashedPassword, err := HashPassword(context.Background(), "pumba la pumba")
 	if err != nil {
 		t.Fatalf("HashPassword returned error: %v", err)
 	}
 
-	match, err := CheckPasswordHash(password, hashedPassword)
+	match, err := CheckPasswordHash(context.Background(), password, hashedPassword)
 	if err != nil {
 		t.Fatalf("CheckPassword returned error: %v", err)
 	}
@@ -39,3 +45,78 @@ func TestCheckPasswordHash_Fail(t *testing.T) {
 		t.Errorf("expected match to be %v, got %v", false, match)
 	}
 }
+
+// testParams keep hashing cheap in tests
+var testParams = argon2id.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasherCheck_Rehash(t *testing.T) {
+	ctx := context.Background()
+	hasher := NewHasher(testParams, 1)
+
+	current, err := hasher.Hash(ctx, "hakuna matata")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	outdatedParams := testParams
+	outdatedParams.Iterations = 2
+	outdated, err := NewHasher(outdatedParams, 1).Hash(ctx, "hakuna matata")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hakuna matata"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword returned error: %v", err)
+	}
+
+	cases := []struct {
+		name         string
+		password     string
+		hash         string
+		expectMatch  bool
+		expectRehash bool
+	}{
+		{name: "current parameters", password: "hakuna matata", hash: current, expectMatch: true},
+		{name: "outdated parameters", password: "hakuna matata", hash: outdated, expectMatch: true, expectRehash: true},
+		{name: "outdated parameters, wrong password", password: "pumba", hash: outdated},
+		{name: "bcrypt", password: "hakuna matata", hash: string(legacy), expectMatch: true, expectRehash: true},
+		{name: "bcrypt, wrong password", password: "pumba", hash: string(legacy)},
+	}
+
+	for _, c := range cases {
+		match, rehash, err := hasher.Check(ctx, c.password, c.hash)
+		if err != nil {
+			t.Fatalf("%v: Check returned error: %v", c.name, err)
+		}
+
+		if match != c.expectMatch || rehash != c.expectRehash {
+			t.Errorf("%v: expected match %v and rehash %v, got %v and %v", c.name, c.expectMatch, c.expectRehash, match, rehash)
+		}
+	}
+}
+
+func TestHasherCheck_UnknownFormat(t *testing.T) {
+	_, _, err := NewHasher(testParams, 1).Check(context.Background(), "hakuna matata", "5f4dcc3b5aa765d61d8327deb882cf99")
+	if !errors.Is(err, ErrUnknownHashFormat) {
+		t.Errorf("expected %v, got %v", ErrUnknownHashFormat, err)
+	}
+}
+
+func TestHasher_BoundsWorkers(t *testing.T) {
+	hasher := NewHasher(testParams, 1)
+
+	// hold the only worker
+	release, err := hasher.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire returned error: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := hasher.Hash(ctx, "hakuna matata"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
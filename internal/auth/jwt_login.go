@@ -22,9 +22,15 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
+	return validateJWT(tokenString, func(token *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
 	})
+}
+
+func validateJWT(tokenString string, keyFunc jwt.Keyfunc) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+	)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("error parsing token: %w", err)
 	}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningKey is an HMAC secret used to sign JWTs, identified by the
+// "kid" header of the tokens it signs
+type SigningKey struct {
+	ID     string
+	Secret string
+}
+
+// Keyring signs JWTs with the current key and validates them with any
+// key that has not been retired for longer than a token lifetime, so
+// the signing key can be rotated without logging everybody out.
+//
+// Tokens without a "kid" header, issued before rotation existed, are
+// validated with the fallback secret until it is retired like any
+// other key, one token lifetime after the first rotation.
+//
+// A token signed by a key another server just rotated in has a "kid"
+// the keyring does not know yet, the keys are then reloaded with the
+// function given to OnUnknownKey, at most once per interval.
+type Keyring struct {
+	mu             sync.RWMutex
+	current        SigningKey
+	keys           map[string]string
+	fallback       string
+	acceptFallback bool
+
+	// reloadMu serializes the reloads, lastReload is the time of the
+	// last one to rate limit them
+	reloadMu       sync.Mutex
+	reload         func() error
+	reloadInterval time.Duration
+	lastReload     time.Time
+}
+
+// NewKeyring returns a keyring that signs with the fallback secret
+// until keys are loaded with Replace
+func NewKeyring(fallback string) *Keyring {
+	return &Keyring{
+		current:        SigningKey{Secret: fallback},
+		keys:           map[string]string{},
+		fallback:       fallback,
+		acceptFallback: true,
+	}
+}
+
+// OnUnknownKey sets the function reloading the keys when a token has
+// an unknown "kid", it is called at most once per interval
+func (k *Keyring) OnUnknownKey(reload func() error, interval time.Duration) {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	k.reload = reload
+	k.reloadInterval = interval
+}
+
+// Replace swaps the keys of the keyring, the first key becomes the
+// signing key and all of them are accepted for validation. An empty
+// list goes back to signing with the fallback secret. Tokens without a
+// "kid" are only accepted with acceptFallback.
+func (k *Keyring) Replace(keys []SigningKey, acceptFallback bool) {
+	byID := make(map[string]string, len(keys))
+	for _, key := range keys {
+		byID[key.ID] = key.Secret
+	}
+
+	current := SigningKey{Secret: k.fallback}
+	if len(keys) > 0 {
+		current = keys[0]
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.current = current
+	k.keys = byID
+	k.acceptFallback = acceptFallback
+}
+
+// CurrentKeyID returns the ID of the key used to sign new tokens,
+// empty when signing with the fallback secret
+func (k *Keyring) CurrentKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current.ID
+}
+
+// MakeJWT signs an access token for userID with the current key
+func (k *Keyring) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+	if current.ID != "" {
+		token.Header["kid"] = current.ID
+	}
+
+	return token.SignedString([]byte(current.Secret))
+}
+
+// ValidateJWT validates a token signed by any key of the keyring and
+// returns the user ID in its subject
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	return validateJWT(tokenString, k.secretFor)
+}
+
+func (k *Keyring) secretFor(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	secret, ok := k.lookup(kid)
+	if !ok && kid != "" && k.reloadKeys() {
+		secret, ok = k.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return []byte(secret), nil
+}
+
+// lookup returns the secret of the key kid, the fallback secret for an
+// empty kid
+func (k *Keyring) lookup(kid string) (string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" {
+		return k.fallback, k.acceptFallback
+	}
+
+	secret, ok := k.keys[kid]
+	return secret, ok
+}
+
+// reloadKeys reloads the keys unless they were reloaded within the
+// reload interval, and reports whether the key is worth looking up
+// again. Callers arriving during a reload wait for it.
+func (k *Keyring) reloadKeys() bool {
+	k.reloadMu.Lock()
+	defer k.reloadMu.Unlock()
+
+	if k.reload == nil {
+		return false
+	}
+	if time.Since(k.lastReload) < k.reloadInterval {
+		return true
+	}
+
+	k.lastReload = time.Now()
+	return k.reload() == nil
+}
+
+// NewSigningKey generates a random signing key
+func NewSigningKey() (SigningKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return SigningKey{}, fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	return SigningKey{
+		ID:     uuid.NewString(),
+		Secret: hex.EncodeToString(secret),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyring_Rotation(t *testing.T) {
+	id := uuid.New()
+	keyring := NewKeyring("fallback secret")
+
+	oldKey, err := NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+	keyring.Replace([]SigningKey{oldKey}, true)
+
+	oldToken, err := keyring.MakeJWT(id, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	newKey, err := NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+	keyring.Replace([]SigningKey{newKey, oldKey}, true)
+
+	if keyring.CurrentKeyID() != newKey.ID {
+		t.Errorf("expected current key %v, got %v", newKey.ID, keyring.CurrentKeyID())
+	}
+
+	validatedUUID, err := keyring.ValidateJWT(oldToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error for a token signed by a retired key: %v", err)
+	}
+
+	if validatedUUID != id {
+		t.Errorf("expected id %v, got %v", id, validatedUUID)
+	}
+
+	keyring.Replace([]SigningKey{newKey}, true)
+
+	if _, err := keyring.ValidateJWT(oldToken); err == nil {
+		t.Errorf("expected error validating a token signed by a dropped key, got nil")
+	}
+}
+
+func TestKeyring_LegacyToken(t *testing.T) {
+	id := uuid.New()
+	keyring := NewKeyring("fallback secret")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+	keyring.Replace([]SigningKey{key}, true)
+
+	legacyToken, err := MakeJWT(id, "fallback secret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	validatedUUID, err := keyring.ValidateJWT(legacyToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error for a legacy token: %v", err)
+	}
+
+	if validatedUUID != id {
+		t.Errorf("expected id %v, got %v", id, validatedUUID)
+	}
+
+	// one token lifetime after the first rotation, SECRET is retired
+	keyring.Replace([]SigningKey{key}, false)
+
+	if _, err := keyring.ValidateJWT(legacyToken); err == nil {
+		t.Errorf("expected error validating a legacy token once the fallback is retired, got nil")
+	}
+}
+
+func TestKeyring_UnknownKeyReload(t *testing.T) {
+	id := uuid.New()
+	keyring := NewKeyring("fallback secret")
+
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+
+	// another server picked up a new key and signed a token with it
+	other := NewKeyring("fallback secret")
+	other.Replace([]SigningKey{key}, true)
+	token, err := other.MakeJWT(id, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	reloads := 0
+	keyring.OnUnknownKey(func() error {
+		reloads++
+		keyring.Replace([]SigningKey{key}, true)
+		return nil
+	}, time.Hour)
+
+	validatedUUID, err := keyring.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error for a token signed by a key rotated elsewhere: %v", err)
+	}
+	if validatedUUID != id {
+		t.Errorf("expected id %v, got %v", id, validatedUUID)
+	}
+
+	// forged key IDs do not hammer the database
+	forger := NewKeyring("fallback secret")
+	forged, _ := NewSigningKey()
+	forger.Replace([]SigningKey{forged}, true)
+	for range 3 {
+		forgedToken, err := forger.MakeJWT(id, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeJWT returned error: %v", err)
+		}
+		if _, err := keyring.ValidateJWT(forgedToken); err == nil {
+			t.Errorf("expected error validating a token signed by an unknown key, got nil")
+		}
+	}
+
+	if reloads != 1 {
+		t.Errorf("expected 1 reload within the interval, got %v", reloads)
+	}
+}
@@ -0,0 +1,72 @@
+// Package chirptext measures and normalizes the bodies of chirps.
+//
+// Lengths count what users see as characters, grapheme clusters, so an
+// accented letter or an emoji made of several code points counts once,
+// and every URL counts as the same fixed weight whatever its size.
+// Bodies are stored in Unicode NFC, so the same text typed on different
+// keyboards is stored, filtered and measured the same way.
+package chirptext
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// urlPattern matches the http and https URLs of a body, up to the next
+// whitespace
+var urlPattern = regexp.MustCompile(`https?://\S+`)
+
+// MaxURLLength is the longest URL a body may hold, in bytes. Length
+// counts a URL as a fixed weight whatever its size, so it is capped
+// apart.
+const MaxURLLength = 2048
+
+// Normalize returns body in Unicode Normalization Form C
+func Normalize(body string) string {
+	return norm.NFC.String(body)
+}
+
+// Length returns the length of body in grapheme clusters, counting
+// every URL as urlLength
+func Length(body string, urlLength int) int {
+	urls := urlPattern.FindAllStringIndex(body, -1)
+
+	length := len(urls) * urlLength
+	start := 0
+	for _, url := range urls {
+		length += uniseg.GraphemeClusterCount(body[start:url[0]])
+		start = url[1]
+	}
+	length += uniseg.GraphemeClusterCount(body[start:])
+
+	return length
+}
+
+// LongestURL returns the length in bytes of the longest URL of body,
+// 0 when it has none
+func LongestURL(body string) int {
+	longest := 0
+	for _, url := range urlPattern.FindAllString(body, -1) {
+		longest = max(longest, len(url))
+	}
+
+	return longest
+}
+
+// URLs returns the URLs of body in order of appearance, without
+// duplicates nor the punctuation that ends the sentence around them
+func URLs(body string) []string {
+	var urls []string
+	for _, url := range urlPattern.FindAllString(body, -1) {
+		url = strings.TrimRight(url, `.,;:!?'")]}>`)
+		if !strings.HasSuffix(url, "://") && !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
@@ -0,0 +1,95 @@
+package chirptext
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		expect int
+	}{
+		{"ascii", "say my name", 11},
+		{"accents", "ação é você", 11},
+		{"decomposed accent", "a\u0301", 1},
+		{"emoji", "👍🏽 ok", 4},
+		{"family emoji", "👨‍👩‍👧‍👦", 1},
+		{"flag", "🇧🇷", 1},
+		{"url", "see https://example.com/a/very/long/path?with=query", 4 + 23},
+		{"two urls", "http://a.io and https://b.io", 23 + 5 + 23},
+		{"scheme only", "https:// alone", 14},
+		{"empty", "", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Length(c.body, 23); got != c.expect {
+				t.Errorf("expected %v, got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestLength_Portuguese(t *testing.T) {
+	// 140 accented characters are 280 bytes long
+	body := strings.Repeat("ã", 140)
+	if got := Length(body, 23); got != 140 {
+		t.Errorf("expected 140, got %v", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	decomposed := "voce\u0302"
+	if got := Normalize(decomposed); got != "você" {
+		t.Errorf("expected the composed form, got %q", got)
+	}
+	if got := Normalize("você"); got != "você" {
+		t.Errorf("expected NFC text to be unchanged, got %q", got)
+	}
+}
+
+func TestLongestURL(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		expect int
+	}{
+		{"none", "say my name", 0},
+		{"one", "see https://a.io now", 12},
+		{"longest", "http://a.io and https://example.com", 19},
+		{"long", "see https://a.io/" + strings.Repeat("a", 5000), 5013},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := LongestURL(c.body); got != c.expect {
+				t.Errorf("expected %v, got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestURLs(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		expect []string
+	}{
+		{"none", "say my name", nil},
+		{"one", "see https://example.com/a?b=c now", []string{"https://example.com/a?b=c"}},
+		{"punctuation", "(http://a.io), https://b.io/x.", []string{"http://a.io", "https://b.io/x"}},
+		{"duplicates", "https://a.io https://a.io!", []string{"https://a.io"}},
+		{"scheme only", "https://. alone", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := URLs(c.body); !slices.Equal(got, c.expect) {
+				t.Errorf("expected %q, got %q", c.expect, got)
+			}
+		})
+	}
+}
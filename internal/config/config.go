@@ -0,0 +1,610 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Platforms accepted in PLATFORM
+const (
+	PlatformDev  = "dev"
+	PlatformProd = "prod"
+)
+
+// Database backends accepted in DB_BACKEND
+const (
+	BackendPostgres = "postgres"
+	BackendSQLite   = "sqlite"
+)
+
+// MinSecretLength is the minimum length of the JWT signing secret,
+// 32 bytes matches the output size of HS256
+const MinSecretLength = 32
+
+// MaxPasswordLength caps PASSWORD_MAX_LENGTH, so a client cannot make
+// the server hash arbitrarily long passwords
+const MaxPasswordLength = 1024
+
+// MaxDraftLength is the length of the longest draft and caps
+// CHIRP_MAX_LENGTH_RED, so every chirp a user can publish fits in a draft
+const MaxDraftLength = 1000
+
+// Caps of the Argon2 parameters, they fit the uint32 of argon2id and
+// keep a misconfigured hash from holding a login for minutes
+const (
+	MaxArgon2Memory     = 4 * 1024 * 1024 // 4 GiB, in KiB
+	MaxArgon2Iterations = 100
+)
+
+// Config is the whole configuration of Chirpy, loaded and validated
+// once at startup
+type Config struct {
+	Platform      string
+	DatabaseURL   string
+	JWTSecret     string
+	PolkaKey      string
+	LogLevel      slog.Level
+	TraceExporter string
+
+	// DatabaseBackend is postgres or sqlite, DatabaseURL is then a
+	// postgres:// URL or the path of the SQLite database file
+	DatabaseBackend string
+
+	// AdminToken authenticates the /admin endpoints of the dev
+	// platform, they are disabled while it is empty
+	AdminToken string
+	// SeedFile holds the fixtures loaded by POST /admin/seed when
+	// the request has no body
+	SeedFile string
+
+	// MigrateOnStart applies pending migrations before the server
+	// starts, it can also be set with --migrate-on-start
+	MigrateOnStart bool
+
+	// HealthCheckTimeout bounds each dependency check of the
+	// readiness endpoint
+	HealthCheckTimeout time.Duration
+
+	HTTP         HTTP
+	DB           DB
+	Password     Password
+	Accounts     Accounts
+	Moderation   Moderation
+	Chirps       Chirps
+	LinkPreviews LinkPreviews
+}
+
+// HTTP holds the tunables of the HTTP server
+type HTTP struct {
+	Host string
+	Port string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	MaxHeaderBytes    int
+	MaxBodyBytes      int64
+}
+
+// DB holds the tunables of the database connection pool
+type DB struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectAttempts int
+}
+
+// Password holds the policy of the passwords users choose
+type Password struct {
+	MinLength int
+	// MaxLength also bounds the cost of hashing a password
+	MaxLength int
+	// BreachFile lists the SHA-1 hashes of breached passwords, as in
+	// the Pwned Passwords downloads, none are checked while it is empty
+	BreachFile string
+
+	// Argon2Memory, in KiB, Argon2Iterations and Argon2Parallelism are
+	// the cost of new hashes, stored hashes with other parameters are
+	// replaced when their user logs in
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	// HashWorkers bounds how many hashes run at once, so concurrent
+	// logins use at most HashWorkers * Argon2Memory KiB
+	HashWorkers int
+}
+
+// Accounts holds the tunables of account deletion and data export
+type Accounts struct {
+	// DeletionGrace is how long a deleted account is kept, hidden,
+	// before it is purged with its data
+	DeletionGrace time.Duration
+	// PurgeInterval is how often accounts past their grace period are
+	// looked for
+	PurgeInterval time.Duration
+	// ExportSyncChirps is the number of chirps above which data exports
+	// are generated in the background instead of in the request
+	ExportSyncChirps int
+}
+
+// Moderation holds the tunables of chirp reports
+type Moderation struct {
+	// HideThreshold is the number of users reporting a chirp above
+	// which it is hidden until a moderator reviews it
+	HideThreshold int
+}
+
+// Chirps holds the tunables of chirp length, deletion and scheduling
+type Chirps struct {
+	// MaxLength and MaxLengthRed bound the length of the chirps of
+	// free and Chirpy Red users, in grapheme clusters with every URL
+	// counted as URLLength
+	MaxLength    int
+	MaxLengthRed int
+	URLLength    int
+	// MaxBodyBytes caps the size of the body of chirps and drafts,
+	// whatever their length, as URLs and clusters of many code points
+	// are short for their size
+	MaxBodyBytes int
+
+	// UndoWindow is how long the author of a deleted chirp can
+	// restore it
+	UndoWindow time.Duration
+	// Retention is how long a deleted chirp is kept, hidden, before
+	// it is purged
+	Retention time.Duration
+	// PurgeInterval is how often chirps past their retention are
+	// looked for
+	PurgeInterval time.Duration
+	// SchedulerInterval is how often scheduled chirps that are due
+	// are looked for, it bounds how late they are published
+	SchedulerInterval time.Duration
+}
+
+// LinkPreviews holds the tunables of the fetching of the previews of
+// the URLs linked by chirps
+type LinkPreviews struct {
+	// Enabled turns the fetching on, without it chirps are shown
+	// without previews
+	Enabled bool
+	// Timeout bounds the fetch of a page, redirects included
+	Timeout time.Duration
+	// MaxBytes is the most of a page that is read
+	MaxBytes     int64
+	MaxRedirects int
+	// Interval is how often pending previews are looked for, new
+	// chirps also wake the fetcher up
+	Interval time.Duration
+}
+
+// Addr returns the address the server listens on
+func (h HTTP) Addr() string {
+	return net.JoinHostPort(h.Host, h.Port)
+}
+
+// IsDev reports whether Chirpy runs on a development platform
+func (c Config) IsDev() bool {
+	return c.Platform == PlatformDev
+}
+
+// Default returns the configuration used for every value that is not
+// set anywhere else
+func Default() Config {
+	return Config{
+		Platform:        PlatformProd,
+		DatabaseBackend: BackendPostgres,
+		LogLevel:        slog.LevelInfo,
+		TraceExporter:   "none",
+
+		HealthCheckTimeout: 2 * time.Second,
+
+		HTTP: HTTP{
+			Port:              "8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxHeaderBytes:    1 << 20, // 1 MiB
+			MaxBodyBytes:      1 << 20, // 1 MiB
+		},
+		DB: DB{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectAttempts: 5,
+		},
+		Password: Password{
+			MinLength:         8,
+			MaxLength:         128,
+			Argon2Memory:      64 * 1024, // 64 MiB
+			Argon2Iterations:  1,
+			Argon2Parallelism: 2,
+			HashWorkers:       4,
+		},
+		Accounts: Accounts{
+			DeletionGrace:    30 * 24 * time.Hour,
+			PurgeInterval:    time.Hour,
+			ExportSyncChirps: 1000,
+		},
+		Moderation: Moderation{
+			HideThreshold: 5,
+		},
+		Chirps: Chirps{
+			MaxLength:         140,
+			MaxLengthRed:      280,
+			URLLength:         23,
+			MaxBodyBytes:      16 << 10,
+			UndoWindow:        5 * time.Minute,
+			Retention:         30 * 24 * time.Hour,
+			PurgeInterval:     time.Hour,
+			SchedulerInterval: 10 * time.Second,
+		},
+		LinkPreviews: LinkPreviews{
+			Enabled:      true,
+			Timeout:      5 * time.Second,
+			MaxBytes:     512 << 10,
+			MaxRedirects: 3,
+			Interval:     time.Minute,
+		},
+	}
+}
+
+// Load reads the configuration and validates it.
+//
+// Each value is looked up, from highest to lowest precedence, in:
+//   - the environment, where .env is loaded without overriding
+//     variables that are already set
+//   - a file named after the variable plus the _FILE suffix, such as
+//     SECRET_FILE=/run/secrets/jwt, for Docker and Kubernetes secrets
+//   - the JSON config file named by CONFIG_FILE, an object using the
+//     same keys as the environment, e.g. {"PORT": "9090"}
+//   - the defaults from Default
+//
+// All invalid or missing values are reported together.
+func Load() (Config, error) {
+	godotenv.Load()
+
+	file, err := readConfigFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	l := loader{file: file}
+	cfg := Default()
+
+	cfg.Platform = l.string("PLATFORM", cfg.Platform)
+	cfg.DatabaseBackend = l.string("DB_BACKEND", cfg.DatabaseBackend)
+	cfg.DatabaseURL = l.string("DB_URL", cfg.DatabaseURL)
+	cfg.JWTSecret = l.string("SECRET", cfg.JWTSecret)
+	cfg.PolkaKey = l.string("POLKA_KEY", cfg.PolkaKey)
+	cfg.TraceExporter = l.string("OTEL_TRACES_EXPORTER", cfg.TraceExporter)
+	cfg.AdminToken = l.string("ADMIN_TOKEN", cfg.AdminToken)
+	cfg.SeedFile = l.string("SEED_FILE", cfg.SeedFile)
+	l.level("LOG_LEVEL", &cfg.LogLevel)
+	l.duration("HEALTH_CHECK_TIMEOUT", &cfg.HealthCheckTimeout)
+	l.bool("MIGRATE_ON_START", &cfg.MigrateOnStart)
+
+	cfg.HTTP.Host = l.string("HOST", cfg.HTTP.Host)
+	cfg.HTTP.Port = l.string("PORT", cfg.HTTP.Port)
+	l.duration("READ_TIMEOUT", &cfg.HTTP.ReadTimeout)
+	l.duration("READ_HEADER_TIMEOUT", &cfg.HTTP.ReadHeaderTimeout)
+	l.duration("WRITE_TIMEOUT", &cfg.HTTP.WriteTimeout)
+	l.duration("IDLE_TIMEOUT", &cfg.HTTP.IdleTimeout)
+	l.duration("SHUTDOWN_TIMEOUT", &cfg.HTTP.ShutdownTimeout)
+	l.int("MAX_HEADER_BYTES", &cfg.HTTP.MaxHeaderBytes)
+	l.int64("MAX_BODY_BYTES", &cfg.HTTP.MaxBodyBytes)
+
+	l.int("DB_MAX_OPEN_CONNS", &cfg.DB.MaxOpenConns)
+	l.int("DB_MAX_IDLE_CONNS", &cfg.DB.MaxIdleConns)
+	l.duration("DB_CONN_MAX_LIFETIME", &cfg.DB.ConnMaxLifetime)
+	l.duration("DB_CONN_MAX_IDLE_TIME", &cfg.DB.ConnMaxIdleTime)
+	l.int("DB_CONNECT_ATTEMPTS", &cfg.DB.ConnectAttempts)
+
+	l.int("PASSWORD_MIN_LENGTH", &cfg.Password.MinLength)
+	l.int("PASSWORD_MAX_LENGTH", &cfg.Password.MaxLength)
+	cfg.Password.BreachFile = l.string("PASSWORD_BREACH_FILE", cfg.Password.BreachFile)
+	l.int("ARGON2_MEMORY", &cfg.Password.Argon2Memory)
+	l.int("ARGON2_ITERATIONS", &cfg.Password.Argon2Iterations)
+	l.int("ARGON2_PARALLELISM", &cfg.Password.Argon2Parallelism)
+	l.int("PASSWORD_HASH_WORKERS", &cfg.Password.HashWorkers)
+
+	l.duration("ACCOUNT_DELETION_GRACE", &cfg.Accounts.DeletionGrace)
+	l.duration("ACCOUNT_PURGE_INTERVAL", &cfg.Accounts.PurgeInterval)
+	l.int("EXPORT_SYNC_CHIRPS", &cfg.Accounts.ExportSyncChirps)
+
+	l.int("MODERATION_HIDE_THRESHOLD", &cfg.Moderation.HideThreshold)
+
+	l.int("CHIRP_MAX_LENGTH", &cfg.Chirps.MaxLength)
+	l.int("CHIRP_MAX_LENGTH_RED", &cfg.Chirps.MaxLengthRed)
+	l.int("CHIRP_URL_LENGTH", &cfg.Chirps.URLLength)
+	l.int("CHIRP_MAX_BODY_BYTES", &cfg.Chirps.MaxBodyBytes)
+	l.duration("CHIRP_UNDO_WINDOW", &cfg.Chirps.UndoWindow)
+	l.duration("CHIRP_RETENTION", &cfg.Chirps.Retention)
+	l.duration("CHIRP_PURGE_INTERVAL", &cfg.Chirps.PurgeInterval)
+	l.duration("CHIRP_SCHEDULER_INTERVAL", &cfg.Chirps.SchedulerInterval)
+
+	l.bool("LINK_PREVIEWS_ENABLED", &cfg.LinkPreviews.Enabled)
+	l.duration("LINK_PREVIEW_TIMEOUT", &cfg.LinkPreviews.Timeout)
+	l.int64("LINK_PREVIEW_MAX_BYTES", &cfg.LinkPreviews.MaxBytes)
+	l.int("LINK_PREVIEW_MAX_REDIRECTS", &cfg.LinkPreviews.MaxRedirects)
+	l.duration("LINK_PREVIEW_INTERVAL", &cfg.LinkPreviews.Interval)
+
+	if err := errors.Join(append(l.errs, cfg.Validate())...); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that every required value is set and well formed
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Platform != PlatformDev && c.Platform != PlatformProd {
+		errs = append(errs, fmt.Errorf("PLATFORM: must be %q or %q, got %q", PlatformDev, PlatformProd, c.Platform))
+	}
+
+	switch c.DatabaseBackend {
+	case BackendPostgres:
+		if c.DatabaseURL == "" {
+			errs = append(errs, errors.New("DB_URL: required"))
+		} else if u, err := url.Parse(c.DatabaseURL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			errs = append(errs, errors.New("DB_URL: must be a postgres:// URL"))
+		}
+	case BackendSQLite:
+		if c.DatabaseURL == "" {
+			errs = append(errs, errors.New("DB_URL: required, the path of the SQLite database file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DB_BACKEND: must be %q or %q, got %q", BackendPostgres, BackendSQLite, c.DatabaseBackend))
+	}
+
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("SECRET: required"))
+	} else if len(c.JWTSecret) < MinSecretLength {
+		errs = append(errs, fmt.Errorf("SECRET: must be at least %d characters long", MinSecretLength))
+	}
+
+	if c.PolkaKey == "" {
+		errs = append(errs, errors.New("POLKA_KEY: required"))
+	}
+
+	switch c.TraceExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER: must be none, stdout or otlp, got %q", c.TraceExporter))
+	}
+
+	if port, err := strconv.Atoi(c.HTTP.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: must be a number between 1 and 65535, got %q", c.HTTP.Port))
+	}
+
+	if c.HTTP.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES: must be positive"))
+	}
+
+	if c.HealthCheckTimeout <= 0 {
+		errs = append(errs, errors.New("HEALTH_CHECK_TIMEOUT: must be positive"))
+	}
+
+	if c.DB.ConnectAttempts < 1 {
+		errs = append(errs, errors.New("DB_CONNECT_ATTEMPTS: must be at least 1"))
+	}
+
+	if c.Password.MinLength < 1 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH: must be at least 1"))
+	}
+	if c.Password.MaxLength < c.Password.MinLength || c.Password.MaxLength > MaxPasswordLength {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_LENGTH: must be between PASSWORD_MIN_LENGTH and %d", MaxPasswordLength))
+	}
+
+	// argon2 needs at least 8 KiB of memory per lane
+	if c.Password.Argon2Parallelism < 1 || c.Password.Argon2Parallelism > 255 {
+		errs = append(errs, errors.New("ARGON2_PARALLELISM: must be between 1 and 255"))
+	} else if c.Password.Argon2Memory < 8*c.Password.Argon2Parallelism || c.Password.Argon2Memory > MaxArgon2Memory {
+		errs = append(errs, fmt.Errorf("ARGON2_MEMORY: must be between 8 KiB per ARGON2_PARALLELISM lane and %d KiB", MaxArgon2Memory))
+	}
+	if c.Password.Argon2Iterations < 1 || c.Password.Argon2Iterations > MaxArgon2Iterations {
+		errs = append(errs, fmt.Errorf("ARGON2_ITERATIONS: must be between 1 and %d", MaxArgon2Iterations))
+	}
+	if c.Password.HashWorkers < 1 {
+		errs = append(errs, errors.New("PASSWORD_HASH_WORKERS: must be at least 1"))
+	}
+
+	if c.Accounts.DeletionGrace < 0 {
+		errs = append(errs, errors.New("ACCOUNT_DELETION_GRACE: must not be negative"))
+	}
+	if c.Accounts.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("ACCOUNT_PURGE_INTERVAL: must be positive"))
+	}
+	if c.Accounts.ExportSyncChirps < 0 {
+		errs = append(errs, errors.New("EXPORT_SYNC_CHIRPS: must not be negative"))
+	}
+
+	if c.Moderation.HideThreshold < 1 {
+		errs = append(errs, errors.New("MODERATION_HIDE_THRESHOLD: must be at least 1"))
+	}
+
+	if c.Chirps.MaxLength < 1 {
+		errs = append(errs, errors.New("CHIRP_MAX_LENGTH: must be at least 1"))
+	} else if c.Chirps.MaxLengthRed < c.Chirps.MaxLength || c.Chirps.MaxLengthRed > MaxDraftLength {
+		errs = append(errs, fmt.Errorf("CHIRP_MAX_LENGTH_RED: must be between CHIRP_MAX_LENGTH and %d", MaxDraftLength))
+	}
+	// a single URL must fit in a chirp
+	if c.Chirps.URLLength < 1 || c.Chirps.URLLength > c.Chirps.MaxLength {
+		errs = append(errs, errors.New("CHIRP_URL_LENGTH: must be between 1 and CHIRP_MAX_LENGTH"))
+	}
+	// a chirp of the longest length fits whatever UTF-8 characters
+	// it is made of
+	if c.Chirps.MaxBodyBytes < 4*c.Chirps.MaxLengthRed {
+		errs = append(errs, errors.New("CHIRP_MAX_BODY_BYTES: must be at least 4 times CHIRP_MAX_LENGTH_RED"))
+	}
+
+	// a chirp cannot be restored once it is purged
+	if c.Chirps.UndoWindow < 0 {
+		errs = append(errs, errors.New("CHIRP_UNDO_WINDOW: must not be negative"))
+	} else if c.Chirps.Retention < c.Chirps.UndoWindow {
+		errs = append(errs, errors.New("CHIRP_RETENTION: must be at least CHIRP_UNDO_WINDOW"))
+	}
+	if c.Chirps.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("CHIRP_PURGE_INTERVAL: must be positive"))
+	}
+	if c.Chirps.SchedulerInterval <= 0 {
+		errs = append(errs, errors.New("CHIRP_SCHEDULER_INTERVAL: must be positive"))
+	}
+
+	if c.LinkPreviews.Timeout <= 0 {
+		errs = append(errs, errors.New("LINK_PREVIEW_TIMEOUT: must be positive"))
+	}
+	if c.LinkPreviews.MaxBytes < 1 {
+		errs = append(errs, errors.New("LINK_PREVIEW_MAX_BYTES: must be at least 1"))
+	}
+	if c.LinkPreviews.MaxRedirects < 0 {
+		errs = append(errs, errors.New("LINK_PREVIEW_MAX_REDIRECTS: must not be negative"))
+	}
+	if c.LinkPreviews.Interval <= 0 {
+		errs = append(errs, errors.New("LINK_PREVIEW_INTERVAL: must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile reads a flat JSON object of configuration values,
+// an empty path means there is no config file
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("error parsing config file %v: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// loader looks up configuration values and collects parsing errors
+type loader struct {
+	file map[string]string
+	errs []error
+}
+
+func (l *loader) lookup(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v, true
+	}
+
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%v_FILE: %w", key, err))
+			return "", false
+		}
+		return strings.TrimSpace(string(data)), true
+	}
+
+	if v, ok := l.file[key]; ok && v != "" {
+		return v, true
+	}
+
+	return "", false
+}
+
+func (l *loader) string(key, fallback string) string {
+	if v, ok := l.lookup(key); ok {
+		return v
+	}
+	return fallback
+}
+
+func (l *loader) duration(key string, dst *time.Duration) {
+	v, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%v: invalid duration %q", key, v))
+		return
+	}
+
+	*dst = d
+}
+
+func (l *loader) int(key string, dst *int) {
+	v, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%v: invalid integer %q", key, v))
+		return
+	}
+
+	*dst = n
+}
+
+func (l *loader) int64(key string, dst *int64) {
+	v, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%v: invalid integer %q", key, v))
+		return
+	}
+
+	*dst = n
+}
+
+func (l *loader) bool(key string, dst *bool) {
+	v, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%v: invalid boolean %q", key, v))
+		return
+	}
+
+	*dst = b
+}
+
+func (l *loader) level(key string, dst *slog.Level) {
+	v, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+
+	if err := dst.UnmarshalText([]byte(v)); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%v: invalid log level %q", key, v))
+	}
+}
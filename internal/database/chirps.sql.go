@@ -7,29 +7,53 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirpsByUserID = `-- name: CountChirpsByUserID :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND deleted_at IS NULL
+`
+
+func (q *Queries) CountChirpsByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserID, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id) 
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, reply_to_id, attachments) 
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	ReplyToID   uuid.NullUUID
+	Attachments []string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		pq.Array(arg.Attachments),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,35 +61,61 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		pq.Array(&i.Attachments),
 	)
 	return i, err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :exec
+const deleteAllChirps = `-- name: DeleteAllChirps :execrows
 DELETE FROM chirps
-USING users 
-WHERE chirps.user_id = users.id 
-AND chirps.id = $1 
-AND users.id = $2
 `
 
-type DeleteChirpByIDParams struct {
-	ID   uuid.UUID
-	ID_2 uuid.UUID
+func (q *Queries) DeleteAllChirps(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAllChirps)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, arg DeleteChirpByIDParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpByID, arg.ID, arg.ID_2)
-	return err
+const deleteChirpsByUserID = `-- name: DeleteChirpsByUserID :execrows
+DELETE FROM chirps
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteChirpsByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpsByUserID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments FROM chirps
+WHERE user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $1::uuid)
+AND user_id NOT IN (SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = $1::uuid)
+AND user_id NOT IN (SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = $1::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = $1::uuid)
+AND chirps.deleted_at IS NULL
 ORDER BY created_at
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+// chirps of accounts pending deletion are hidden until they are purged,
+// and so are the chirps of authors blocking the viewer. The timeline
+// also hides the authors the viewer blocked or muted. viewer_id is the
+// nil UUID for anonymous requests, which no block or mute matches.
+// Chirps hidden by moderation are only shown to their author. Deleted
+// chirps are kept until they are purged, GetChirpByID returns them so
+// they can be told apart from the ones that never existed.
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +129,11 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			pq.Array(&i.Attachments),
 		); err != nil {
 			return nil, err
 		}
@@ -94,12 +149,20 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments FROM chirps 
+WHERE chirps.id = $1
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $2::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid)
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+type GetChirpByIDParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpByID(ctx context.Context, arg GetChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, arg.ID, arg.ViewerID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -107,17 +170,32 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		pq.Array(&i.Attachments),
 	)
 	return i, err
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments FROM chirps 
 WHERE user_id = $1
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $2::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid)
+AND chirps.deleted_at IS NULL
+ORDER BY created_at
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -131,6 +209,11 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			pq.Array(&i.Attachments),
 		); err != nil {
 			return nil, err
 		}
@@ -144,3 +227,116 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - $1::float8 * INTERVAL '1 second'
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, retentionSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, retentionSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirpByID = `-- name: RestoreChirpByID :one
+
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1
+AND user_id = $2
+AND deleted_by = user_id
+AND deleted_at > NOW() - $3::float8 * INTERVAL '1 second'
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments
+`
+
+type RestoreChirpByIDParams struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	WindowSeconds float64
+}
+
+// only the author can restore a chirp, and only when the author
+// deleted it. The cutoffs are computed from NOW() like deleted_at, so
+// they do not depend on the clock of the server.
+func (q *Queries) RestoreChirpByID(ctx context.Context, arg RestoreChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirpByID, arg.ID, arg.UserID, arg.WindowSeconds)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		pq.Array(&i.Attachments),
+	)
+	return i, err
+}
+
+const seedChirp = `-- name: SeedChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4
+)
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, deleted_by, reply_to_id, attachments
+`
+
+type SeedChirpParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) SeedChirp(ctx context.Context, arg SeedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, seedChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.Body,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		pq.Array(&i.Attachments),
+	)
+	return i, err
+}
+
+const softDeleteChirpByID = `-- name: SoftDeleteChirpByID :execrows
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $1
+WHERE id = $2
+AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpByIDParams struct {
+	DeletedBy uuid.NullUUID
+	ID        uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirpByID(ctx context.Context, arg SoftDeleteChirpByIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirpByID, arg.DeletedBy, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,12 +11,68 @@ import (
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Body        string
+	UserID      uuid.UUID
+	HiddenAt    sql.NullTime
+	DeletedAt   sql.NullTime
+	DeletedBy   uuid.NullUUID
+	ReplyToID   uuid.NullUUID
+	Attachments []string
+}
+
+type Draft struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Body        string
+	ReplyToID   uuid.NullUUID
+	Attachments []string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LinkPreview struct {
+	Url         string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Status      string
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	FetchedAt   sql.NullTime
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	ModeratorID uuid.NullUUID
+	Action      string
+	ReportID    uuid.NullUUID
+	ChirpID     uuid.NullUUID
+	UserID      uuid.NullUUID
+	Note        string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +84,36 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.NullUUID
+	AuthorID   uuid.UUID
+	ReporterID uuid.UUID
+	ChirpBody  string
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt sql.NullTime
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	PublishAt time.Time
+}
+
+type SigningKey struct {
+	ID        string
+	Secret    string
+	CreatedAt time.Time
+	RetiredAt sql.NullTime
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +121,12 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	SuspendedAt    sql.NullTime
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	Location       string
+	DeletedAt      sql.NullTime
+	IsModerator    bool
 }
@@ -7,6 +7,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +22,7 @@ VALUES (
   $1,
   $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
 `
 
 type CreateUserParams struct {
@@ -38,6 +40,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
 	)
 	return i, err
 }
@@ -51,8 +61,54 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteUserByID = `-- name: DeleteUserByID :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUserByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getStats = `-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM users) AS users,
+  (SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS chirpy_red_users,
+  (SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) AS suspended_users,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NULL) AS chirps,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NOT NULL) AS deleted_chirps,
+  (SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > NOW()) AS active_refresh_tokens
+`
+
+type GetStatsRow struct {
+	Users               int64
+	ChirpyRedUsers      int64
+	SuspendedUsers      int64
+	Chirps              int64
+	DeletedChirps       int64
+	ActiveRefreshTokens int64
+}
+
+func (q *Queries) GetStats(ctx context.Context) (GetStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getStats)
+	var i GetStatsRow
+	err := row.Scan(
+		&i.Users,
+		&i.ChirpyRedUsers,
+		&i.SuspendedUsers,
+		&i.Chirps,
+		&i.DeletedChirps,
+		&i.ActiveRefreshTokens,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator FROM users 
 WHERE email = $1
 `
 
@@ -66,12 +122,47 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator FROM users
+WHERE handle = $1
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator FROM users 
 WHERE id = $1
 `
 
@@ -85,14 +176,252 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
 	)
 	return i, err
 }
 
-const updateUserEmailAndPass = `-- name: UpdateUserEmailAndPass :exec
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() AT TIME ZONE 'UTC' - $1::float8 * INTERVAL '1 second'
+`
+
+// the cutoff is computed from NOW() like deleted_at, so it does not
+// depend on the clock of the server
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, graceSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, graceSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUserByID = `-- name: RestoreUserByID :one
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NOT NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
+`
+
+func (q *Queries) RestoreUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
+}
+
+const seedUser = `-- name: SeedUser :one
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5
+)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
+`
+
+type SeedUserParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Email          string
+	HashedPassword string
+	IsChirpyRed    bool
+}
+
+func (q *Queries) SeedUser(ctx context.Context, arg SeedUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, seedUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.Email,
+		arg.HashedPassword,
+		arg.IsChirpyRed,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
+}
+
+const setUserModerator = `-- name: SetUserModerator :execrows
+UPDATE users
+SET is_moderator = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserModeratorParams struct {
+	ID          uuid.UUID
+	IsModerator bool
+}
+
+func (q *Queries) SetUserModerator(ctx context.Context, arg SetUserModeratorParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserModerator, arg.ID, arg.IsModerator)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteUserByID = `-- name: SoftDeleteUserByID :one
+UPDATE users
+SET deleted_at = NOW() AT TIME ZONE 'UTC', updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
+`
+
+// deleted_at is stored in UTC like publish_at, so it reads back as the
+// instant of the deletion whatever the time zone of the session
+func (q *Queries) SoftDeleteUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
+}
+
+const suspendUserByID = `-- name: SuspendUserByID :execrows
+UPDATE users
+SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SuspendUserByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUserByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsuspendUserByID = `-- name: UnsuspendUserByID :execrows
+UPDATE users
+SET suspended_at = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUserByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUserByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email = $2,
+  hashed_password = $3,
+  handle = $4,
+  display_name = $5,
+  bio = $6,
+  avatar_url = $7,
+  location = $8,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
+`
+
+type UpdateUserParams struct {
+	ID             uuid.UUID
+	Email          string
+	HashedPassword string
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	Location       string
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.ID,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.Location,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
+}
+
+const updateUserEmailAndPass = `-- name: UpdateUserEmailAndPass :one
 UPDATE users 
 SET hashed_password = $1, email = $2, updated_at = NOW()
 WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, handle, display_name, bio, avatar_url, location, deleted_at, is_moderator
 `
 
 type UpdateUserEmailAndPassParams struct {
@@ -101,18 +430,57 @@ type UpdateUserEmailAndPassParams struct {
 	ID             uuid.UUID
 }
 
-func (q *Queries) UpdateUserEmailAndPass(ctx context.Context, arg UpdateUserEmailAndPassParams) error {
-	_, err := q.db.ExecContext(ctx, updateUserEmailAndPass, arg.HashedPassword, arg.Email, arg.ID)
-	return err
+func (q *Queries) UpdateUserEmailAndPass(ctx context.Context, arg UpdateUserEmailAndPassParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmailAndPass, arg.HashedPassword, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.DeletedAt,
+		&i.IsModerator,
+	)
+	return i, err
 }
 
-const upgradeUserByID = `-- name: UpgradeUserByID :exec
+const updateUserPassword = `-- name: UpdateUserPassword :execrows
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upgradeUserByID = `-- name: UpgradeUserByID :execrows
 UPDATE users 
 SET is_chirpy_red = true 
 WHERE id = $1
 `
 
-func (q *Queries) UpgradeUserByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserByID, id)
-	return err
+func (q *Queries) UpgradeUserByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeUserByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,193 @@
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/luis-octavius/chirpy/internal/auth"
+)
+
+// Formats accepted by Parse
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Fixtures is a deterministic data set for integration tests, every
+// row carries its own ID so tests can refer to it
+type Fixtures struct {
+	Users         []User         `json:"users" yaml:"users"`
+	Chirps        []Chirp        `json:"chirps" yaml:"chirps"`
+	Follows       []Follow       `json:"follows" yaml:"follows"`
+	RefreshTokens []RefreshToken `json:"refresh_tokens" yaml:"refresh_tokens"`
+}
+
+type User struct {
+	ID          uuid.UUID `json:"id" yaml:"id"`
+	Email       string    `json:"email" yaml:"email"`
+	Password    string    `json:"password" yaml:"password"`
+	IsChirpyRed bool      `json:"is_chirpy_red" yaml:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+}
+
+type Chirp struct {
+	ID        uuid.UUID `json:"id" yaml:"id"`
+	UserID    uuid.UUID `json:"user_id" yaml:"user_id"`
+	Body      string    `json:"body" yaml:"body"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id" yaml:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id" yaml:"followee_id"`
+}
+
+type RefreshToken struct {
+	Token     string    `json:"token" yaml:"token"`
+	UserID    uuid.UUID `json:"user_id" yaml:"user_id"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+	Revoked   bool      `json:"revoked" yaml:"revoked"`
+}
+
+// epoch is the creation time of rows that do not set one, a fixed
+// value keeps the ordering of chirps deterministic
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Parse decodes fixtures in the given format, rejecting unknown fields
+// so a typo in a fixture file does not silently drop data
+func Parse(data []byte, format string) (Fixtures, error) {
+	var f Fixtures
+
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return Fixtures{}, fmt.Errorf("error parsing JSON fixtures: %w", err)
+		}
+	case FormatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return Fixtures{}, fmt.Errorf("error parsing YAML fixtures: %w", err)
+		}
+	default:
+		return Fixtures{}, fmt.Errorf("unknown fixtures format %q", format)
+	}
+
+	return f, f.Validate()
+}
+
+// Validate checks that every row has the fields it needs and that
+// references point to users of the same fixtures
+func (f Fixtures) Validate() error {
+	var errs []error
+
+	users := make(map[uuid.UUID]bool, len(f.Users))
+	for i, u := range f.Users {
+		if u.ID == uuid.Nil || u.Email == "" || u.Password == "" {
+			errs = append(errs, fmt.Errorf("users[%d]: id, email and password are required", i))
+		}
+		users[u.ID] = true
+	}
+
+	for i, c := range f.Chirps {
+		if c.ID == uuid.Nil || c.Body == "" {
+			errs = append(errs, fmt.Errorf("chirps[%d]: id and body are required", i))
+		}
+		if !users[c.UserID] {
+			errs = append(errs, fmt.Errorf("chirps[%d]: unknown user %v", i, c.UserID))
+		}
+	}
+
+	for i, fl := range f.Follows {
+		if !users[fl.FollowerID] || !users[fl.FolloweeID] {
+			errs = append(errs, fmt.Errorf("follows[%d]: unknown user", i))
+		}
+	}
+
+	for i, t := range f.RefreshTokens {
+		if t.Token == "" || t.ExpiresAt.IsZero() {
+			errs = append(errs, fmt.Errorf("refresh_tokens[%d]: token and expires_at are required", i))
+		}
+		if !users[t.UserID] {
+			errs = append(errs, fmt.Errorf("refresh_tokens[%d]: unknown user %v", i, t.UserID))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reset deletes every row of the data tables, children first so the
+// foreign keys are never violated. Schema versions, signing keys and
+// the audit log of moderation, which cannot be deleted, are kept.
+func Reset(ctx context.Context, q Queries) error {
+	steps := []struct {
+		table string
+		fn    func(context.Context) error
+	}{
+		{"follows", q.DeleteAllFollows},
+		{"refresh_tokens", q.DeleteAllRefreshTokens},
+		{"chirps", q.DeleteAllChirps},
+		{"users", q.DeleteAllUsers},
+		{"link_previews", q.DeleteAllLinkPreviews},
+	}
+
+	for _, step := range steps {
+		if err := step.fn(ctx); err != nil {
+			return fmt.Errorf("error deleting %v: %w", step.table, err)
+		}
+	}
+
+	return nil
+}
+
+// Load inserts the fixtures, parents first. Passwords are hashed with
+// hasher, the one logins check them with, so seeded users log in
+// without being rehashed.
+func Load(ctx context.Context, q Queries, hasher *auth.Hasher, f Fixtures) error {
+	for _, u := range f.Users {
+		hashedPassword, err := hasher.Hash(ctx, u.Password)
+		if err != nil {
+			return fmt.Errorf("error hashing password of %v: %w", u.Email, err)
+		}
+
+		if err := q.SeedUser(ctx, u, hashedPassword); err != nil {
+			return fmt.Errorf("error inserting user %v: %w", u.Email, err)
+		}
+	}
+
+	for _, c := range f.Chirps {
+		if err := q.SeedChirp(ctx, c); err != nil {
+			return fmt.Errorf("error inserting chirp %v: %w", c.ID, err)
+		}
+	}
+
+	for _, fl := range f.Follows {
+		if err := q.SeedFollow(ctx, fl); err != nil {
+			return fmt.Errorf("error inserting follow %v -> %v: %w", fl.FollowerID, fl.FolloweeID, err)
+		}
+	}
+
+	for _, t := range f.RefreshTokens {
+		if err := q.SeedRefreshToken(ctx, t); err != nil {
+			return fmt.Errorf("error inserting refresh token of %v: %w", t.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+func orEpoch(t time.Time) time.Time {
+	if t.IsZero() {
+		return epoch
+	}
+	return t
+}
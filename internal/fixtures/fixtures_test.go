@@ -0,0 +1,68 @@
+package fixtures
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParse_YAML(t *testing.T) {
+	data, err := os.ReadFile("testdata/seed.yaml")
+	if err != nil {
+		t.Fatalf("error reading fixtures: %v", err)
+	}
+
+	f, err := Parse(data, FormatYAML)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(f.Users) != 2 || len(f.Chirps) != 2 || len(f.Follows) != 1 || len(f.RefreshTokens) != 1 {
+		t.Errorf("unexpected fixture counts: %+v", f)
+	}
+
+	if !f.Users[0].IsChirpyRed {
+		t.Errorf("expected first user to be Chirpy Red")
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	data := []byte(`{
+		"users": [{"id": "6f2b6a34-3d1c-4b8e-9f0a-1a2b3c4d5e01", "email": "walt@example.com", "password": "04234"}],
+		"chirps": [{"id": "0c7e1f6a-9a57-4c43-8d1e-2b3c4d5e6f01", "user_id": "6f2b6a34-3d1c-4b8e-9f0a-1a2b3c4d5e01", "body": "Say my name"}]
+	}`)
+
+	f, err := Parse(data, FormatJSON)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(f.Users) != 1 || len(f.Chirps) != 1 {
+		t.Errorf("unexpected fixture counts: %+v", f)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "unknown field",
+			data: `{"users": [], "likes": []}`,
+		},
+		{
+			name: "chirp of unknown user",
+			data: `{"chirps": [{"id": "0c7e1f6a-9a57-4c43-8d1e-2b3c4d5e6f01", "user_id": "6f2b6a34-3d1c-4b8e-9f0a-1a2b3c4d5e01", "body": "orphan"}]}`,
+		},
+		{
+			name: "user without password",
+			data: `{"users": [{"id": "6f2b6a34-3d1c-4b8e-9f0a-1a2b3c4d5e01", "email": "walt@example.com"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		if _, err := Parse([]byte(c.data), FormatJSON); err == nil {
+			t.Errorf("%v: expected error, got nil", c.name)
+		}
+	}
+}
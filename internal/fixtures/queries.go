@@ -0,0 +1,128 @@
+package fixtures
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/sqlitedb"
+)
+
+// Queries are the statements Reset and Load run, Postgres and SQLite
+// implement them with their own sqlc queries
+type Queries interface {
+	DeleteAllFollows(ctx context.Context) error
+	DeleteAllRefreshTokens(ctx context.Context) error
+	DeleteAllChirps(ctx context.Context) error
+	DeleteAllUsers(ctx context.Context) error
+	DeleteAllLinkPreviews(ctx context.Context) error
+
+	SeedUser(ctx context.Context, u User, hashedPassword string) error
+	SeedChirp(ctx context.Context, c Chirp) error
+	SeedFollow(ctx context.Context, f Follow) error
+	SeedRefreshToken(ctx context.Context, t RefreshToken) error
+}
+
+// Postgres returns the Queries of a Postgres database
+func Postgres(q *database.Queries) Queries {
+	return postgresQueries{q}
+}
+
+type postgresQueries struct {
+	*database.Queries
+}
+
+func (q postgresQueries) DeleteAllChirps(ctx context.Context) error {
+	_, err := q.Queries.DeleteAllChirps(ctx)
+	return err
+}
+
+func (q postgresQueries) SeedUser(ctx context.Context, u User, hashedPassword string) error {
+	_, err := q.Queries.SeedUser(ctx, database.SeedUserParams{
+		ID:             u.ID,
+		CreatedAt:      orEpoch(u.CreatedAt),
+		Email:          u.Email,
+		HashedPassword: hashedPassword,
+		IsChirpyRed:    u.IsChirpyRed,
+	})
+	return err
+}
+
+func (q postgresQueries) SeedChirp(ctx context.Context, c Chirp) error {
+	_, err := q.Queries.SeedChirp(ctx, database.SeedChirpParams{
+		ID:        c.ID,
+		CreatedAt: orEpoch(c.CreatedAt),
+		Body:      c.Body,
+		UserID:    c.UserID,
+	})
+	return err
+}
+
+func (q postgresQueries) SeedFollow(ctx context.Context, f Follow) error {
+	return q.Queries.CreateFollow(ctx, database.CreateFollowParams{
+		FollowerID: f.FollowerID,
+		FolloweeID: f.FolloweeID,
+	})
+}
+
+func (q postgresQueries) SeedRefreshToken(ctx context.Context, t RefreshToken) error {
+	return q.Queries.SeedRefreshToken(ctx, database.SeedRefreshTokenParams{
+		Token:     t.Token,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: sql.NullTime{Time: epoch, Valid: t.Revoked},
+	})
+}
+
+// SQLite returns the Queries of a SQLite database, times are stored in
+// UTC like the SQLite store does
+func SQLite(q *sqlitedb.Queries) Queries {
+	return sqliteQueries{q}
+}
+
+type sqliteQueries struct {
+	*sqlitedb.Queries
+}
+
+func (q sqliteQueries) DeleteAllChirps(ctx context.Context) error {
+	_, err := q.Queries.DeleteAllChirps(ctx)
+	return err
+}
+
+func (q sqliteQueries) SeedUser(ctx context.Context, u User, hashedPassword string) error {
+	return q.Queries.SeedUser(ctx, sqlitedb.SeedUserParams{
+		ID:             u.ID,
+		CreatedAt:      orEpoch(u.CreatedAt).UTC(),
+		Email:          u.Email,
+		HashedPassword: hashedPassword,
+		IsChirpyRed:    u.IsChirpyRed,
+	})
+}
+
+func (q sqliteQueries) SeedChirp(ctx context.Context, c Chirp) error {
+	return q.Queries.SeedChirp(ctx, sqlitedb.SeedChirpParams{
+		ID:        c.ID,
+		CreatedAt: orEpoch(c.CreatedAt).UTC(),
+		Body:      c.Body,
+		UserID:    c.UserID,
+	})
+}
+
+func (q sqliteQueries) SeedFollow(ctx context.Context, f Follow) error {
+	return q.Queries.CreateFollow(ctx, sqlitedb.CreateFollowParams{
+		FollowerID: f.FollowerID,
+		FolloweeID: f.FolloweeID,
+		Now:        time.Now().UTC(),
+	})
+}
+
+func (q sqliteQueries) SeedRefreshToken(ctx context.Context, t RefreshToken) error {
+	return q.Queries.SeedRefreshToken(ctx, sqlitedb.SeedRefreshTokenParams{
+		Token:     t.Token,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt.UTC(),
+		RevokedAt: sql.NullTime{Time: epoch, Valid: t.Revoked},
+		Now:       time.Now().UTC(),
+	})
+}
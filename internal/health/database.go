@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Database checks that the database answers a ping
+func Database(db *sql.DB) Checker {
+	return CheckerFunc{
+		CheckerName: "database",
+		Fn:          db.PingContext,
+	}
+}
+
+// Migrations checks that the goose migrations applied to the database
+// reach at least the version this binary expects. A newer schema is
+// fine: during a rolling deploy the new release migrates while the
+// old one is still serving, and migrations keep the schema compatible
+// with the previous release.
+func Migrations(db *sql.DB, expected int64) Checker {
+	return CheckerFunc{
+		CheckerName: "migrations",
+		Fn: func(ctx context.Context) error {
+			version, err := SchemaVersion(ctx, db)
+			if err != nil {
+				return err
+			}
+
+			if version < expected {
+				return fmt.Errorf("schema at version %d, expected at least %d", version, expected)
+			}
+
+			return nil
+		},
+	}
+}
+
+// SchemaVersion returns the current goose version of the database.
+//
+// It follows the same algorithm as goose: the version table is read
+// from the newest entry, and a version rolled back by a later entry
+// is skipped.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return 0, fmt.Errorf("error reading goose_db_version: %w", err)
+	}
+	defer rows.Close()
+
+	rolledBack := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var applied bool
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, fmt.Errorf("error reading goose_db_version: %w", err)
+		}
+
+		if rolledBack[version] {
+			continue
+		}
+
+		if applied {
+			return version, nil
+		}
+
+		rolledBack[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading goose_db_version: %w", err)
+	}
+
+	return 0, nil
+}
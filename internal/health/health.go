@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses reported for each dependency and for the whole service
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Checker is a dependency whose health decides whether Chirpy is
+// ready to serve traffic. Future dependencies (blob storage, mailer,
+// cache) implement it and register themselves in a Registry.
+type Checker interface {
+	// Name identifies the dependency in the readiness report
+	Name() string
+	// Check returns nil when the dependency is usable, it must
+	// give up when ctx is done
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function into a Checker
+type CheckerFunc struct {
+	CheckerName string
+	Fn          func(ctx context.Context) error
+}
+
+func (c CheckerFunc) Name() string                    { return c.CheckerName }
+func (c CheckerFunc) Check(ctx context.Context) error { return c.Fn(ctx) }
+
+// Result is the outcome of a single Checker
+type Result struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Report is the outcome of every registered Checker
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Healthy reports whether every dependency is up
+func (r Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+// Registry runs the registered checkers concurrently, each one
+// bounded by the same timeout
+type Registry struct {
+	mu       sync.RWMutex
+	checkers []Checker
+	timeout  time.Duration
+}
+
+// NewRegistry returns an empty registry whose checks time out after
+// timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds c to the checks run by Check
+func (r *Registry) Register(c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers = append(r.checkers, c)
+}
+
+// Check runs every registered checker and aggregates the results, the
+// report is up only when all dependencies are up
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make([]Checker, len(r.checkers))
+	copy(checkers, r.checkers)
+	r.mu.RUnlock()
+
+	sort.Slice(checkers, func(i, j int) bool { return checkers[i].Name() < checkers[j].Name() })
+
+	results := make([]Result, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checkers))}
+	for i, c := range checkers {
+		report.Checks[c.Name()] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestRegistry_AllUp(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register(CheckerFunc{CheckerName: "database", Fn: func(ctx context.Context) error { return nil }})
+	registry.Register(CheckerFunc{CheckerName: "cache", Fn: func(ctx context.Context) error { return nil }})
+
+	report := registry.Check(context.Background())
+
+	if !report.Healthy() {
+		t.Errorf("expected report to be healthy, got %v", report.Status)
+	}
+
+	if len(report.Checks) != 2 {
+		t.Errorf("expected %v checks, got %v", 2, len(report.Checks))
+	}
+}
+
+func TestRegistry_OneDown(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register(CheckerFunc{CheckerName: "database", Fn: func(ctx context.Context) error { return nil }})
+	registry.Register(CheckerFunc{CheckerName: "mailer", Fn: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}})
+
+	report := registry.Check(context.Background())
+
+	if report.Healthy() {
+		t.Errorf("expected report to be unhealthy")
+	}
+
+	if report.Checks["database"].Status != StatusUp {
+		t.Errorf("expected database to be %v, got %v", StatusUp, report.Checks["database"].Status)
+	}
+
+	mailer := report.Checks["mailer"]
+	if mailer.Status != StatusDown || mailer.Error != "connection refused" {
+		t.Errorf("expected mailer to be down with its error, got %+v", mailer)
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	registry := NewRegistry(50 * time.Millisecond)
+	registry.Register(CheckerFunc{CheckerName: "slow", Fn: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+
+	start := time.Now()
+	report := registry.Check(context.Background())
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected check to give up after the timeout")
+	}
+
+	if report.Checks["slow"].Status != StatusDown {
+		t.Errorf("expected slow check to be %v, got %v", StatusDown, report.Checks["slow"].Status)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "health.db"))
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// version 3 was rolled back, the schema is at version 2
+	_, err = db.Exec(`
+CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY, version_id INTEGER NOT NULL, is_applied BOOLEAN NOT NULL);
+INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1), (1, 1), (2, 1), (3, 1), (3, 0);`)
+	if err != nil {
+		t.Fatalf("error creating goose_db_version: %v", err)
+	}
+
+	cases := []struct {
+		expected int64
+		healthy  bool
+	}{
+		{expected: 1, healthy: true}, // a newer release migrated ahead
+		{expected: 2, healthy: true},
+		{expected: 3, healthy: false},
+	}
+
+	for _, c := range cases {
+		err := Migrations(db, c.expected).Check(context.Background())
+		if healthy := err == nil; healthy != c.healthy {
+			t.Errorf("expecting version %d: expected healthy %v, got error %v", c.expected, c.healthy, err)
+		}
+	}
+}
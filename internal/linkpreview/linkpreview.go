@@ -0,0 +1,193 @@
+// Package linkpreview fetches the cards shown under the URLs of chirps
+// from the Open Graph and Twitter Card metadata of the linked pages.
+//
+// URLs come from users, so HTTPFetcher refuses to connect to private,
+// loopback and otherwise internal addresses, checked on every
+// connection after DNS resolution and redirects included, and bounds
+// the number of redirects, the time and the bytes a fetch may take.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// userAgent identifies the fetcher to the sites it reads
+const userAgent = "Chirpy-LinkPreview/1.0"
+
+// maxHeaderBytes bounds the response headers, the body is bounded by
+// Options.MaxBytes
+const maxHeaderBytes = 64 << 10
+
+var (
+	// ErrBlockedAddr is returned when the URL resolves to an address
+	// the fetcher may not connect to
+	ErrBlockedAddr = errors.New("address not allowed")
+	// ErrTooManyRedirects is returned when the URL redirects more than
+	// Options.MaxRedirects times
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrNotHTML is returned when the URL is not an HTML page
+	ErrNotHTML = errors.New("not an HTML page")
+	// ErrNoMetadata is returned when the page has no title to show
+	ErrNoMetadata = errors.New("no preview metadata")
+)
+
+// Preview is the card of a page
+type Preview struct {
+	// URL is the URL that was fetched, before any redirect
+	URL         string
+	Title       string
+	Description string
+	// ImageURL is absolute, it is empty when the page has no image
+	ImageURL string
+	SiteName string
+}
+
+// Fetcher fetches the preview of a URL
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (Preview, error)
+}
+
+// Options limit what an HTTPFetcher downloads
+type Options struct {
+	// Timeout bounds a whole fetch, redirects and body included
+	Timeout time.Duration
+	// MaxBytes is the most of a page that is read, the metadata is
+	// expected in its head
+	MaxBytes     int64
+	MaxRedirects int
+	// AllowAddr reports whether the fetcher may connect to an address,
+	// nil allows the addresses PublicAddr allows
+	AllowAddr func(netip.Addr) bool
+}
+
+// HTTPFetcher is a Fetcher reading the pages over HTTP
+type HTTPFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewHTTPFetcher returns a fetcher limited by opts. It ignores the
+// proxy environment variables, a proxy would connect on its behalf to
+// addresses it cannot check.
+func NewHTTPFetcher(opts Options) *HTTPFetcher {
+	allow := opts.AllowAddr
+	if allow == nil {
+		allow = PublicAddr
+	}
+
+	// the address is checked once resolved, right before connecting,
+	// so a host cannot resolve to a public address when checked and to
+	// an internal one when dialed
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+
+			if !allow(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %v", ErrBlockedAddr, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      true,
+		TLSHandshakeTimeout:    opts.Timeout,
+		ResponseHeaderTimeout:  opts.Timeout,
+		MaxResponseHeaderBytes: maxHeaderBytes,
+		IdleConnTimeout:        90 * time.Second,
+	}
+
+	return &HTTPFetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > opts.MaxRedirects {
+					return ErrTooManyRedirects
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		maxBytes: opts.MaxBytes,
+	}
+}
+
+// Fetch reads the head of the page at rawURL, following redirects
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if err := checkScheme(u); err != nil {
+		return Preview{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Preview{}, fmt.Errorf("%w: %q", ErrNotHTML, contentType)
+	}
+
+	// pages that are not UTF-8 declare their charset in the header or
+	// in a meta tag
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return Preview{}, fmt.Errorf("error decoding the page: %w", err)
+	}
+
+	// relative images are relative to the page after the redirects
+	preview, err := Parse(body, resp.Request.URL)
+	if err != nil {
+		return Preview{}, err
+	}
+	preview.URL = rawURL
+
+	return preview, nil
+}
+
+// checkScheme only allows http and https URLs with a host
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("missing host")
+	}
+
+	return nil
+}
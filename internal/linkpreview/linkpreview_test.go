@@ -0,0 +1,213 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Los Pollos Hermanos</title>
+  <meta property="og:title" content="Los Pollos Hermanos &amp; Family">
+  <meta property="og:description" content="  The chicken
+    brothers ">
+  <meta property="og:image" content="/img/logo.png">
+  <meta property="og:site_name" content="Pollos">
+  <meta name="twitter:title" content="ignored">
+</head>
+<body><meta property="og:title" content="not in the head"></body>
+</html>`
+
+// allowAll lets tests reach httptest servers on loopback
+func allowAll(netip.Addr) bool { return true }
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	mux.HandleFunc("/redirect/{n}", func(w http.ResponseWriter, r *http.Request) {
+		next := "/page"
+		if n := r.PathValue("n"); n != "1" {
+			next = fmt.Sprintf("/redirect/%c", n[0]-1)
+		}
+		http.Redirect(w, r, next, http.StatusFound)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><!--"+strings.Repeat("x", 64<<10)+"-->")
+		fmt.Fprint(w, `<meta property="og:title" content="too far"></head></html>`)
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewHTTPFetcher(Options{Timeout: time.Second, MaxBytes: 32 << 10, MaxRedirects: 2, AllowAddr: allowAll})
+	ctx := context.Background()
+
+	preview, err := f.Fetch(ctx, srv.URL+"/redirect/2")
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	expect := Preview{
+		URL:         srv.URL + "/redirect/2",
+		Title:       "Los Pollos Hermanos & Family",
+		Description: "The chicken brothers",
+		ImageURL:    srv.URL + "/img/logo.png",
+		SiteName:    "Pollos",
+	}
+	if preview != expect {
+		t.Errorf("expected %+v, got %+v", expect, preview)
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		expect error
+	}{
+		{"too many redirects", "/redirect/3", ErrTooManyRedirects},
+		{"beyond max bytes", "/big", ErrNoMetadata},
+		{"not html", "/image.png", ErrNotHTML},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := f.Fetch(ctx, srv.URL+c.path); !errors.Is(err, c.expect) {
+				t.Errorf("expected %v, got %v", c.expect, err)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		f := NewHTTPFetcher(Options{Timeout: 50 * time.Millisecond, MaxBytes: 32 << 10, AllowAddr: allowAll})
+		if _, err := f.Fetch(ctx, srv.URL+"/slow"); err == nil {
+			t.Error("expected the fetch to time out")
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		if _, err := f.Fetch(ctx, "file:///etc/passwd"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestHTTPFetcher_BlocksInternalAddresses(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewHTTPFetcher(Options{Timeout: time.Second, MaxBytes: 32 << 10, MaxRedirects: 2})
+
+	u, _ := url.Parse(srv.URL)
+	urls := []string{
+		srv.URL + "/page",
+		"http://localhost:" + u.Port() + "/page",
+		"http://[::1]:" + u.Port() + "/page",
+	}
+
+	for _, rawURL := range urls {
+		if _, err := f.Fetch(context.Background(), rawURL); !errors.Is(err, ErrBlockedAddr) {
+			t.Errorf("expected %v fetching %v, got %v", ErrBlockedAddr, rawURL, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+
+	cases := []struct {
+		name   string
+		html   string
+		expect Preview
+		err    error
+	}{
+		{
+			name:   "twitter card",
+			html:   `<head><meta name="twitter:title" content="Card"><meta name="twitter:image" content="img.png"></head>`,
+			expect: Preview{Title: "Card", ImageURL: "https://example.com/blog/img.png"},
+		},
+		{
+			name:   "plain page",
+			html:   `<head><title> Plain  page </title><meta name="description" content="About it"></head>`,
+			expect: Preview{Title: "Plain page", Description: "About it"},
+		},
+		{
+			name:   "unsafe image",
+			html:   `<head><meta property="og:title" content="Page"><meta property="og:image" content="javascript:alert(1)"></head>`,
+			expect: Preview{Title: "Page"},
+		},
+		{
+			name:   "long title",
+			html:   `<head><meta property="og:title" content="` + strings.Repeat("é", maxTitle+10) + `"></head>`,
+			expect: Preview{Title: strings.Repeat("é", maxTitle-1) + "…"},
+		},
+		{
+			name: "no metadata",
+			html: `<head></head><body><h1>hello</h1></body>`,
+			err:  ErrNoMetadata,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			preview, err := Parse(strings.NewReader(c.html), base)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected error %v, got %v", c.err, err)
+			}
+			if preview != c.expect {
+				t.Errorf("expected %+v, got %+v", c.expect, preview)
+			}
+		})
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"255.255.255.255":      false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"::":                   false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+		"64:ff9b::a00:1":       false,
+	}
+
+	for addr, expect := range cases {
+		if got := PublicAddr(netip.MustParseAddr(addr)); got != expect {
+			t.Errorf("PublicAddr(%v): expected %v, got %v", addr, expect, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package linkpreview
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Limits of the fields of a preview, longer texts are cut and longer
+// image URLs dropped
+const (
+	maxTitle       = 300
+	maxDescription = 1000
+	maxSiteName    = 100
+	maxImageURL    = 2048
+)
+
+// Parse reads the preview metadata of the HTML page at base, up to the
+// end of its head. Open Graph properties win over Twitter Card ones,
+// which win over the title and description of the page.
+//
+// Returns ErrNoMetadata if the page has no title at all
+func Parse(r io.Reader, base *url.URL) (Preview, error) {
+	meta := map[string]string{}
+	var title string
+
+	z := html.NewTokenizer(r)
+	for done := false; !done; {
+		switch z.Next() {
+		case html.ErrorToken:
+			if !errors.Is(z.Err(), io.EOF) {
+				return Preview{}, z.Err()
+			}
+			done = true
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "meta":
+				if hasAttr {
+					readMeta(z, meta)
+				}
+			case "title":
+				if title == "" && z.Next() == html.TextToken {
+					title = string(z.Text())
+				}
+			case "body":
+				done = true
+			}
+
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				done = true
+			}
+		}
+	}
+
+	preview := Preview{
+		Title:       clean(first(meta["og:title"], meta["twitter:title"], title), maxTitle),
+		Description: clean(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescription),
+		ImageURL:    imageURL(base, first(meta["og:image"], meta["og:image:url"], meta["og:image:secure_url"], meta["twitter:image"], meta["twitter:image:src"])),
+		SiteName:    clean(meta["og:site_name"], maxSiteName),
+	}
+	if preview.Title == "" {
+		return Preview{}, ErrNoMetadata
+	}
+
+	return preview, nil
+}
+
+// readMeta stores the content of a meta tag under its property, Open
+// Graph, or its name, Twitter Card, keeping the first of repeated tags
+func readMeta(z *html.Tokenizer, meta map[string]string) {
+	var key, content string
+	for {
+		name, value, more := z.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = string(value)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if _, ok := meta[key]; key != "" && !ok {
+		meta[key] = content
+	}
+}
+
+// first returns the first of values that is not blank
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// clean collapses the whitespace of s and cuts it to max characters
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// imageURL resolves the image against the page, only keeping http and
+// https images
+func imageURL(base *url.URL, raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+
+	u, err := base.Parse(raw)
+	if err != nil || checkScheme(u) != nil {
+		return ""
+	}
+
+	resolved := u.String()
+	if len(resolved) > maxImageURL {
+		return ""
+	}
+
+	return resolved
+}
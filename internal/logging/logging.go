@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedactedValue replaces the value of every attribute that may hold
+// a credential
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys are matched against attribute keys, case insensitive,
+// to decide whether a value must be redacted before it is written.
+// Keys ending in "key", such as polka_key or signing_key, are redacted
+// too, while key_id and other identifiers of keys are not.
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"cookie",
+	"credential",
+	"private",
+	"signature",
+}
+
+// New returns a JSON logger writing to w at the given level.
+//
+// Every record is enriched with the request data stored in its context
+// and goes through the redaction layer, so tokens, passwords and keys
+// never reach the output even when passed by mistake.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel converts a level name such as "debug" or "WARN" into a
+// slog.Level, falling back to info for empty or unknown names
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+// Secret is a string that always logs as RedactedValue, whatever key
+// it is logged under
+type Secret string
+
+// LogValue implements slog.LogValuer
+func (Secret) LogValue() slog.Value {
+	return slog.StringValue(RedactedValue)
+}
+
+// redact is the ReplaceAttr hook of the JSON handler
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, RedactedValue)
+	}
+
+	return a
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "key") {
+		return true
+	}
+
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contextHandler adds the request ID, the authenticated user and the
+// current trace stored in the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		r.AddAttrs(slog.String("request_id", info.id))
+		if info.userID != uuid.Nil {
+			r.AddAttrs(slog.String("user_id", info.userID.String()))
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+type requestInfoKey struct{}
+
+// requestInfo is shared by pointer through the context so handlers
+// deeper in the chain can report the authenticated user back to the
+// access log
+type requestInfo struct {
+	id     string
+	userID uuid.UUID
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: id})
+}
+
+// RequestID returns the request ID stored in ctx, if any
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+
+	return ""
+}
+
+// SetUserID records the authenticated user of the request, so it is
+// included in every following log line and in the access log
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNew_RedactsSensitiveValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Info("login",
+		"password", "hakuna matata",
+		"refresh_token", "abc123",
+		"api_key", "f271c81ff7084ee5b99a5091b42d486e",
+		"polka_key", "f271c81ff7084ee5b99a5091b42d486e",
+		"signingKey", "c2lnbmluZw==",
+		"key", Secret("pumba"),
+		"key_id", "kid-1",
+		"email", "timon@example.com",
+	)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not valid JSON: %v", err)
+	}
+
+	for _, key := range []string{"password", "refresh_token", "api_key", "polka_key", "signingKey", "key"} {
+		if line[key] != RedactedValue {
+			t.Errorf("expected %v to be redacted, got %v", key, line[key])
+		}
+	}
+
+	if line["key_id"] != "kid-1" {
+		t.Errorf("expected key_id to be logged, got %v", line["key_id"])
+	}
+	if line["email"] != "timon@example.com" {
+		t.Errorf("expected email to be logged, got %v", line["email"])
+	}
+}
+
+func TestNew_AddsRequestInfo(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	userID := uuid.New()
+	ctx := WithRequestID(context.Background(), "req-42")
+	SetUserID(ctx, userID)
+
+	logger.InfoContext(ctx, "chirp created")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not valid JSON: %v", err)
+	}
+
+	if line["request_id"] != "req-42" {
+		t.Errorf("expected request_id %v, got %v", "req-42", line["request_id"])
+	}
+
+	if line["user_id"] != userID.String() {
+		t.Errorf("expected user_id %v, got %v", userID, line["user_id"])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected slog.Level
+	}{
+		{input: "debug", expected: slog.LevelDebug},
+		{input: "WARN", expected: slog.LevelWarn},
+		{input: "", expected: slog.LevelInfo},
+		{input: "loud", expected: slog.LevelInfo},
+	}
+
+	for _, c := range cases {
+		if actual := ParseLevel(c.input); actual != c.expected {
+			t.Errorf("ParseLevel(%q): expected %v, got %v", c.input, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chirpy"
+
+// knownMethods are the HTTP methods recorded as their own label value,
+// any other is recorded as "other" so clients cannot create series
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Metrics holds every Prometheus collector exposed by Chirpy.
+//
+// Each instance owns its own registry, so tests can build as many
+// as they need without colliding on the global default registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	chirpsCreated    prometheus.Counter
+	logins           *prometheus.CounterVec
+	passwordRehashes prometheus.Counter
+	webhooksReceived *prometheus.CounterVec
+}
+
+// New creates and registers all Chirpy collectors.
+//
+// When db is not nil, the connection pool statistics of database/sql
+// are exported as well.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Total number of chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Total number of login attempts by result.",
+		}, []string{"result"}),
+		passwordRehashes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "password_rehashes_total",
+			Help:      "Total number of password hashes upgraded on login.",
+		}),
+		webhooksReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhooks_received_total",
+			Help:      "Total number of webhooks received by event.",
+		}, []string{"event"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.passwordRehashes,
+		m.webhooksReceived,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+
+	// both login results are always exported, even before the first login
+	m.logins.WithLabelValues("success")
+	m.logins.WithLabelValues("failure")
+
+	return m
+}
+
+// Handler returns the handler that serves the metrics in the
+// Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RequestStarted marks the beginning of an HTTP request, every call
+// must be balanced by a call to RequestDone, even when the request
+// panics
+func (m *Metrics) RequestStarted() {
+	m.inFlight.Inc()
+}
+
+// RequestDone marks the end of an HTTP request started with
+// RequestStarted
+func (m *Metrics) RequestDone() {
+	m.inFlight.Dec()
+}
+
+// RequestFinished records the outcome of an HTTP request that
+// returned a response
+func (m *Metrics) RequestFinished(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	if !knownMethods[method] {
+		method = "other"
+	}
+
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.duration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ChirpCreated counts a chirp successfully stored
+func (m *Metrics) ChirpCreated() {
+	m.chirpsCreated.Inc()
+}
+
+// LoginSucceeded counts a login that issued tokens
+func (m *Metrics) LoginSucceeded() {
+	m.logins.WithLabelValues("success").Inc()
+}
+
+// LoginFailed counts a login rejected for any reason
+func (m *Metrics) LoginFailed() {
+	m.logins.WithLabelValues("failure").Inc()
+}
+
+// PasswordRehashed counts a stored hash replaced with one using the
+// current parameters
+func (m *Metrics) PasswordRehashed() {
+	m.passwordRehashes.Inc()
+}
+
+// WebhookReceived counts an authenticated webhook delivery.
+//
+// Events other than the ones Chirpy handles are grouped under
+// "other" to keep the label cardinality bounded.
+func (m *Metrics) WebhookReceived(event string) {
+	if event != "user.upgraded" {
+		event = "other"
+	}
+
+	m.webhooksReceived.WithLabelValues(event).Inc()
+}
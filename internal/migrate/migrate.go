@@ -0,0 +1,170 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"text/tabwriter"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/sql/schema"
+	sqliteschema "github.com/luis-octavius/chirpy/sql/sqlite/schema"
+)
+
+// Commands accepted by Run
+const (
+	CommandUp     = "up"
+	CommandDown   = "down"
+	CommandStatus = "status"
+	CommandRedo   = "redo"
+)
+
+// Migrations returns the embedded migrations of a database backend
+func Migrations(backend string) fs.FS {
+	if backend == config.BackendSQLite {
+		return sqliteschema.FS
+	}
+	return schema.FS
+}
+
+// NewProvider returns a goose provider for the embedded migrations of
+// backend.
+//
+// On Postgres every command runs under an advisory lock, so replicas
+// started together with --migrate-on-start wait for each other
+// instead of racing on the schema. SQLite locks the database file by
+// itself.
+func NewProvider(db *sql.DB, backend string) (*goose.Provider, error) {
+	if backend == config.BackendSQLite {
+		provider, err := goose.NewProvider(goose.DialectSQLite3, db, sqliteschema.FS)
+		if err != nil {
+			return nil, fmt.Errorf("error loading migrations: %w", err)
+		}
+		return provider, nil
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("error creating migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, schema.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Latest returns the version of the newest embedded migration of
+// backend, which is the schema version this binary expects
+func Latest(backend string) int64 {
+	names, err := fs.Glob(Migrations(backend), "*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var latest int64
+	for _, name := range names {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, version)
+	}
+
+	return latest
+}
+
+// Run executes one migration command against db and writes a summary
+// of what happened to out
+func Run(ctx context.Context, db *sql.DB, backend, command string, out io.Writer) error {
+	provider, err := NewProvider(db, backend)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case CommandUp:
+		results, err := provider.Up(ctx)
+		printResults(out, results)
+		return err
+
+	case CommandDown:
+		result, err := provider.Down(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		return err
+
+	case CommandRedo:
+		result, err := provider.Down(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		if err != nil {
+			return err
+		}
+
+		result, err = provider.UpByOne(ctx)
+		printResults(out, []*goose.MigrationResult{result})
+		return err
+
+	case CommandStatus:
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(out, statuses)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down, status or redo", command)
+	}
+}
+
+// Up applies every pending migration, it is used by --migrate-on-start
+func Up(ctx context.Context, db *sql.DB, backend string) error {
+	provider, err := NewProvider(db, backend)
+	if err != nil {
+		return err
+	}
+
+	results, err := provider.Up(ctx)
+	for _, result := range results {
+		slog.InfoContext(ctx, "migration applied", "version", result.Source.Version, "duration", result.Duration)
+	}
+
+	return err
+}
+
+func printResults(out io.Writer, results []*goose.MigrationResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(out, "no migrations to run")
+		return
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		fmt.Fprintf(out, "%-4s %v (%v)\n", result.Direction, result.Source.Path, result.Duration)
+	}
+}
+
+func printStatus(out io.Writer, statuses []*goose.MigrationStatus) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE\tAPPLIED AT")
+
+	for _, status := range statuses {
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%v\t%v\t%v\n", status.Source.Version, status.Source.Path, status.State, appliedAt)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+)
+
+func TestEmbeddedMigrations_Contiguous(t *testing.T) {
+	for _, backend := range []string{config.BackendPostgres, config.BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			testContiguous(t, backend)
+		})
+	}
+}
+
+func testContiguous(t *testing.T, backend string) {
+	names, err := fs.Glob(Migrations(backend), "*.sql")
+	if err != nil {
+		t.Fatalf("error listing embedded migrations: %v", err)
+	}
+
+	if len(names) == 0 {
+		t.Fatalf("expected embedded migrations, got none")
+	}
+
+	seen := make(map[int64]string)
+	for _, name := range names {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			t.Fatalf("migration %v has no version: %v", name, err)
+		}
+
+		if other, ok := seen[version]; ok {
+			t.Errorf("migrations %v and %v share version %d", other, name, version)
+		}
+		seen[version] = name
+	}
+
+	latest := Latest(backend)
+	for version := int64(1); version <= latest; version++ {
+		if _, ok := seen[version]; !ok {
+			t.Errorf("missing migration for version %d", version)
+		}
+	}
+}
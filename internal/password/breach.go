@@ -0,0 +1,87 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// prefixLength is the number of hex characters of the SHA-1 hash sent
+// to a RangeSource, as in the Pwned Passwords range API
+const prefixLength = 5
+
+// RangeSource looks up breached passwords with k-anonymity: it is only
+// given the first 5 hex characters of the SHA-1 hash of a password and
+// returns the upper case suffixes of every breached hash sharing them,
+// so the password itself never leaves the process.
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// IsBreached reports whether password is known to source
+func IsBreached(ctx context.Context, source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(ctx, hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(suffixes, hash[prefixLength:]), nil
+}
+
+// Offline is a RangeSource holding breached hashes in memory, read
+// from a file of the Pwned Passwords downloads
+type Offline struct {
+	ranges map[string][]string
+}
+
+// LoadOffline reads the breached hashes of the file at path, see
+// NewOffline
+func LoadOffline(path string) (*Offline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening breached passwords file: %w", err)
+	}
+	defer f.Close()
+
+	return NewOffline(f)
+}
+
+// NewOffline reads one SHA-1 hash per line, in hex and optionally
+// followed by :count as in the Pwned Passwords downloads
+func NewOffline(r io.Reader) (*Offline, error) {
+	o := &Offline{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash %q", line, hash)
+		}
+
+		prefix := hash[:prefixLength]
+		o.ranges[prefix] = append(o.ranges[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading breached passwords: %w", err)
+	}
+
+	return o, nil
+}
+
+func (o *Offline) Range(ctx context.Context, prefix string) ([]string, error) {
+	return o.ranges[strings.ToUpper(prefix)], nil
+}
@@ -0,0 +1,100 @@
+// Package password decides which passwords users may choose.
+//
+// A Policy bounds their length, the upper bound also bounds the cost
+// of hashing them, rejects the bundled list of common passwords and,
+// when it has a RangeSource, passwords known from data breaches.
+package password
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of the violations reported by Policy.Check
+const (
+	CodeTooShort = "too_short"
+	CodeTooLong  = "too_long"
+	CodeCommon   = "common_password"
+	CodeBreached = "breached_password"
+)
+
+//go:embed common.txt
+var commonList string
+
+// common holds the bundled common passwords, lower cased
+var common = parseCommon(commonList)
+
+func parseCommon(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
+
+// Violation is the rule of a Policy a password breaks
+type Violation struct {
+	Code    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return "password " + v.Message
+}
+
+// Policy is the set of rules a new password must follow
+type Policy struct {
+	// MinLength and MaxLength bound the number of characters
+	MinLength int
+	MaxLength int
+
+	// Breached is consulted for passwords known from data breaches,
+	// nil skips the check
+	Breached RangeSource
+}
+
+// Check returns a *Violation for the first rule password breaks, or
+// nil if it may be used.
+//
+// A failing breach lookup is logged and ignored, users can still sign
+// up while the source is unavailable.
+func (p Policy) Check(ctx context.Context, password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return &Violation{Code: CodeTooShort, Message: fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if length > p.MaxLength {
+		return &Violation{Code: CodeTooLong, Message: fmt.Sprintf("must be at most %d characters", p.MaxLength)}
+	}
+
+	if _, ok := common[strings.ToLower(password)]; ok {
+		return &Violation{Code: CodeCommon, Message: "is too common"}
+	}
+
+	if p.Breached == nil {
+		return nil
+	}
+
+	breached, err := IsBreached(ctx, p.Breached, password)
+	if err != nil {
+		slog.WarnContext(ctx, "error looking up breached passwords", "err", err)
+		return nil
+	}
+	if breached {
+		return &Violation{Code: CodeBreached, Message: "appears in a known data breach"}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingSource is a RangeSource that is never available
+type failingSource struct{}
+
+func (failingSource) Range(ctx context.Context, prefix string) ([]string, error) {
+	return nil, errors.New("unavailable")
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPolicyCheck(t *testing.T) {
+	breached, err := NewOffline(strings.NewReader(sha1Hex("correct horse battery staple") + ":3\n"))
+	if err != nil {
+		t.Fatalf("NewOffline returned error: %v", err)
+	}
+	policy := Policy{MinLength: 8, MaxLength: 16, Breached: breached}
+
+	cases := []struct {
+		password string
+		expected string
+	}{
+		{password: "say-my-name", expected: ""},
+		{password: "short", expected: CodeTooShort},
+		{password: "ñandúñandú", expected: ""},
+		{password: "a-password-way-too-long", expected: CodeTooLong},
+		{password: "PassWord123", expected: CodeCommon},
+		{password: "exactly-16-chars", expected: ""},
+	}
+
+	for _, c := range cases {
+		err := policy.Check(context.Background(), c.password)
+
+		var violation *Violation
+		switch {
+		case c.expected == "" && err != nil:
+			t.Errorf("%q: expected no error, got %v", c.password, err)
+		case c.expected != "" && !errors.As(err, &violation):
+			t.Errorf("%q: expected a violation, got %v", c.password, err)
+		case c.expected != "" && violation.Code != c.expected:
+			t.Errorf("%q: expected %v, got %v", c.password, c.expected, violation.Code)
+		}
+	}
+}
+
+func TestPolicyCheck_Breached(t *testing.T) {
+	breached, err := NewOffline(strings.NewReader(strings.ToLower(sha1Hex("say-my-name")) + "\n"))
+	if err != nil {
+		t.Fatalf("NewOffline returned error: %v", err)
+	}
+
+	policy := Policy{MinLength: 8, MaxLength: 64, Breached: breached}
+
+	var violation *Violation
+	if err := policy.Check(context.Background(), "say-my-name"); !errors.As(err, &violation) || violation.Code != CodeBreached {
+		t.Errorf("expected %v, got %v", CodeBreached, err)
+	}
+
+	if err := policy.Check(context.Background(), "yeah-science"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// an unavailable source does not block users
+	policy.Breached = failingSource{}
+	if err := policy.Check(context.Background(), "say-my-name"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestIsBreached_SendsOnlyThePrefix(t *testing.T) {
+	var sent string
+	source := rangeFunc(func(prefix string) []string {
+		sent = prefix
+		return nil
+	})
+
+	if _, err := IsBreached(context.Background(), source, "say-my-name"); err != nil {
+		t.Fatalf("IsBreached returned error: %v", err)
+	}
+
+	if expected := strings.ToUpper(sha1Hex("say-my-name"))[:5]; sent != expected {
+		t.Errorf("expected %v, got %v", expected, sent)
+	}
+}
+
+type rangeFunc func(prefix string) []string
+
+func (f rangeFunc) Range(ctx context.Context, prefix string) ([]string, error) {
+	return f(prefix), nil
+}
+
+func TestNewOffline_InvalidHash(t *testing.T) {
+	if _, err := NewOffline(strings.NewReader("not-a-hash:1\n")); err == nil {
+		t.Errorf("expected an error for an invalid hash")
+	}
+}
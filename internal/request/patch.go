@@ -0,0 +1,59 @@
+package request
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// MergePatchType is the media type of JSON merge patches (RFC 7386)
+const MergePatchType = "application/merge-patch+json"
+
+// Field is a member of a JSON merge patch, which is either absent and
+// left unchanged, null and removed, or set to Value.
+//
+// The validate rules of a Field apply to Value when it is set.
+type Field[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+
+	if string(data) == "null" {
+		f.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(data, &f.Value)
+}
+
+// Apply merges f into dst, a null member sets it to its zero value
+func (f Field[T]) Apply(dst *T) {
+	if !f.Set {
+		return
+	}
+
+	var zero T
+	if f.Null {
+		*dst = zero
+		return
+	}
+
+	*dst = f.Value
+}
+
+// patchValue returns the value the rules of the field apply to, and
+// false when the field has no value
+func (f Field[T]) patchValue() (reflect.Value, bool) {
+	if !f.Set || f.Null {
+		return reflect.Value{}, false
+	}
+
+	return reflect.ValueOf(f.Value), true
+}
+
+type patchField interface {
+	patchValue() (reflect.Value, bool)
+}
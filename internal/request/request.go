@@ -0,0 +1,164 @@
+// Package request decodes and validates the JSON bodies of API
+// requests, reporting every problem as an *apierror.Error.
+//
+// Request structs declare their rules in a validate tag, a comma
+// separated list of:
+//
+//	required  the field must not be empty
+//	email     the field must be an email address
+//	uuid      the field must be a UUID
+//	url       the field must be an http or https URL
+//	min=N     the field must have at least N characters
+//	max=N     the field must have at most N characters
+//	oneof=A B the field must be one of the space separated values
+//
+// Rules other than required are skipped for empty fields. Structs that
+// need rules the tags cannot express implement Validator. Merge
+// patches declare their members as Field.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/luis-octavius/chirpy/internal/apierror"
+)
+
+// DefaultMaxBytes bounds the size of request bodies read by Decode
+const DefaultMaxBytes = 1 << 20
+
+// Codes of the field errors reported by Decode
+const (
+	CodeRequired     = "required"
+	CodeInvalidEmail = "invalid_email"
+	CodeInvalidUUID  = "invalid_uuid"
+	CodeInvalidURL   = "invalid_url"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeInvalidValue = "invalid_value"
+	CodeInvalidType  = "invalid_type"
+	CodeUnknownField = "unknown_field"
+)
+
+// CodeUnsupportedMediaType is returned for bodies that are not JSON
+const CodeUnsupportedMediaType = "unsupported_media_type"
+
+// Validator is implemented by request structs with rules that cannot
+// be declared in tags, its errors are reported along the tag ones
+type Validator interface {
+	Validate() []apierror.FieldError
+}
+
+// Decoder reads JSON request bodies, the zero value rejects bodies over
+// DefaultMaxBytes and fields that v does not declare
+type Decoder struct {
+	// MaxBytes overrides DefaultMaxBytes when positive
+	MaxBytes int64
+
+	// AllowUnknownFields ignores fields v does not declare, for payloads
+	// defined by third parties that may grow new fields
+	AllowUnknownFields bool
+
+	// MediaTypes overrides the accepted Content-Types, application/json
+	// when empty
+	MediaTypes []string
+}
+
+// Decode reads the body of r into v with the zero Decoder
+func Decode(w http.ResponseWriter, r *http.Request, v any) error {
+	return Decoder{}.Decode(w, r, v)
+}
+
+// Decode reads the JSON body of r into v, which must be a pointer to a
+// struct, and validates it.
+//
+// Returns a 415 unsupported_media_type error if the body is not JSON,
+// a 413 body_too_large or 400 invalid_json error if it cannot be read,
+// and a 400 validation_failed error listing every invalid field.
+func (d Decoder) Decode(w http.ResponseWriter, r *http.Request, v any) error {
+	mediaTypes := d.MediaTypes
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(mediaTypes, mediaType) {
+		detail := "the request body must be " + strings.Join(mediaTypes, " or ")
+		return apierror.New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, detail)
+	}
+
+	maxBytes := d.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	if !d.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	// a body holds a single JSON value
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return apierror.InvalidJSON(errors.New("unexpected data after the JSON value"))
+	}
+
+	if fields := Validate(v); len(fields) > 0 {
+		return apierror.Validation(fields...)
+	}
+
+	return nil
+}
+
+// decodeError reports the fields json could not decode as field errors
+// and everything else as a body that is not valid JSON
+func decodeError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return apierror.InvalidJSON(errors.New("empty request body"))
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return apierror.Validation(apierror.FieldError{
+			Field:   typeErr.Field,
+			Code:    CodeInvalidType,
+			Message: fmt.Sprintf("must be %v", jsonType(typeErr.Type.Kind().String())),
+		}).Wrap(err)
+	}
+
+	// encoding/json has no error type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return apierror.Validation(apierror.FieldError{
+			Field:   strings.Trim(field, `"`),
+			Code:    CodeUnknownField,
+			Message: "is not a known field",
+		}).Wrap(err)
+	}
+
+	return apierror.InvalidJSON(err)
+}
+
+// jsonType names a Go kind the way API clients know it
+func jsonType(kind string) string {
+	switch {
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,186 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luis-octavius/chirpy/internal/apierror"
+)
+
+type signup struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=4,max=8"`
+	Plan     string `json:"plan" validate:"oneof=free red"`
+	Profile  struct {
+		ID string `json:"id" validate:"uuid"`
+	} `json:"profile"`
+}
+
+func withProfileID(id string) signup {
+	v := signup{Email: "walt@example.com", Password: "heisen"}
+	v.Profile.ID = id
+	return v
+}
+
+func decode(t *testing.T, d Decoder, contentType, body string) (signup, *apierror.Error) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/api/users", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	var v signup
+	err := d.Decode(httptest.NewRecorder(), req, &v)
+	if err == nil {
+		return v, nil
+	}
+
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *apierror.Error, got %T", err)
+	}
+	return v, apiErr
+}
+
+func TestDecode(t *testing.T) {
+	v, err := decode(t, Decoder{}, "application/json; charset=utf-8", `{"email":"walt@example.com","password":"heisen"}`)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if v.Email != "walt@example.com" || v.Password != "heisen" {
+		t.Errorf("unexpected value %+v", v)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	cases := map[string]struct {
+		decoder      Decoder
+		contentType  string
+		body         string
+		expectStatus int
+		expectCode   string
+	}{
+		"no content type":  {Decoder{}, "", `{}`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+		"not JSON":         {Decoder{}, "text/plain", `{}`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+		"empty body":       {Decoder{}, "application/json", ``, http.StatusBadRequest, apierror.CodeInvalidJSON},
+		"malformed":        {Decoder{}, "application/json", `{"email":`, http.StatusBadRequest, apierror.CodeInvalidJSON},
+		"trailing data":    {Decoder{}, "application/json", `{} {}`, http.StatusBadRequest, apierror.CodeInvalidJSON},
+		"too large":        {Decoder{MaxBytes: 8}, "application/json", `{"email":"walt@example.com"}`, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge},
+		"unknown field":    {Decoder{}, "application/json", `{"name":"walt"}`, http.StatusBadRequest, apierror.CodeValidationFailed},
+		"wrong type":       {Decoder{}, "application/json", `{"email":42}`, http.StatusBadRequest, apierror.CodeValidationFailed},
+		"validation rules": {Decoder{}, "application/json", `{}`, http.StatusBadRequest, apierror.CodeValidationFailed},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, c.decoder, c.contentType, c.body)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if err.Status != c.expectStatus || err.Code != c.expectCode {
+				t.Errorf("expected %v %v, got %v %v", c.expectStatus, c.expectCode, err.Status, err.Code)
+			}
+		})
+	}
+}
+
+func TestDecode_AllowUnknownFields(t *testing.T) {
+	body := `{"email":"walt@example.com","password":"heisen","name":"walt"}`
+	if _, err := decode(t, Decoder{AllowUnknownFields: true}, "application/json", body); err != nil {
+		t.Errorf("Decode returned error: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		input  signup
+		expect map[string]string
+	}{
+		"valid": {
+			input:  signup{Email: "walt@example.com", Password: "heisen"},
+			expect: map[string]string{},
+		},
+		"missing fields": {
+			input:  signup{},
+			expect: map[string]string{"email": CodeRequired, "password": CodeRequired},
+		},
+		"invalid fields": {
+			input:  signup{Email: "Walt <walt@example.com>", Password: "abc"},
+			expect: map[string]string{"email": CodeInvalidEmail, "password": CodeTooShort},
+		},
+		"characters, not bytes": {
+			input:  signup{Email: "walt@example.com", Password: "ñññññññ"},
+			expect: map[string]string{},
+		},
+		"too long": {
+			input:  signup{Email: "walt@example.com", Password: "say-my-name"},
+			expect: map[string]string{"password": CodeTooLong},
+		},
+		"unknown value": {
+			input:  signup{Email: "walt@example.com", Password: "heisen", Plan: "blue"},
+			expect: map[string]string{"plan": CodeInvalidValue},
+		},
+		"nested struct": {
+			input:  withProfileID("heisenberg"),
+			expect: map[string]string{"profile.id": CodeInvalidUUID},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fields := Validate(&c.input)
+
+			actual := map[string]string{}
+			for _, field := range fields {
+				actual[field.Field] = field.Code
+			}
+
+			if len(actual) != len(c.expect) {
+				t.Fatalf("expected %v, got %v", c.expect, actual)
+			}
+			for field, code := range c.expect {
+				if actual[field] != code {
+					t.Errorf("expected %v for %v, got %v", code, field, actual[field])
+				}
+			}
+		})
+	}
+}
+
+func TestField(t *testing.T) {
+	type patch struct {
+		Bio    Field[string] `json:"bio" validate:"max=3"`
+		Handle Field[string] `json:"handle"`
+		Site   Field[string] `json:"site" validate:"url"`
+	}
+
+	var p patch
+	if err := json.Unmarshal([]byte(`{"bio":null,"site":"ftp://example.com"}`), &p); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if !p.Bio.Set || !p.Bio.Null {
+		t.Errorf("expected bio to be null, got %+v", p.Bio)
+	}
+	if p.Handle.Set {
+		t.Errorf("expected handle to be absent, got %+v", p.Handle)
+	}
+
+	bio, handle := "old", "walt"
+	p.Bio.Apply(&bio)
+	p.Handle.Apply(&handle)
+	if bio != "" || handle != "walt" {
+		t.Errorf("expected bio removed and handle unchanged, got %q and %q", bio, handle)
+	}
+
+	// rules only apply to members with a value
+	fields := Validate(&p)
+	if len(fields) != 1 || fields[0].Field != "site" || fields[0].Code != CodeInvalidURL {
+		t.Errorf("expected only an invalid site, got %+v", fields)
+	}
+}
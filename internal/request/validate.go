@@ -0,0 +1,167 @@
+package request
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+)
+
+// Validate checks the validate tags of the struct v points to, nested
+// structs included, then calls Validate on v when it is a Validator.
+// Fields are named after their JSON keys, joined with dots.
+func Validate(v any) []apierror.FieldError {
+	var fields []apierror.FieldError
+
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.Struct {
+		fields = validateStruct(val, "")
+	}
+
+	if validator, ok := v.(Validator); ok {
+		fields = append(fields, validator.Validate()...)
+	}
+
+	return fields
+}
+
+func validateStruct(val reflect.Value, prefix string) []apierror.FieldError {
+	var fields []apierror.FieldError
+
+	typ := val.Type()
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		value := val.Field(i)
+		if patch, ok := value.Interface().(patchField); ok {
+			var present bool
+			if value, present = patch.patchValue(); !present {
+				continue
+			}
+		}
+
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if fieldErr, ok := checkRules(name, value, rules); !ok {
+				fields = append(fields, fieldErr)
+				continue
+			}
+		}
+
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			fields = append(fields, validateStruct(value, name)...)
+		}
+	}
+
+	return fields
+}
+
+// jsonName returns the key of field in JSON documents
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// checkRules returns the error of the first rule value breaks
+func checkRules(name string, value reflect.Value, rules string) (apierror.FieldError, bool) {
+	empty := value.IsZero()
+
+	for rule := range strings.SplitSeq(rules, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+
+		if rule == "required" {
+			if empty {
+				return apierror.FieldError{Field: name, Code: CodeRequired, Message: "is required"}, false
+			}
+			continue
+		}
+		if empty {
+			continue
+		}
+
+		s, isString := stringValue(value)
+		if !isString {
+			panic(fmt.Sprintf("request: rule %q on non-string field %v", rule, name))
+		}
+
+		switch rule {
+		case "email":
+			addr, err := mail.ParseAddress(s)
+			if err != nil || addr.Address != s {
+				return apierror.FieldError{Field: name, Code: CodeInvalidEmail, Message: "must be an email address"}, false
+			}
+		case "url":
+			u, err := url.Parse(s)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return apierror.FieldError{Field: name, Code: CodeInvalidURL, Message: "must be an http or https URL"}, false
+			}
+		case "uuid":
+			if _, err := uuid.Parse(s); err != nil {
+				return apierror.FieldError{Field: name, Code: CodeInvalidUUID, Message: "must be a UUID"}, false
+			}
+		case "min":
+			if utf8.RuneCountInString(s) < ruleInt(name, rule, arg) {
+				return apierror.FieldError{Field: name, Code: CodeTooShort, Message: fmt.Sprintf("must be at least %v characters", arg)}, false
+			}
+		case "max":
+			if utf8.RuneCountInString(s) > ruleInt(name, rule, arg) {
+				return apierror.FieldError{Field: name, Code: CodeTooLong, Message: fmt.Sprintf("must be at most %v characters", arg)}, false
+			}
+		case "oneof":
+			values := strings.Fields(arg)
+			if !slices.Contains(values, s) {
+				return apierror.FieldError{Field: name, Code: CodeInvalidValue, Message: "must be one of " + strings.Join(values, ", ")}, false
+			}
+		default:
+			panic(fmt.Sprintf("request: unknown rule %q on field %v", rule, name))
+		}
+	}
+
+	return apierror.FieldError{}, true
+}
+
+func stringValue(value reflect.Value) (string, bool) {
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.String {
+		return "", false
+	}
+	return value.String(), true
+}
+
+// ruleInt parses the argument of a min or max rule, a malformed tag is
+// a programming error
+func ruleInt(name, rule, arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		panic(fmt.Sprintf("request: invalid argument %q of rule %q on field %v", arg, rule, name))
+	}
+	return n
+}
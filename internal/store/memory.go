@@ -0,0 +1,1269 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory implements Store in memory with the semantics of the Postgres
+// schema, it is meant for tests
+type Memory struct {
+	// mu is nil inside a transaction, which holds the lock of the
+	// store it was started from
+	mu   *sync.Mutex
+	data *memoryData
+}
+
+type memoryData struct {
+	users       map[uuid.UUID]User
+	chirps      map[uuid.UUID]Chirp
+	scheduled   map[uuid.UUID]ScheduledChirp
+	drafts      map[uuid.UUID]Draft
+	previews    map[string]LinkPreview
+	tokens      map[string]RefreshToken
+	blocks      map[relation]time.Time
+	mutes       map[relation]time.Time
+	reports     map[uuid.UUID]Report
+	actions     []ModerationAction
+	signingKeys []SigningKey
+}
+
+// relation is a block or a mute of to by from
+type relation struct {
+	from, to uuid.UUID
+}
+
+// NewMemory returns an empty in-memory Store
+func NewMemory() *Memory {
+	return &Memory{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			users:     make(map[uuid.UUID]User),
+			chirps:    make(map[uuid.UUID]Chirp),
+			scheduled: make(map[uuid.UUID]ScheduledChirp),
+			drafts:    make(map[uuid.UUID]Draft),
+			previews:  make(map[string]LinkPreview),
+			tokens:    make(map[string]RefreshToken),
+			blocks:    make(map[relation]time.Time),
+			mutes:     make(map[relation]time.Time),
+			reports:   make(map[uuid.UUID]Report),
+		},
+	}
+}
+
+// InTx runs fn on a copy of the data that replaces it when fn succeeds,
+// other callers wait until the transaction ends
+func (m *Memory) InTx(ctx context.Context, fn func(Store) error) error {
+	if m.mu == nil {
+		return fn(m)
+	}
+
+	defer m.lock()()
+
+	tx := &Memory{data: m.data.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.data = tx.data
+	return nil
+}
+
+// ClaimTx is InTx, transactions of Memory already run one at a time
+func (m *Memory) ClaimTx(ctx context.Context, fn func(Store) error) error {
+	return m.InTx(ctx, fn)
+}
+
+// lock locks the store outside of a transaction and returns the
+// function that unlocks it
+func (m *Memory) lock() func() {
+	if m.mu == nil {
+		return func() {}
+	}
+
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:       maps.Clone(d.users),
+		chirps:      maps.Clone(d.chirps),
+		scheduled:   maps.Clone(d.scheduled),
+		drafts:      maps.Clone(d.drafts),
+		previews:    maps.Clone(d.previews),
+		tokens:      maps.Clone(d.tokens),
+		blocks:      maps.Clone(d.blocks),
+		mutes:       maps.Clone(d.mutes),
+		reports:     maps.Clone(d.reports),
+		actions:     slices.Clone(d.actions),
+		signingKeys: slices.Clone(d.signingKeys),
+	}
+}
+
+// AddSigningKey stores a signing key as is, letting tests pick its
+// creation and retirement times
+func (m *Memory) AddSigningKey(key SigningKey) {
+	defer m.lock()()
+
+	m.data.signingKeys = append(m.data.signingKeys, key)
+}
+
+func (m *Memory) CreateUser(ctx context.Context, email, hashedPassword string) (User, error) {
+	defer m.lock()()
+
+	if m.emailTaken(email, uuid.Nil) {
+		return User{}, ErrEmailTaken
+	}
+
+	now := time.Now()
+	user := User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          email,
+		HashedPassword: hashedPassword,
+	}
+	m.data.users[user.ID] = user
+
+	return user, nil
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	defer m.lock()()
+
+	for _, user := range m.data.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrNotFound
+}
+
+func (m *Memory) UpdateUserEmailAndPassword(ctx context.Context, id uuid.UUID, email, hashedPassword string) (User, error) {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	if m.emailTaken(email, id) {
+		return User{}, ErrEmailTaken
+	}
+
+	user.Email = email
+	user.HashedPassword = hashedPassword
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return user, nil
+}
+
+func (m *Memory) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	defer m.lock()()
+
+	for _, user := range m.data.users {
+		if handle != "" && user.Handle == handle {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrNotFound
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, user User) (User, error) {
+	defer m.lock()()
+
+	stored, ok := m.data.users[user.ID]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	if m.emailTaken(user.Email, user.ID) {
+		return User{}, ErrEmailTaken
+	}
+	for _, other := range m.data.users {
+		if user.Handle != "" && other.Handle == user.Handle && other.ID != user.ID {
+			return User{}, ErrHandleTaken
+		}
+	}
+
+	stored.Email = user.Email
+	stored.HashedPassword = user.HashedPassword
+	stored.Handle = user.Handle
+	stored.DisplayName = user.DisplayName
+	stored.Bio = user.Bio
+	stored.AvatarURL = user.AvatarURL
+	stored.Location = user.Location
+	stored.UpdatedAt = time.Now()
+	m.data.users[user.ID] = stored
+
+	return stored, nil
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.HashedPassword = hashedPassword
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.IsChirpyRed = true
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	user.SuspendedAt = &now
+	user.UpdatedAt = now
+	m.data.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.SuspendedAt = nil
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) SetModerator(ctx context.Context, id uuid.UUID, moderator bool) error {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.IsModerator = moderator
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+
+	if _, ok := m.data.users[id]; !ok {
+		return ErrNotFound
+	}
+
+	m.deleteUser(id)
+	return nil
+}
+
+func (m *Memory) SoftDeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok || user.Deleted() {
+		return User{}, ErrNotFound
+	}
+
+	now := time.Now()
+	user.DeletedAt = &now
+	user.UpdatedAt = now
+	m.data.users[id] = user
+
+	return user, nil
+}
+
+func (m *Memory) RestoreUser(ctx context.Context, id uuid.UUID) (User, error) {
+	defer m.lock()()
+
+	user, ok := m.data.users[id]
+	if !ok || !user.Deleted() {
+		return User{}, ErrNotFound
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	m.data.users[id] = user
+
+	return user, nil
+}
+
+func (m *Memory) PurgeDeletedUsers(ctx context.Context, grace time.Duration) (int64, error) {
+	defer m.lock()()
+
+	deletedBefore := time.Now().Add(-grace)
+
+	var purged int64
+	for id, user := range m.data.users {
+		if user.Deleted() && user.DeletedAt.Before(deletedBefore) {
+			m.deleteUser(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, chirp Chirp) (Chirp, error) {
+	defer m.lock()()
+
+	// foreign keys on users and chirps
+	if _, ok := m.data.users[chirp.UserID]; !ok {
+		return Chirp{}, ErrNotFound
+	}
+	if _, ok := m.data.chirps[chirp.ReplyToID]; chirp.ReplyToID != uuid.Nil && !ok {
+		return Chirp{}, ErrNotFound
+	}
+
+	now := time.Now()
+	created := Chirp{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		ReplyToID:   chirp.ReplyToID,
+		Attachments: slices.Clone(chirp.Attachments),
+	}
+	m.data.chirps[created.ID] = created
+
+	return created, nil
+}
+
+func (m *Memory) GetChirp(ctx context.Context, id, viewerID uuid.UUID) (Chirp, error) {
+	defer m.lock()()
+
+	chirp, ok := m.data.chirps[id]
+	if !ok || !m.visible(chirp, viewerID) {
+		return Chirp{}, ErrNotFound
+	}
+
+	if chirp.DeletedAt != nil {
+		return Chirp{}, ErrDeleted
+	}
+
+	return chirp, nil
+}
+
+func (m *Memory) ListChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	return m.listChirps(viewerID, func(c Chirp) bool {
+		_, blocked := m.data.blocks[relation{viewerID, c.UserID}]
+		_, muted := m.data.mutes[relation{viewerID, c.UserID}]
+		return !blocked && !muted
+	}), nil
+}
+
+func (m *Memory) ListChirpsByUser(ctx context.Context, userID, viewerID uuid.UUID) ([]Chirp, error) {
+	return m.listChirps(viewerID, func(c Chirp) bool { return c.UserID == userID }), nil
+}
+
+func (m *Memory) CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer m.lock()()
+
+	var count int64
+	for _, chirp := range m.data.chirps {
+		if chirp.UserID == userID && chirp.DeletedAt == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, id, deletedBy uuid.UUID) error {
+	defer m.lock()()
+
+	chirp, ok := m.data.chirps[id]
+	if !ok || chirp.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	chirp.DeletedAt = &now
+	chirp.DeletedBy = deletedBy
+	m.data.chirps[id] = chirp
+
+	return nil
+}
+
+func (m *Memory) RestoreChirp(ctx context.Context, id, userID uuid.UUID, window time.Duration) (Chirp, error) {
+	defer m.lock()()
+
+	deletedAfter := time.Now().Add(-window)
+
+	chirp, ok := m.data.chirps[id]
+	if !ok || chirp.UserID != userID || chirp.DeletedBy != userID || chirp.DeletedAt == nil || !chirp.DeletedAt.After(deletedAfter) {
+		return Chirp{}, ErrNotFound
+	}
+
+	chirp.DeletedAt = nil
+	chirp.DeletedBy = uuid.Nil
+	m.data.chirps[id] = chirp
+
+	return chirp, nil
+}
+
+func (m *Memory) PurgeDeletedChirps(ctx context.Context, retention time.Duration) (int64, error) {
+	defer m.lock()()
+
+	deletedBefore := time.Now().Add(-retention)
+
+	var purged int64
+	for id, chirp := range m.data.chirps {
+		if chirp.DeletedAt != nil && chirp.DeletedAt.Before(deletedBefore) {
+			delete(m.data.chirps, id)
+			m.unlinkChirp(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (m *Memory) DeleteAllChirps(ctx context.Context) (int64, error) {
+	return m.deleteChirps(func(Chirp) bool { return true }), nil
+}
+
+func (m *Memory) DeleteUserChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.deleteChirps(func(chirp Chirp) bool { return chirp.UserID == userID }), nil
+}
+
+// deleteChirps deletes for good the chirps matching match and returns
+// how many were deleted
+func (m *Memory) deleteChirps(match func(Chirp) bool) int64 {
+	defer m.lock()()
+
+	var deleted int64
+	for id, chirp := range m.data.chirps {
+		if match(chirp) {
+			delete(m.data.chirps, id)
+			m.unlinkChirp(id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+func (m *Memory) ScheduleChirp(ctx context.Context, userID uuid.UUID, body string, publishAt time.Time) (ScheduledChirp, error) {
+	defer m.lock()()
+
+	// foreign key on users
+	if _, ok := m.data.users[userID]; !ok {
+		return ScheduledChirp{}, ErrNotFound
+	}
+
+	now := time.Now()
+	scheduled := ScheduledChirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt,
+	}
+	m.data.scheduled[scheduled.ID] = scheduled
+
+	return scheduled, nil
+}
+
+func (m *Memory) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	defer m.lock()()
+
+	result := []ScheduledChirp{}
+	for _, scheduled := range m.data.scheduled {
+		if scheduled.UserID == userID {
+			result = append(result, scheduled)
+		}
+	}
+	sortScheduledChirps(result)
+
+	return result, nil
+}
+
+func (m *Memory) CancelScheduledChirp(ctx context.Context, id, userID uuid.UUID) error {
+	defer m.lock()()
+
+	scheduled, ok := m.data.scheduled[id]
+	if !ok || scheduled.UserID != userID {
+		return ErrNotFound
+	}
+
+	delete(m.data.scheduled, id)
+	return nil
+}
+
+func (m *Memory) ClaimDueChirps(ctx context.Context, limit int) ([]ScheduledChirp, error) {
+	defer m.lock()()
+
+	dueBefore := time.Now()
+
+	var due []ScheduledChirp
+	for _, scheduled := range m.data.scheduled {
+		author := m.data.users[scheduled.UserID]
+		if !scheduled.PublishAt.After(dueBefore) && !author.Suspended() && !author.Deleted() {
+			due = append(due, scheduled)
+		}
+	}
+	sortScheduledChirps(due)
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, scheduled := range due {
+		delete(m.data.scheduled, scheduled.ID)
+	}
+
+	return due, nil
+}
+
+func (m *Memory) PublishScheduledChirp(ctx context.Context, scheduled ScheduledChirp) (Chirp, error) {
+	defer m.lock()()
+
+	// foreign key on users
+	if _, ok := m.data.users[scheduled.UserID]; !ok {
+		return Chirp{}, ErrNotFound
+	}
+
+	now := time.Now()
+	chirp := Chirp{
+		ID:        scheduled.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      scheduled.Body,
+		UserID:    scheduled.UserID,
+	}
+	m.data.chirps[chirp.ID] = chirp
+
+	return chirp, nil
+}
+
+// sortScheduledChirps sorts the chirps by the time they are published
+func sortScheduledChirps(scheduled []ScheduledChirp) {
+	slices.SortFunc(scheduled, func(a, b ScheduledChirp) int {
+		return a.PublishAt.Compare(b.PublishAt)
+	})
+}
+
+func (m *Memory) CreateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	defer m.lock()()
+
+	// foreign keys on users and chirps
+	if _, ok := m.data.users[draft.UserID]; !ok {
+		return Draft{}, ErrNotFound
+	}
+	if _, ok := m.data.chirps[draft.ReplyToID]; draft.ReplyToID != uuid.Nil && !ok {
+		return Draft{}, ErrNotFound
+	}
+
+	now := time.Now()
+	created := Draft{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		UserID:      draft.UserID,
+		Body:        draft.Body,
+		ReplyToID:   draft.ReplyToID,
+		Attachments: slices.Clone(draft.Attachments),
+	}
+	m.data.drafts[created.ID] = created
+
+	return created, nil
+}
+
+func (m *Memory) GetDraft(ctx context.Context, id, userID uuid.UUID) (Draft, error) {
+	defer m.lock()()
+
+	draft, ok := m.data.drafts[id]
+	if !ok || draft.UserID != userID {
+		return Draft{}, ErrNotFound
+	}
+
+	return draft, nil
+}
+
+func (m *Memory) ListDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error) {
+	defer m.lock()()
+
+	drafts := []Draft{}
+	for _, draft := range m.data.drafts {
+		if draft.UserID == userID {
+			drafts = append(drafts, draft)
+		}
+	}
+
+	slices.SortFunc(drafts, func(a, b Draft) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+
+	return drafts, nil
+}
+
+func (m *Memory) UpdateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	defer m.lock()()
+
+	updated, ok := m.data.drafts[draft.ID]
+	if !ok || updated.UserID != draft.UserID {
+		return Draft{}, ErrNotFound
+	}
+	// foreign key on chirps
+	if _, ok := m.data.chirps[draft.ReplyToID]; draft.ReplyToID != uuid.Nil && !ok {
+		return Draft{}, ErrNotFound
+	}
+
+	updated.Body = draft.Body
+	updated.ReplyToID = draft.ReplyToID
+	updated.Attachments = slices.Clone(draft.Attachments)
+	updated.UpdatedAt = time.Now()
+	m.data.drafts[draft.ID] = updated
+
+	return updated, nil
+}
+
+func (m *Memory) DeleteDraft(ctx context.Context, id, userID uuid.UUID) error {
+	defer m.lock()()
+
+	draft, ok := m.data.drafts[id]
+	if !ok || draft.UserID != userID {
+		return ErrNotFound
+	}
+
+	delete(m.data.drafts, id)
+	return nil
+}
+
+func (m *Memory) PublishDraft(ctx context.Context, draft Draft) (Chirp, error) {
+	defer m.lock()()
+
+	// foreign keys on users and chirps
+	if _, ok := m.data.users[draft.UserID]; !ok {
+		return Chirp{}, ErrNotFound
+	}
+	if _, ok := m.data.chirps[draft.ReplyToID]; draft.ReplyToID != uuid.Nil && !ok {
+		return Chirp{}, ErrNotFound
+	}
+
+	now := time.Now()
+	chirp := Chirp{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Body:        draft.Body,
+		UserID:      draft.UserID,
+		ReplyToID:   draft.ReplyToID,
+		Attachments: slices.Clone(draft.Attachments),
+	}
+	m.data.chirps[chirp.ID] = chirp
+
+	return chirp, nil
+}
+
+func (m *Memory) RequestLinkPreviews(ctx context.Context, urls []string) error {
+	defer m.lock()()
+
+	now := time.Now()
+	for _, url := range urls {
+		if _, ok := m.data.previews[url]; ok {
+			continue
+		}
+
+		m.data.previews[url] = LinkPreview{
+			URL:       url,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Status:    LinkPreviewPending,
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) ListPendingLinkPreviews(ctx context.Context, limit int) ([]string, error) {
+	defer m.lock()()
+
+	var pending []LinkPreview
+	for _, preview := range m.data.previews {
+		if preview.Status == LinkPreviewPending {
+			pending = append(pending, preview)
+		}
+	}
+
+	slices.SortFunc(pending, func(a, b LinkPreview) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	urls := make([]string, 0, len(pending))
+	for _, preview := range pending {
+		urls = append(urls, preview.URL)
+	}
+
+	return urls, nil
+}
+
+func (m *Memory) SaveLinkPreview(ctx context.Context, preview LinkPreview) error {
+	defer m.lock()()
+
+	stored, ok := m.data.previews[preview.URL]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	stored.Status = preview.Status
+	stored.Title = preview.Title
+	stored.Description = preview.Description
+	stored.ImageURL = preview.ImageURL
+	stored.SiteName = preview.SiteName
+	stored.FetchedAt = &now
+	stored.UpdatedAt = now
+	m.data.previews[preview.URL] = stored
+
+	return nil
+}
+
+func (m *Memory) GetLinkPreviews(ctx context.Context, urls []string) ([]LinkPreview, error) {
+	defer m.lock()()
+
+	previews := []LinkPreview{}
+	for _, url := range urls {
+		preview, ok := m.data.previews[url]
+		if ok && preview.Status == LinkPreviewReady {
+			previews = append(previews, preview)
+		}
+	}
+
+	return previews, nil
+}
+
+func (m *Memory) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return m.addRelation(blocksOf, blockerID, blockedID)
+}
+
+func (m *Memory) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	defer m.lock()()
+
+	delete(m.data.blocks, relation{blockerID, blockedID})
+	return nil
+}
+
+func (m *Memory) ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]User, error) {
+	return m.listRelated(blocksOf, blockerID), nil
+}
+
+func (m *Memory) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return m.addRelation(mutesOf, muterID, mutedID)
+}
+
+func (m *Memory) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	defer m.lock()()
+
+	delete(m.data.mutes, relation{muterID, mutedID})
+	return nil
+}
+
+func (m *Memory) ListMutedUsers(ctx context.Context, muterID uuid.UUID) ([]User, error) {
+	return m.listRelated(mutesOf, muterID), nil
+}
+
+func (m *Memory) CreateReport(ctx context.Context, report Report) (Report, error) {
+	defer m.lock()()
+
+	_, authorOK := m.data.users[report.AuthorID]
+	_, reporterOK := m.data.users[report.ReporterID]
+	if !authorOK || !reporterOK {
+		return Report{}, ErrNotFound
+	}
+	if _, ok := m.data.chirps[report.ChirpID]; report.ChirpID != uuid.Nil && !ok {
+		return Report{}, ErrNotFound
+	}
+
+	for _, other := range m.data.reports {
+		if report.ChirpID != uuid.Nil && other.ChirpID == report.ChirpID && other.ReporterID == report.ReporterID {
+			return Report{}, ErrAlreadyReported
+		}
+	}
+
+	now := time.Now()
+	report.ID = uuid.New()
+	report.CreatedAt = now
+	report.UpdatedAt = now
+	report.Status = ReportOpen
+	report.ResolvedAt = nil
+	m.data.reports[report.ID] = report
+
+	return report, nil
+}
+
+func (m *Memory) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	defer m.lock()()
+
+	report, ok := m.data.reports[id]
+	if !ok {
+		return Report{}, ErrNotFound
+	}
+
+	return report, nil
+}
+
+func (m *Memory) ListReports(ctx context.Context, status string) ([]Report, error) {
+	defer m.lock()()
+
+	reports := []Report{}
+	for _, report := range m.data.reports {
+		if report.Status == status {
+			reports = append(reports, report)
+		}
+	}
+
+	slices.SortStableFunc(reports, func(a, b Report) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return reports, nil
+}
+
+func (m *Memory) CountOpenReports(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	defer m.lock()()
+
+	var count int64
+	for _, report := range m.data.reports {
+		if chirpID != uuid.Nil && report.ChirpID == chirpID && report.Status == ReportOpen {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Memory) ResolveReports(ctx context.Context, reportID uuid.UUID, status string) error {
+	defer m.lock()()
+
+	resolved, ok := m.data.reports[reportID]
+	if !ok || resolved.Status != ReportOpen {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	for id, report := range m.data.reports {
+		sameChirp := resolved.ChirpID != uuid.Nil && report.ChirpID == resolved.ChirpID
+		if report.Status == ReportOpen && (id == reportID || sameChirp) {
+			report.Status = status
+			report.ResolvedAt = &now
+			report.UpdatedAt = now
+			m.data.reports[id] = report
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) HideChirp(ctx context.Context, id uuid.UUID) error {
+	defer m.lock()()
+
+	chirp, ok := m.data.chirps[id]
+	if !ok || chirp.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if chirp.HiddenAt == nil {
+		now := time.Now()
+		chirp.HiddenAt = &now
+		m.data.chirps[id] = chirp
+	}
+
+	return nil
+}
+
+func (m *Memory) RecordModerationAction(ctx context.Context, action ModerationAction) (ModerationAction, error) {
+	defer m.lock()()
+
+	action.ID = uuid.New()
+	action.CreatedAt = time.Now()
+	m.data.actions = append(m.data.actions, action)
+
+	return action, nil
+}
+
+func (m *Memory) ListModerationActions(ctx context.Context) ([]ModerationAction, error) {
+	defer m.lock()()
+
+	actions := slices.Clone(m.data.actions)
+	slices.Reverse(actions)
+
+	return actions, nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) (RefreshToken, error) {
+	defer m.lock()()
+
+	if _, ok := m.data.users[userID]; !ok {
+		return RefreshToken{}, ErrNotFound
+	}
+
+	now := time.Now()
+	refreshToken := RefreshToken{
+		Token:     token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	m.data.tokens[token] = refreshToken
+
+	return refreshToken, nil
+}
+
+func (m *Memory) GetUserByRefreshToken(ctx context.Context, token string) (User, error) {
+	defer m.lock()()
+
+	refreshToken, ok := m.data.tokens[token]
+	if !ok || refreshToken.RevokedAt != nil || !refreshToken.ExpiresAt.After(time.Now()) {
+		return User{}, ErrNotFound
+	}
+
+	user, ok := m.data.users[refreshToken.UserID]
+	if !ok || user.Deleted() {
+		return User{}, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, token string) error {
+	defer m.lock()()
+
+	// like the UPDATE in Postgres, revoking an unknown token is not an error
+	refreshToken, ok := m.data.tokens[token]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	refreshToken.RevokedAt = &now
+	refreshToken.UpdatedAt = now
+	m.data.tokens[token] = refreshToken
+
+	return nil
+}
+
+func (m *Memory) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	defer m.lock()()
+
+	var revoked int64
+	now := time.Now()
+	for token, refreshToken := range m.data.tokens {
+		if refreshToken.UserID == userID && refreshToken.RevokedAt == nil {
+			refreshToken.RevokedAt = &now
+			refreshToken.UpdatedAt = now
+			m.data.tokens[token] = refreshToken
+			revoked++
+		}
+	}
+
+	return revoked, nil
+}
+
+func (m *Memory) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	defer m.lock()()
+
+	tokens := []RefreshToken{}
+	for _, refreshToken := range m.data.tokens {
+		if refreshToken.UserID == userID {
+			tokens = append(tokens, refreshToken)
+		}
+	}
+
+	slices.SortStableFunc(tokens, func(a, b RefreshToken) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return tokens, nil
+}
+
+func (m *Memory) ListSigningKeys(ctx context.Context, lifetime time.Duration) ([]SigningKey, error) {
+	defer m.lock()()
+
+	cutoff := time.Now().Add(-lifetime)
+
+	var keys []SigningKey
+	for _, key := range m.data.signingKeys {
+		if key.RetiredAt == nil || key.RetiredAt.After(cutoff) {
+			key.PastLifetime = key.CreatedAt.Before(cutoff)
+			keys = append(keys, key)
+		}
+	}
+
+	slices.SortStableFunc(keys, func(a, b SigningKey) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (m *Memory) RotateSigningKey(ctx context.Context, id, secret string) (SigningKey, error) {
+	defer m.lock()()
+
+	now := time.Now()
+	for i, key := range m.data.signingKeys {
+		if key.ID == id {
+			return SigningKey{}, errors.New("signing key already exists")
+		}
+		if key.RetiredAt == nil {
+			m.data.signingKeys[i].RetiredAt = &now
+		}
+	}
+
+	key := SigningKey{ID: id, Secret: secret, CreatedAt: now}
+	m.data.signingKeys = append(m.data.signingKeys, key)
+
+	return key, nil
+}
+
+func (m *Memory) GetStats(ctx context.Context) (Stats, error) {
+	defer m.lock()()
+
+	var stats Stats
+	for _, user := range m.data.users {
+		stats.Users++
+		if user.IsChirpyRed {
+			stats.ChirpyRedUsers++
+		}
+		if user.Suspended() {
+			stats.SuspendedUsers++
+		}
+	}
+	for _, chirp := range m.data.chirps {
+		if chirp.DeletedAt == nil {
+			stats.Chirps++
+		} else {
+			stats.DeletedChirps++
+		}
+	}
+	now := time.Now()
+	for _, token := range m.data.tokens {
+		if token.RevokedAt == nil && token.ExpiresAt.After(now) {
+			stats.ActiveRefreshTokens++
+		}
+	}
+
+	return stats, nil
+}
+
+// emailTaken reports whether a user other than id has the email, the
+// caller holds the lock
+func (m *Memory) emailTaken(email string, id uuid.UUID) bool {
+	for _, user := range m.data.users {
+		if user.Email == email && user.ID != id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteUser deletes the user and, like ON DELETE CASCADE, its chirps,
+// scheduled chirps, drafts, refresh tokens, blocks, mutes and the
+// reports by or about the user, the caller holds the lock. The chirps
+// the user deleted as a moderator are kept.
+func (m *Memory) deleteUser(id uuid.UUID) {
+	delete(m.data.users, id)
+
+	for reportID, report := range m.data.reports {
+		if report.AuthorID == id || report.ReporterID == id {
+			delete(m.data.reports, reportID)
+		}
+	}
+
+	for _, relations := range []map[relation]time.Time{m.data.blocks, m.data.mutes} {
+		for r := range relations {
+			if r.from == id || r.to == id {
+				delete(relations, r)
+			}
+		}
+	}
+
+	for chirpID, chirp := range m.data.chirps {
+		switch {
+		case chirp.UserID == id:
+			delete(m.data.chirps, chirpID)
+			m.unlinkChirp(chirpID)
+		case chirp.DeletedBy == id:
+			// ON DELETE SET NULL
+			chirp.DeletedBy = uuid.Nil
+			m.data.chirps[chirpID] = chirp
+		}
+	}
+	for scheduledID, scheduled := range m.data.scheduled {
+		if scheduled.UserID == id {
+			delete(m.data.scheduled, scheduledID)
+		}
+	}
+	for draftID, draft := range m.data.drafts {
+		if draft.UserID == id {
+			delete(m.data.drafts, draftID)
+		}
+	}
+	for token, refreshToken := range m.data.tokens {
+		if refreshToken.UserID == id {
+			delete(m.data.tokens, token)
+		}
+	}
+}
+
+// unlinkChirp keeps the reports, replies and drafts of a deleted chirp,
+// like ON DELETE SET NULL, the caller holds the lock
+func (m *Memory) unlinkChirp(chirpID uuid.UUID) {
+	for id, report := range m.data.reports {
+		if report.ChirpID == chirpID {
+			report.ChirpID = uuid.Nil
+			m.data.reports[id] = report
+		}
+	}
+	for id, chirp := range m.data.chirps {
+		if chirp.ReplyToID == chirpID {
+			chirp.ReplyToID = uuid.Nil
+			m.data.chirps[id] = chirp
+		}
+	}
+	for id, draft := range m.data.drafts {
+		if draft.ReplyToID == chirpID {
+			draft.ReplyToID = uuid.Nil
+			m.data.drafts[id] = draft
+		}
+	}
+}
+
+// visible reports whether viewerID may read the chirp, the caller
+// holds the lock
+func (m *Memory) visible(chirp Chirp, viewerID uuid.UUID) bool {
+	if m.data.users[chirp.UserID].Deleted() {
+		return false
+	}
+
+	if chirp.HiddenAt != nil && chirp.UserID != viewerID {
+		return false
+	}
+
+	_, blocked := m.data.blocks[relation{chirp.UserID, viewerID}]
+	return !blocked
+}
+
+func blocksOf(d *memoryData) map[relation]time.Time { return d.blocks }
+func mutesOf(d *memoryData) map[relation]time.Time  { return d.mutes }
+
+// addRelation stores a block or mute, like the foreign keys it needs
+// both users to exist
+func (m *Memory) addRelation(kind func(*memoryData) map[relation]time.Time, from, to uuid.UUID) error {
+	defer m.lock()()
+
+	relations := kind(m.data)
+
+	_, fromOK := m.data.users[from]
+	_, toOK := m.data.users[to]
+	if !fromOK || !toOK {
+		return ErrNotFound
+	}
+
+	r := relation{from, to}
+	if _, ok := relations[r]; !ok {
+		relations[r] = time.Now()
+	}
+
+	return nil
+}
+
+// listRelated returns the users from blocked or muted, oldest first
+func (m *Memory) listRelated(kind func(*memoryData) map[relation]time.Time, from uuid.UUID) []User {
+	defer m.lock()()
+
+	relations := kind(m.data)
+
+	var related []relation
+	for r := range relations {
+		if r.from == from {
+			related = append(related, r)
+		}
+	}
+
+	slices.SortStableFunc(related, func(a, b relation) int {
+		return relations[a].Compare(relations[b])
+	})
+
+	users := make([]User, 0, len(related))
+	for _, r := range related {
+		users = append(users, m.data.users[r.to])
+	}
+
+	return users
+}
+
+func (m *Memory) listChirps(viewerID uuid.UUID, keep func(Chirp) bool) []Chirp {
+	defer m.lock()()
+
+	chirps := []Chirp{}
+	for _, chirp := range m.data.chirps {
+		if chirp.DeletedAt == nil && keep(chirp) && m.visible(chirp, viewerID) {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	slices.SortStableFunc(chirps, func(a, b Chirp) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return chirps
+}
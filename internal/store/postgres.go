@@ -0,0 +1,879 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/tracing"
+)
+
+// Postgres error codes of UNIQUE and FOREIGN KEY constraint failures
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// emailConstraint is the UNIQUE(email) constraint of users
+const emailConstraint = "users_email_key"
+
+// handleConstraint is the unique index on users.handle
+const handleConstraint = "users_handle_key"
+
+// reportConstraint lets each user report a chirp once
+const reportConstraint = "reports_chirp_id_reporter_id_key"
+
+// Postgres implements Store with the sqlc queries of internal/database,
+// every query is traced as a child of the span in its context
+type Postgres struct {
+	// db is nil inside a transaction
+	db *sql.DB
+	q  *database.Queries
+}
+
+// NewPostgres returns a Store running its queries on db
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db, q: database.New(tracing.WrapDB(db, tracing.SystemPostgreSQL))}
+}
+
+// InTx runs fn with a Store bound to a transaction, see Tx
+func (p *Postgres) InTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+
+	return Tx(ctx, p.db, func(tx *sql.Tx) error {
+		return fn(&Postgres{q: database.New(tracing.WrapDB(tx, tracing.SystemPostgreSQL))})
+	})
+}
+
+// ClaimTx runs fn with a Store bound to a transaction, see
+// ReadCommittedTx
+func (p *Postgres) ClaimTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+
+	return ReadCommittedTx(ctx, p.db, func(tx *sql.Tx) error {
+		return fn(&Postgres{q: database.New(tracing.WrapDB(tx, tracing.SystemPostgreSQL))})
+	})
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, email, hashedPassword string) (User, error) {
+	user, err := p.q.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := p.q.GetUserByID(ctx, id)
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	user, err := p.q.GetUserByEmail(ctx, email)
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) UpdateUserEmailAndPassword(ctx context.Context, id uuid.UUID, email, hashedPassword string) (User, error) {
+	user, err := p.q.UpdateUserEmailAndPass(ctx, database.UpdateUserEmailAndPassParams{
+		HashedPassword: hashedPassword,
+		Email:          email,
+		ID:             id,
+	})
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	user, err := p.q.GetUserByHandle(ctx, nullString(handle))
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) UpdateUser(ctx context.Context, user User) (User, error) {
+	updated, err := p.q.UpdateUser(ctx, database.UpdateUserParams{
+		ID:             user.ID,
+		Email:          user.Email,
+		HashedPassword: user.HashedPassword,
+		Handle:         nullString(user.Handle),
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarUrl:      user.AvatarURL,
+		Location:       user.Location,
+	})
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(updated), nil
+}
+
+func (p *Postgres) UpdateUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	rows, err := p.q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		ID:             id,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := p.q.UpgradeUserByID(ctx, id)
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := p.q.SuspendUserByID(ctx, id)
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := p.q.UnsuspendUserByID(ctx, id)
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) SetModerator(ctx context.Context, id uuid.UUID, moderator bool) error {
+	rows, err := p.q.SetUserModerator(ctx, database.SetUserModeratorParams{
+		ID:          id,
+		IsModerator: moderator,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := p.q.DeleteUserByID(ctx, id)
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) SoftDeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := p.q.SoftDeleteUserByID(ctx, id)
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) RestoreUser(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := p.q.RestoreUserByID(ctx, id)
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) PurgeDeletedUsers(ctx context.Context, grace time.Duration) (int64, error) {
+	purged, err := p.q.PurgeDeletedUsers(ctx, grace.Seconds())
+	return purged, pgError(err)
+}
+
+func (p *Postgres) CreateChirp(ctx context.Context, chirp Chirp) (Chirp, error) {
+	created, err := p.q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		ReplyToID:   nullUUID(chirp.ReplyToID),
+		Attachments: textArray(chirp.Attachments),
+	})
+	if err != nil {
+		return Chirp{}, pgError(err)
+	}
+
+	return pgChirp(created), nil
+}
+
+func (p *Postgres) GetChirp(ctx context.Context, id, viewerID uuid.UUID) (Chirp, error) {
+	chirp, err := p.q.GetChirpByID(ctx, database.GetChirpByIDParams{
+		ID:       id,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return Chirp{}, pgError(err)
+	}
+
+	if chirp.DeletedAt.Valid {
+		return Chirp{}, ErrDeleted
+	}
+
+	return pgChirp(chirp), nil
+}
+
+func (p *Postgres) ListChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	chirps, err := p.q.GetAllChirps(ctx, viewerID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgChirps(chirps), nil
+}
+
+func (p *Postgres) ListChirpsByUser(ctx context.Context, userID, viewerID uuid.UUID) ([]Chirp, error) {
+	chirps, err := p.q.GetChirpsByUserID(ctx, database.GetChirpsByUserIDParams{
+		UserID:   userID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgChirps(chirps), nil
+}
+
+func (p *Postgres) CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := p.q.CountChirpsByUserID(ctx, userID)
+	return count, pgError(err)
+}
+
+func (p *Postgres) DeleteChirp(ctx context.Context, id, deletedBy uuid.UUID) error {
+	rows, err := p.q.SoftDeleteChirpByID(ctx, database.SoftDeleteChirpByIDParams{
+		DeletedBy: nullUUID(deletedBy),
+		ID:        id,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) RestoreChirp(ctx context.Context, id, userID uuid.UUID, window time.Duration) (Chirp, error) {
+	chirp, err := p.q.RestoreChirpByID(ctx, database.RestoreChirpByIDParams{
+		ID:            id,
+		UserID:        userID,
+		WindowSeconds: window.Seconds(),
+	})
+	if err != nil {
+		return Chirp{}, pgError(err)
+	}
+
+	return pgChirp(chirp), nil
+}
+
+func (p *Postgres) PurgeDeletedChirps(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := p.q.PurgeDeletedChirps(ctx, retention.Seconds())
+	return purged, pgError(err)
+}
+
+func (p *Postgres) DeleteAllChirps(ctx context.Context) (int64, error) {
+	deleted, err := p.q.DeleteAllChirps(ctx)
+	return deleted, pgError(err)
+}
+
+func (p *Postgres) DeleteUserChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	deleted, err := p.q.DeleteChirpsByUserID(ctx, userID)
+	return deleted, pgError(err)
+}
+
+func (p *Postgres) ScheduleChirp(ctx context.Context, userID uuid.UUID, body string, publishAt time.Time) (ScheduledChirp, error) {
+	scheduled, err := p.q.CreateScheduledChirp(ctx, database.CreateScheduledChirpParams{
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt.UTC(),
+	})
+	if err != nil {
+		return ScheduledChirp{}, pgError(err)
+	}
+
+	return ScheduledChirp(scheduled), nil
+}
+
+func (p *Postgres) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	scheduled, err := p.q.GetScheduledChirpsByUserID(ctx, userID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgScheduledChirps(scheduled), nil
+}
+
+func (p *Postgres) CancelScheduledChirp(ctx context.Context, id, userID uuid.UUID) error {
+	rows, err := p.q.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+		ID:     id,
+		UserID: userID,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) ClaimDueChirps(ctx context.Context, limit int) ([]ScheduledChirp, error) {
+	scheduled, err := p.q.ClaimDueScheduledChirps(ctx, int32(limit))
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgScheduledChirps(scheduled), nil
+}
+
+func (p *Postgres) PublishScheduledChirp(ctx context.Context, scheduled ScheduledChirp) (Chirp, error) {
+	chirp, err := p.q.PublishScheduledChirp(ctx, database.PublishScheduledChirpParams{
+		ID:     scheduled.ID,
+		Body:   scheduled.Body,
+		UserID: scheduled.UserID,
+	})
+	if err != nil {
+		return Chirp{}, pgError(err)
+	}
+
+	return pgChirp(chirp), nil
+}
+
+func (p *Postgres) CreateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	created, err := p.q.CreateDraft(ctx, database.CreateDraftParams{
+		UserID:      draft.UserID,
+		Body:        draft.Body,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: textArray(draft.Attachments),
+	})
+	if err != nil {
+		return Draft{}, pgError(err)
+	}
+
+	return pgDraft(created), nil
+}
+
+func (p *Postgres) GetDraft(ctx context.Context, id, userID uuid.UUID) (Draft, error) {
+	draft, err := p.q.GetDraftByID(ctx, database.GetDraftByIDParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return Draft{}, pgError(err)
+	}
+
+	return pgDraft(draft), nil
+}
+
+func (p *Postgres) ListDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error) {
+	drafts, err := p.q.GetDraftsByUserID(ctx, userID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	result := make([]Draft, 0, len(drafts))
+	for _, draft := range drafts {
+		result = append(result, pgDraft(draft))
+	}
+
+	return result, nil
+}
+
+func (p *Postgres) UpdateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	updated, err := p.q.UpdateDraft(ctx, database.UpdateDraftParams{
+		Body:        draft.Body,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: textArray(draft.Attachments),
+		ID:          draft.ID,
+		UserID:      draft.UserID,
+	})
+	if err != nil {
+		return Draft{}, pgError(err)
+	}
+
+	return pgDraft(updated), nil
+}
+
+func (p *Postgres) DeleteDraft(ctx context.Context, id, userID uuid.UUID) error {
+	rows, err := p.q.DeleteDraft(ctx, database.DeleteDraftParams{
+		ID:     id,
+		UserID: userID,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) PublishDraft(ctx context.Context, draft Draft) (Chirp, error) {
+	chirp, err := p.q.PublishDraft(ctx, database.PublishDraftParams{
+		Body:        draft.Body,
+		UserID:      draft.UserID,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: textArray(draft.Attachments),
+	})
+	if err != nil {
+		return Chirp{}, pgError(err)
+	}
+
+	return pgChirp(chirp), nil
+}
+
+func (p *Postgres) RequestLinkPreviews(ctx context.Context, urls []string) error {
+	for _, url := range urls {
+		if err := p.q.CreateLinkPreview(ctx, url); err != nil {
+			return pgError(err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListPendingLinkPreviews(ctx context.Context, limit int) ([]string, error) {
+	urls, err := p.q.GetPendingLinkPreviews(ctx, int32(limit))
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return urls, nil
+}
+
+func (p *Postgres) SaveLinkPreview(ctx context.Context, preview LinkPreview) error {
+	rows, err := p.q.UpdateLinkPreview(ctx, database.UpdateLinkPreviewParams{
+		Url:         preview.URL,
+		Status:      preview.Status,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageUrl:    preview.ImageURL,
+		SiteName:    preview.SiteName,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) GetLinkPreviews(ctx context.Context, urls []string) ([]LinkPreview, error) {
+	previews, err := p.q.GetReadyLinkPreviews(ctx, urls)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	result := make([]LinkPreview, 0, len(previews))
+	for _, preview := range previews {
+		result = append(result, LinkPreview{
+			URL:         preview.Url,
+			CreatedAt:   preview.CreatedAt,
+			UpdatedAt:   preview.UpdatedAt,
+			Status:      preview.Status,
+			Title:       preview.Title,
+			Description: preview.Description,
+			ImageURL:    preview.ImageUrl,
+			SiteName:    preview.SiteName,
+			FetchedAt:   nullTime(preview.FetchedAt),
+		})
+	}
+
+	return result, nil
+}
+
+func (p *Postgres) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return pgError(p.q.CreateBlock(ctx, database.CreateBlockParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}))
+}
+
+func (p *Postgres) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return pgError(p.q.DeleteBlock(ctx, database.DeleteBlockParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}))
+}
+
+func (p *Postgres) ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]User, error) {
+	users, err := p.q.GetBlockedUsers(ctx, blockerID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgUsers(users), nil
+}
+
+func (p *Postgres) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return pgError(p.q.CreateMute(ctx, database.CreateMuteParams{
+		MuterID: muterID,
+		MutedID: mutedID,
+	}))
+}
+
+func (p *Postgres) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return pgError(p.q.DeleteMute(ctx, database.DeleteMuteParams{
+		MuterID: muterID,
+		MutedID: mutedID,
+	}))
+}
+
+func (p *Postgres) ListMutedUsers(ctx context.Context, muterID uuid.UUID) ([]User, error) {
+	users, err := p.q.GetMutedUsers(ctx, muterID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	return pgUsers(users), nil
+}
+
+func (p *Postgres) CreateReport(ctx context.Context, report Report) (Report, error) {
+	created, err := p.q.CreateReport(ctx, database.CreateReportParams{
+		ChirpID:    nullUUID(report.ChirpID),
+		AuthorID:   report.AuthorID,
+		ReporterID: report.ReporterID,
+		ChirpBody:  report.ChirpBody,
+		Reason:     report.Reason,
+		Details:    report.Details,
+	})
+	if err != nil {
+		return Report{}, pgError(err)
+	}
+
+	return pgReport(created), nil
+}
+
+func (p *Postgres) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	report, err := p.q.GetReportByID(ctx, id)
+	if err != nil {
+		return Report{}, pgError(err)
+	}
+
+	return pgReport(report), nil
+}
+
+func (p *Postgres) ListReports(ctx context.Context, status string) ([]Report, error) {
+	rows, err := p.q.GetReportsByStatus(ctx, status)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	reports := make([]Report, 0, len(rows))
+	for _, row := range rows {
+		reports = append(reports, pgReport(row))
+	}
+
+	return reports, nil
+}
+
+func (p *Postgres) CountOpenReports(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	count, err := p.q.CountOpenReports(ctx, nullUUID(chirpID))
+	return count, pgError(err)
+}
+
+func (p *Postgres) ResolveReports(ctx context.Context, reportID uuid.UUID, status string) error {
+	rows, err := p.q.ResolveReports(ctx, database.ResolveReportsParams{
+		Status: status,
+		ID:     reportID,
+	})
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) HideChirp(ctx context.Context, id uuid.UUID) error {
+	rows, err := p.q.HideChirpByID(ctx, id)
+	return pgRowsError(rows, err)
+}
+
+func (p *Postgres) RecordModerationAction(ctx context.Context, action ModerationAction) (ModerationAction, error) {
+	recorded, err := p.q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ModeratorID: nullUUID(action.ModeratorID),
+		Action:      action.Action,
+		ReportID:    nullUUID(action.ReportID),
+		ChirpID:     nullUUID(action.ChirpID),
+		UserID:      nullUUID(action.UserID),
+		Note:        action.Note,
+	})
+	if err != nil {
+		return ModerationAction{}, pgError(err)
+	}
+
+	return pgModerationAction(recorded), nil
+}
+
+func (p *Postgres) ListModerationActions(ctx context.Context) ([]ModerationAction, error) {
+	rows, err := p.q.GetModerationActions(ctx)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	actions := make([]ModerationAction, 0, len(rows))
+	for _, row := range rows {
+		actions = append(actions, pgModerationAction(row))
+	}
+
+	return actions, nil
+}
+
+func (p *Postgres) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) (RefreshToken, error) {
+	refreshToken, err := p.q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return RefreshToken{}, pgError(err)
+	}
+
+	return pgRefreshToken(refreshToken), nil
+}
+
+func (p *Postgres) GetUserByRefreshToken(ctx context.Context, token string) (User, error) {
+	user, err := p.q.GetUserByRefreshToken(ctx, token)
+	if err != nil {
+		return User{}, pgError(err)
+	}
+
+	return pgUser(user), nil
+}
+
+func (p *Postgres) RevokeRefreshToken(ctx context.Context, token string) error {
+	return pgError(p.q.RevokeRefreshToken(ctx, token))
+}
+
+func (p *Postgres) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	revoked, err := p.q.RevokeAllRefreshTokensForUser(ctx, userID)
+	return revoked, pgError(err)
+}
+
+func (p *Postgres) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := p.q.GetRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	tokens := make([]RefreshToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, pgRefreshToken(row))
+	}
+
+	return tokens, nil
+}
+
+func (p *Postgres) ListSigningKeys(ctx context.Context, lifetime time.Duration) ([]SigningKey, error) {
+	rows, err := p.q.GetValidSigningKeys(ctx, lifetime.Seconds())
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	keys := make([]SigningKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, SigningKey{
+			ID:           row.ID,
+			Secret:       row.Secret,
+			CreatedAt:    row.CreatedAt,
+			RetiredAt:    nullTime(row.RetiredAt),
+			PastLifetime: row.PastLifetime,
+		})
+	}
+
+	return keys, nil
+}
+
+func (p *Postgres) RotateSigningKey(ctx context.Context, id, secret string) (SigningKey, error) {
+	key, err := p.q.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:     id,
+		Secret: secret,
+	})
+	if err != nil {
+		return SigningKey{}, pgError(err)
+	}
+
+	if err := p.q.RetireSigningKeysExcept(ctx, key.ID); err != nil {
+		return SigningKey{}, pgError(err)
+	}
+
+	return SigningKey{ID: key.ID, Secret: key.Secret, CreatedAt: key.CreatedAt}, nil
+}
+
+func (p *Postgres) GetStats(ctx context.Context) (Stats, error) {
+	stats, err := p.q.GetStats(ctx)
+	if err != nil {
+		return Stats{}, pgError(err)
+	}
+
+	return Stats(stats), nil
+}
+
+// pgError translates the driver errors handlers care about into the
+// errors of this package
+func pgError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		switch pqErr.Constraint {
+		case emailConstraint:
+			return ErrEmailTaken
+		case handleConstraint:
+			return ErrHandleTaken
+		case reportConstraint:
+			return ErrAlreadyReported
+		}
+	}
+
+	// the row referenced by the new one does not exist
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// pgRowsError reports ErrNotFound when a statement affected no rows
+func pgRowsError(rows int64, err error) error {
+	if err != nil {
+		return pgError(err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func pgUser(u database.User) User {
+	return User{
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+		IsChirpyRed:    u.IsChirpyRed,
+		SuspendedAt:    nullTime(u.SuspendedAt),
+		IsModerator:    u.IsModerator,
+		Handle:         u.Handle.String,
+		DisplayName:    u.DisplayName,
+		Bio:            u.Bio,
+		AvatarURL:      u.AvatarUrl,
+		Location:       u.Location,
+		DeletedAt:      nullTime(u.DeletedAt),
+	}
+}
+
+func pgUsers(users []database.User) []User {
+	result := make([]User, 0, len(users))
+	for _, user := range users {
+		result = append(result, pgUser(user))
+	}
+
+	return result
+}
+
+func pgRefreshToken(t database.RefreshToken) RefreshToken {
+	return RefreshToken{
+		Token:     t.Token,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: nullTime(t.RevokedAt),
+	}
+}
+
+func pgChirp(c database.Chirp) Chirp {
+	return Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		HiddenAt:  nullTime(c.HiddenAt),
+		DeletedAt: nullTime(c.DeletedAt),
+		DeletedBy: c.DeletedBy.UUID,
+
+		ReplyToID:   c.ReplyToID.UUID,
+		Attachments: c.Attachments,
+	}
+}
+
+func pgChirps(chirps []database.Chirp) []Chirp {
+	result := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		result = append(result, pgChirp(chirp))
+	}
+
+	return result
+}
+
+func pgScheduledChirps(scheduled []database.ScheduledChirp) []ScheduledChirp {
+	result := make([]ScheduledChirp, 0, len(scheduled))
+	for _, chirp := range scheduled {
+		result = append(result, ScheduledChirp(chirp))
+	}
+
+	return result
+}
+
+func pgReport(r database.Report) Report {
+	return Report{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		ChirpID:    r.ChirpID.UUID,
+		AuthorID:   r.AuthorID,
+		ReporterID: r.ReporterID,
+		ChirpBody:  r.ChirpBody,
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ResolvedAt: nullTime(r.ResolvedAt),
+	}
+}
+
+func pgModerationAction(a database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:          a.ID,
+		CreatedAt:   a.CreatedAt,
+		ModeratorID: a.ModeratorID.UUID,
+		Action:      a.Action,
+		ReportID:    a.ReportID.UUID,
+		ChirpID:     a.ChirpID.UUID,
+		UserID:      a.UserID.UUID,
+		Note:        a.Note,
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func pgDraft(d database.Draft) Draft {
+	return Draft{
+		ID:          d.ID,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+		UserID:      d.UserID,
+		Body:        d.Body,
+		ReplyToID:   d.ReplyToID.UUID,
+		Attachments: d.Attachments,
+	}
+}
+
+// textArray stores a nil slice as an empty array, lib/pq would send
+// NULL
+func textArray(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// nullUUID stores uuid.Nil as NULL
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// nullString stores an empty string as NULL, so unique columns hold
+// any number of unset values
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,956 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/luis-octavius/chirpy/internal/sqlitedb"
+	"github.com/luis-octavius/chirpy/internal/tracing"
+)
+
+// SQLiteDriver is the database/sql driver name of SQLite
+const SQLiteDriver = "sqlite"
+
+// SQLite implements Store with the sqlc queries of internal/sqlitedb.
+//
+// SQLite has no gen_random_uuid() nor NOW(), so IDs and timestamps are
+// generated here. Timestamps are stored in UTC so they compare and sort
+// as text the way they do as TIMESTAMP in Postgres.
+type SQLite struct {
+	// db is nil inside a transaction
+	db *sql.DB
+	q  *sqlitedb.Queries
+}
+
+// NewSQLite returns a Store running its queries on db, which must be
+// opened with a DSN from SQLiteDSN
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db, q: sqlitedb.New(tracing.WrapDB(db, tracing.SystemSQLite))}
+}
+
+// InTx runs fn with a Store bound to a transaction, see Tx
+func (s *SQLite) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+
+	return Tx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&SQLite{q: sqlitedb.New(tracing.WrapDB(tx, tracing.SystemSQLite))})
+	})
+}
+
+// ClaimTx is InTx, SQLite runs one write transaction at a time so
+// claims cannot conflict
+func (s *SQLite) ClaimTx(ctx context.Context, fn func(Store) error) error {
+	return s.InTx(ctx, fn)
+}
+
+// SQLiteDSN turns a database file path into a DSN that enables the
+// foreign keys needed for cascade deletes, waits on a locked database
+// instead of failing, and writes times in a format SQLite can compare.
+// Transactions take the write lock when they begin, so two of them
+// cannot both read and then fail to upgrade their lock.
+func SQLiteDSN(path string) string {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_time_format", "sqlite")
+	params.Set("_txlock", "immediate")
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return path + separator + params.Encode()
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, email, hashedPassword string) (User, error) {
+	user, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := s.q.GetUserByID(ctx, id)
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	user, err := s.q.GetUserByEmail(ctx, email)
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) UpdateUserEmailAndPassword(ctx context.Context, id uuid.UUID, email, hashedPassword string) (User, error) {
+	user, err := s.q.UpdateUserEmailAndPass(ctx, sqlitedb.UpdateUserEmailAndPassParams{
+		HashedPassword: hashedPassword,
+		Email:          email,
+		Now:            now(),
+		ID:             id,
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	user, err := s.q.GetUserByHandle(ctx, nullString(handle))
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) UpdateUser(ctx context.Context, user User) (User, error) {
+	updated, err := s.q.UpdateUser(ctx, sqlitedb.UpdateUserParams{
+		Email:          user.Email,
+		HashedPassword: user.HashedPassword,
+		Handle:         nullString(user.Handle),
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarUrl:      user.AvatarURL,
+		Location:       user.Location,
+		Now:            now(),
+		ID:             user.ID,
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(updated), nil
+}
+
+func (s *SQLite) UpdateUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	rows, err := s.q.UpdateUserPassword(ctx, sqlitedb.UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		Now:            now(),
+		ID:             id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := s.q.UpgradeUserByID(ctx, sqlitedb.UpgradeUserByIDParams{
+		Now: now(),
+		ID:  id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := s.q.SuspendUserByID(ctx, sqlitedb.SuspendUserByIDParams{
+		Now: sql.NullTime{Time: now(), Valid: true},
+		ID:  id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := s.q.UnsuspendUserByID(ctx, sqlitedb.UnsuspendUserByIDParams{
+		Now: now(),
+		ID:  id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) SetModerator(ctx context.Context, id uuid.UUID, moderator bool) error {
+	rows, err := s.q.SetUserModerator(ctx, sqlitedb.SetUserModeratorParams{
+		IsModerator: moderator,
+		Now:         now(),
+		ID:          id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	rows, err := s.q.DeleteUserByID(ctx, id)
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) SoftDeleteUser(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := s.q.SoftDeleteUserByID(ctx, sqlitedb.SoftDeleteUserByIDParams{
+		Now: sql.NullTime{Time: now(), Valid: true},
+		ID:  id,
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) RestoreUser(ctx context.Context, id uuid.UUID) (User, error) {
+	user, err := s.q.RestoreUserByID(ctx, sqlitedb.RestoreUserByIDParams{
+		Now: now(),
+		ID:  id,
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+// PurgeDeletedUsers computes the cutoff with now(), the clock
+// SoftDeleteUser sets deleted_at with
+func (s *SQLite) PurgeDeletedUsers(ctx context.Context, grace time.Duration) (int64, error) {
+	purged, err := s.q.PurgeDeletedUsers(ctx, sql.NullTime{Time: now().Add(-grace), Valid: true})
+	return purged, sqliteError(err)
+}
+
+func (s *SQLite) CreateChirp(ctx context.Context, chirp Chirp) (Chirp, error) {
+	created, err := s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		ID:          uuid.New(),
+		Now:         now(),
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		ReplyToID:   nullUUID(chirp.ReplyToID),
+		Attachments: joinLines(chirp.Attachments),
+	})
+	if err != nil {
+		return Chirp{}, sqliteError(err)
+	}
+
+	return sqliteChirp(created), nil
+}
+
+func (s *SQLite) GetChirp(ctx context.Context, id, viewerID uuid.UUID) (Chirp, error) {
+	chirp, err := s.q.GetChirpByID(ctx, sqlitedb.GetChirpByIDParams{
+		ID:       id,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return Chirp{}, sqliteError(err)
+	}
+
+	if chirp.DeletedAt.Valid {
+		return Chirp{}, ErrDeleted
+	}
+
+	return sqliteChirp(chirp), nil
+}
+
+func (s *SQLite) ListChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	chirps, err := s.q.GetAllChirps(ctx, viewerID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteChirps(chirps), nil
+}
+
+func (s *SQLite) ListChirpsByUser(ctx context.Context, userID, viewerID uuid.UUID) ([]Chirp, error) {
+	chirps, err := s.q.GetChirpsByUserID(ctx, sqlitedb.GetChirpsByUserIDParams{
+		UserID:   userID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteChirps(chirps), nil
+}
+
+func (s *SQLite) CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := s.q.CountChirpsByUserID(ctx, userID)
+	return count, sqliteError(err)
+}
+
+func (s *SQLite) DeleteChirp(ctx context.Context, id, deletedBy uuid.UUID) error {
+	rows, err := s.q.SoftDeleteChirpByID(ctx, sqlitedb.SoftDeleteChirpByIDParams{
+		Now:       sql.NullTime{Time: now(), Valid: true},
+		DeletedBy: nullUUID(deletedBy),
+		ID:        id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+// RestoreChirp computes the cutoff with now(), the clock DeleteChirp
+// sets deleted_at with
+func (s *SQLite) RestoreChirp(ctx context.Context, id, userID uuid.UUID, window time.Duration) (Chirp, error) {
+	chirp, err := s.q.RestoreChirpByID(ctx, sqlitedb.RestoreChirpByIDParams{
+		ID:           id,
+		UserID:       userID,
+		DeletedAfter: sql.NullTime{Time: now().Add(-window), Valid: true},
+	})
+	if err != nil {
+		return Chirp{}, sqliteError(err)
+	}
+
+	return sqliteChirp(chirp), nil
+}
+
+func (s *SQLite) PurgeDeletedChirps(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.q.PurgeDeletedChirps(ctx, sql.NullTime{Time: now().Add(-retention), Valid: true})
+	return purged, sqliteError(err)
+}
+
+func (s *SQLite) DeleteAllChirps(ctx context.Context) (int64, error) {
+	deleted, err := s.q.DeleteAllChirps(ctx)
+	return deleted, sqliteError(err)
+}
+
+func (s *SQLite) DeleteUserChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	deleted, err := s.q.DeleteChirpsByUserID(ctx, userID)
+	return deleted, sqliteError(err)
+}
+
+func (s *SQLite) ScheduleChirp(ctx context.Context, userID uuid.UUID, body string, publishAt time.Time) (ScheduledChirp, error) {
+	scheduled, err := s.q.CreateScheduledChirp(ctx, sqlitedb.CreateScheduledChirpParams{
+		ID:        uuid.New(),
+		Now:       now(),
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt.UTC(),
+	})
+	if err != nil {
+		return ScheduledChirp{}, sqliteError(err)
+	}
+
+	return ScheduledChirp(scheduled), nil
+}
+
+func (s *SQLite) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	scheduled, err := s.q.GetScheduledChirpsByUserID(ctx, userID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteScheduledChirps(scheduled), nil
+}
+
+func (s *SQLite) CancelScheduledChirp(ctx context.Context, id, userID uuid.UUID) error {
+	rows, err := s.q.DeleteScheduledChirp(ctx, sqlitedb.DeleteScheduledChirpParams{
+		ID:     id,
+		UserID: userID,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) ClaimDueChirps(ctx context.Context, limit int) ([]ScheduledChirp, error) {
+	scheduled, err := s.q.ClaimDueScheduledChirps(ctx, sqlitedb.ClaimDueScheduledChirpsParams{
+		DueBefore: now(),
+		MaxChirps: int64(limit),
+	})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteScheduledChirps(scheduled), nil
+}
+
+func (s *SQLite) PublishScheduledChirp(ctx context.Context, scheduled ScheduledChirp) (Chirp, error) {
+	chirp, err := s.q.PublishScheduledChirp(ctx, sqlitedb.PublishScheduledChirpParams{
+		ID:     scheduled.ID,
+		Now:    now(),
+		Body:   scheduled.Body,
+		UserID: scheduled.UserID,
+	})
+	if err != nil {
+		return Chirp{}, sqliteError(err)
+	}
+
+	return sqliteChirp(chirp), nil
+}
+
+func (s *SQLite) CreateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	created, err := s.q.CreateDraft(ctx, sqlitedb.CreateDraftParams{
+		ID:          uuid.New(),
+		Now:         now(),
+		UserID:      draft.UserID,
+		Body:        draft.Body,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: joinLines(draft.Attachments),
+	})
+	if err != nil {
+		return Draft{}, sqliteError(err)
+	}
+
+	return sqliteDraft(created), nil
+}
+
+func (s *SQLite) GetDraft(ctx context.Context, id, userID uuid.UUID) (Draft, error) {
+	draft, err := s.q.GetDraftByID(ctx, sqlitedb.GetDraftByIDParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return Draft{}, sqliteError(err)
+	}
+
+	return sqliteDraft(draft), nil
+}
+
+func (s *SQLite) ListDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error) {
+	drafts, err := s.q.GetDraftsByUserID(ctx, userID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	result := make([]Draft, 0, len(drafts))
+	for _, draft := range drafts {
+		result = append(result, sqliteDraft(draft))
+	}
+
+	return result, nil
+}
+
+func (s *SQLite) UpdateDraft(ctx context.Context, draft Draft) (Draft, error) {
+	updated, err := s.q.UpdateDraft(ctx, sqlitedb.UpdateDraftParams{
+		Body:        draft.Body,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: joinLines(draft.Attachments),
+		Now:         now(),
+		ID:          draft.ID,
+		UserID:      draft.UserID,
+	})
+	if err != nil {
+		return Draft{}, sqliteError(err)
+	}
+
+	return sqliteDraft(updated), nil
+}
+
+func (s *SQLite) DeleteDraft(ctx context.Context, id, userID uuid.UUID) error {
+	rows, err := s.q.DeleteDraft(ctx, sqlitedb.DeleteDraftParams{
+		ID:     id,
+		UserID: userID,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) PublishDraft(ctx context.Context, draft Draft) (Chirp, error) {
+	chirp, err := s.q.PublishDraft(ctx, sqlitedb.PublishDraftParams{
+		ID:          uuid.New(),
+		Now:         now(),
+		Body:        draft.Body,
+		UserID:      draft.UserID,
+		ReplyToID:   nullUUID(draft.ReplyToID),
+		Attachments: joinLines(draft.Attachments),
+	})
+	if err != nil {
+		return Chirp{}, sqliteError(err)
+	}
+
+	return sqliteChirp(chirp), nil
+}
+
+func (s *SQLite) RequestLinkPreviews(ctx context.Context, urls []string) error {
+	for _, url := range urls {
+		err := s.q.CreateLinkPreview(ctx, sqlitedb.CreateLinkPreviewParams{
+			Url: url,
+			Now: now(),
+		})
+		if err != nil {
+			return sqliteError(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLite) ListPendingLinkPreviews(ctx context.Context, limit int) ([]string, error) {
+	urls, err := s.q.GetPendingLinkPreviews(ctx, int64(limit))
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return urls, nil
+}
+
+func (s *SQLite) SaveLinkPreview(ctx context.Context, preview LinkPreview) error {
+	rows, err := s.q.UpdateLinkPreview(ctx, sqlitedb.UpdateLinkPreviewParams{
+		Url:         preview.URL,
+		Status:      preview.Status,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageUrl:    preview.ImageURL,
+		SiteName:    preview.SiteName,
+		Now:         sql.NullTime{Time: now(), Valid: true},
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) GetLinkPreviews(ctx context.Context, urls []string) ([]LinkPreview, error) {
+	previews, err := s.q.GetReadyLinkPreviews(ctx, urls)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	result := make([]LinkPreview, 0, len(previews))
+	for _, preview := range previews {
+		result = append(result, LinkPreview{
+			URL:         preview.Url,
+			CreatedAt:   preview.CreatedAt,
+			UpdatedAt:   preview.UpdatedAt,
+			Status:      preview.Status,
+			Title:       preview.Title,
+			Description: preview.Description,
+			ImageURL:    preview.ImageUrl,
+			SiteName:    preview.SiteName,
+			FetchedAt:   nullTime(preview.FetchedAt),
+		})
+	}
+
+	return result, nil
+}
+
+func (s *SQLite) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return sqliteError(s.q.CreateBlock(ctx, sqlitedb.CreateBlockParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		Now:       now(),
+	}))
+}
+
+func (s *SQLite) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return sqliteError(s.q.DeleteBlock(ctx, sqlitedb.DeleteBlockParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}))
+}
+
+func (s *SQLite) ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]User, error) {
+	users, err := s.q.GetBlockedUsers(ctx, blockerID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteUsers(users), nil
+}
+
+func (s *SQLite) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return sqliteError(s.q.CreateMute(ctx, sqlitedb.CreateMuteParams{
+		MuterID: muterID,
+		MutedID: mutedID,
+		Now:     now(),
+	}))
+}
+
+func (s *SQLite) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return sqliteError(s.q.DeleteMute(ctx, sqlitedb.DeleteMuteParams{
+		MuterID: muterID,
+		MutedID: mutedID,
+	}))
+}
+
+func (s *SQLite) ListMutedUsers(ctx context.Context, muterID uuid.UUID) ([]User, error) {
+	users, err := s.q.GetMutedUsers(ctx, muterID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return sqliteUsers(users), nil
+}
+
+func (s *SQLite) CreateReport(ctx context.Context, report Report) (Report, error) {
+	created, err := s.q.CreateReport(ctx, sqlitedb.CreateReportParams{
+		ID:         uuid.New(),
+		Now:        now(),
+		ChirpID:    nullUUID(report.ChirpID),
+		AuthorID:   report.AuthorID,
+		ReporterID: report.ReporterID,
+		ChirpBody:  report.ChirpBody,
+		Reason:     report.Reason,
+		Details:    report.Details,
+	})
+	if err != nil {
+		return Report{}, sqliteError(err)
+	}
+
+	return sqliteReport(created), nil
+}
+
+func (s *SQLite) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	report, err := s.q.GetReportByID(ctx, id)
+	if err != nil {
+		return Report{}, sqliteError(err)
+	}
+
+	return sqliteReport(report), nil
+}
+
+func (s *SQLite) ListReports(ctx context.Context, status string) ([]Report, error) {
+	rows, err := s.q.GetReportsByStatus(ctx, status)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	reports := make([]Report, 0, len(rows))
+	for _, row := range rows {
+		reports = append(reports, sqliteReport(row))
+	}
+
+	return reports, nil
+}
+
+func (s *SQLite) CountOpenReports(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	count, err := s.q.CountOpenReports(ctx, nullUUID(chirpID))
+	return count, sqliteError(err)
+}
+
+func (s *SQLite) ResolveReports(ctx context.Context, reportID uuid.UUID, status string) error {
+	rows, err := s.q.ResolveReports(ctx, sqlitedb.ResolveReportsParams{
+		Status: status,
+		Now:    sql.NullTime{Time: now(), Valid: true},
+		ID:     reportID,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) HideChirp(ctx context.Context, id uuid.UUID) error {
+	rows, err := s.q.HideChirpByID(ctx, sqlitedb.HideChirpByIDParams{
+		Now: sql.NullTime{Time: now(), Valid: true},
+		ID:  id,
+	})
+	return sqliteRowsError(rows, err)
+}
+
+func (s *SQLite) RecordModerationAction(ctx context.Context, action ModerationAction) (ModerationAction, error) {
+	recorded, err := s.q.CreateModerationAction(ctx, sqlitedb.CreateModerationActionParams{
+		ID:          uuid.New(),
+		Now:         now(),
+		ModeratorID: nullUUID(action.ModeratorID),
+		Action:      action.Action,
+		ReportID:    nullUUID(action.ReportID),
+		ChirpID:     nullUUID(action.ChirpID),
+		UserID:      nullUUID(action.UserID),
+		Note:        action.Note,
+	})
+	if err != nil {
+		return ModerationAction{}, sqliteError(err)
+	}
+
+	return sqliteModerationAction(recorded), nil
+}
+
+func (s *SQLite) ListModerationActions(ctx context.Context) ([]ModerationAction, error) {
+	rows, err := s.q.GetModerationActions(ctx)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	actions := make([]ModerationAction, 0, len(rows))
+	for _, row := range rows {
+		actions = append(actions, sqliteModerationAction(row))
+	}
+
+	return actions, nil
+}
+
+func (s *SQLite) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) (RefreshToken, error) {
+	refreshToken, err := s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: expiresAt.UTC(),
+		Now:       now(),
+	})
+	if err != nil {
+		return RefreshToken{}, sqliteError(err)
+	}
+
+	return sqliteRefreshToken(refreshToken), nil
+}
+
+func (s *SQLite) GetUserByRefreshToken(ctx context.Context, token string) (User, error) {
+	user, err := s.q.GetUserByRefreshToken(ctx, sqlitedb.GetUserByRefreshTokenParams{
+		Token: token,
+		Now:   now(),
+	})
+	if err != nil {
+		return User{}, sqliteError(err)
+	}
+
+	return sqliteUser(user), nil
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, token string) error {
+	return sqliteError(s.q.RevokeRefreshToken(ctx, sqlitedb.RevokeRefreshTokenParams{
+		Now:   sql.NullTime{Time: now(), Valid: true},
+		Token: token,
+	}))
+}
+
+func (s *SQLite) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	revoked, err := s.q.RevokeAllRefreshTokensForUser(ctx, sqlitedb.RevokeAllRefreshTokensForUserParams{
+		Now:    sql.NullTime{Time: now(), Valid: true},
+		UserID: userID,
+	})
+	return revoked, sqliteError(err)
+}
+
+func (s *SQLite) ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := s.q.GetRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	tokens := make([]RefreshToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, sqliteRefreshToken(row))
+	}
+
+	return tokens, nil
+}
+
+// ListSigningKeys computes the cutoff with now(), the clock
+// RotateSigningKey sets created_at and retired_at with
+func (s *SQLite) ListSigningKeys(ctx context.Context, lifetime time.Duration) ([]SigningKey, error) {
+	cutoff := now().Add(-lifetime)
+
+	rows, err := s.q.GetValidSigningKeys(ctx, sql.NullTime{Time: cutoff, Valid: true})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	keys := make([]SigningKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, SigningKey{
+			ID:           row.ID,
+			Secret:       row.Secret,
+			CreatedAt:    row.CreatedAt,
+			RetiredAt:    nullTime(row.RetiredAt),
+			PastLifetime: row.CreatedAt.Before(cutoff),
+		})
+	}
+
+	return keys, nil
+}
+
+func (s *SQLite) RotateSigningKey(ctx context.Context, id, secret string) (SigningKey, error) {
+	createdAt := now()
+
+	key, err := s.q.CreateSigningKey(ctx, sqlitedb.CreateSigningKeyParams{
+		ID:     id,
+		Secret: secret,
+		Now:    createdAt,
+	})
+	if err != nil {
+		return SigningKey{}, sqliteError(err)
+	}
+
+	err = s.q.RetireSigningKeysExcept(ctx, sqlitedb.RetireSigningKeysExceptParams{
+		Now: sql.NullTime{Time: createdAt, Valid: true},
+		ID:  key.ID,
+	})
+	if err != nil {
+		return SigningKey{}, sqliteError(err)
+	}
+
+	return SigningKey{ID: key.ID, Secret: key.Secret, CreatedAt: key.CreatedAt}, nil
+}
+
+func (s *SQLite) GetStats(ctx context.Context) (Stats, error) {
+	stats, err := s.q.GetStats(ctx, now())
+	if err != nil {
+		return Stats{}, sqliteError(err)
+	}
+
+	return Stats(stats), nil
+}
+
+// now is the timestamp of every row written to SQLite
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// sqliteError translates the driver errors handlers care about into
+// the errors of this package
+func sqliteError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	// SQLite only names the failing column in the message
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		switch msg := sqliteErr.Error(); {
+		case strings.Contains(msg, "users.email"):
+			return ErrEmailTaken
+		case strings.Contains(msg, "users.handle"):
+			return ErrHandleTaken
+		case strings.Contains(msg, "reports.chirp_id"):
+			return ErrAlreadyReported
+		}
+	}
+
+	// the row referenced by the new one does not exist
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// sqliteRowsError reports ErrNotFound when a statement affected no rows
+func sqliteRowsError(rows int64, err error) error {
+	if err != nil {
+		return sqliteError(err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func sqliteUser(u sqlitedb.User) User {
+	return User{
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+		IsChirpyRed:    u.IsChirpyRed,
+		SuspendedAt:    nullTime(u.SuspendedAt),
+		IsModerator:    u.IsModerator,
+		Handle:         u.Handle.String,
+		DisplayName:    u.DisplayName,
+		Bio:            u.Bio,
+		AvatarURL:      u.AvatarUrl,
+		Location:       u.Location,
+		DeletedAt:      nullTime(u.DeletedAt),
+	}
+}
+
+func sqliteUsers(users []sqlitedb.User) []User {
+	result := make([]User, 0, len(users))
+	for _, user := range users {
+		result = append(result, sqliteUser(user))
+	}
+
+	return result
+}
+
+func sqliteRefreshToken(t sqlitedb.RefreshToken) RefreshToken {
+	return RefreshToken{
+		Token:     t.Token,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: nullTime(t.RevokedAt),
+	}
+}
+
+func sqliteChirp(c sqlitedb.Chirp) Chirp {
+	return Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		HiddenAt:  nullTime(c.HiddenAt),
+		DeletedAt: nullTime(c.DeletedAt),
+		DeletedBy: c.DeletedBy.UUID,
+
+		ReplyToID:   c.ReplyToID.UUID,
+		Attachments: splitLines(c.Attachments),
+	}
+}
+
+func sqliteChirps(chirps []sqlitedb.Chirp) []Chirp {
+	result := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		result = append(result, sqliteChirp(chirp))
+	}
+
+	return result
+}
+
+func sqliteDraft(d sqlitedb.Draft) Draft {
+	return Draft{
+		ID:          d.ID,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+		UserID:      d.UserID,
+		Body:        d.Body,
+		ReplyToID:   d.ReplyToID.UUID,
+		Attachments: splitLines(d.Attachments),
+	}
+}
+
+// joinLines stores the attachment URLs one per line, SQLite has no
+// arrays and URLs cannot hold a newline
+func joinLines(values []string) string {
+	return strings.Join(values, "\n")
+}
+
+// splitLines reads the attachment URLs stored by joinLines
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
+
+func sqliteScheduledChirps(scheduled []sqlitedb.ScheduledChirp) []ScheduledChirp {
+	result := make([]ScheduledChirp, 0, len(scheduled))
+	for _, chirp := range scheduled {
+		result = append(result, ScheduledChirp(chirp))
+	}
+
+	return result
+}
+
+func sqliteReport(r sqlitedb.Report) Report {
+	return Report{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		ChirpID:    r.ChirpID.UUID,
+		AuthorID:   r.AuthorID,
+		ReporterID: r.ReporterID,
+		ChirpBody:  r.ChirpBody,
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ResolvedAt: nullTime(r.ResolvedAt),
+	}
+}
+
+func sqliteModerationAction(a sqlitedb.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:          a.ID,
+		CreatedAt:   a.CreatedAt,
+		ModeratorID: a.ModeratorID.UUID,
+		Action:      a.Action,
+		ReportID:    a.ReportID.UUID,
+		ChirpID:     a.ChirpID.UUID,
+		UserID:      a.UserID.UUID,
+		Note:        a.Note,
+	}
+}
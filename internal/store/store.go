@@ -0,0 +1,409 @@
+// Package store defines the repositories the HTTP handlers depend on,
+// with Postgres and SQLite implementations backed by sqlc and an
+// in-memory one for tests that honors the same semantics.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = errors.New("email already in use")
+	// ErrHandleTaken is returned when another user already has the handle
+	ErrHandleTaken = errors.New("handle already in use")
+	// ErrDeleted is returned when reading a chirp that was deleted and
+	// is not purged yet
+	ErrDeleted = errors.New("deleted")
+	// ErrAlreadyReported is returned when the user already reported the
+	// chirp
+	ErrAlreadyReported = errors.New("chirp already reported")
+)
+
+// Statuses of a report, every report starts open
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// Statuses of a link preview, every preview starts pending
+const (
+	LinkPreviewPending = "pending"
+	LinkPreviewReady   = "ready"
+	LinkPreviewFailed  = "failed"
+)
+
+type User struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	HashedPassword string
+	IsChirpyRed    bool
+	SuspendedAt    *time.Time
+	// IsModerator lets the user review reports, it is granted with
+	// `chirpy admin users grant-moderator`
+	IsModerator bool
+
+	// Handle is unique, empty until the user picks one
+	Handle      string
+	DisplayName string
+	Bio         string
+	AvatarURL   string
+	Location    string
+
+	// DeletedAt is set when the user deletes the account, it is purged
+	// with its data once the grace period is over
+	DeletedAt *time.Time
+}
+
+// Suspended reports whether an administrator suspended the user
+func (u User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// Deleted reports whether the account is pending deletion
+func (u User) Deleted() bool {
+	return u.DeletedAt != nil
+}
+
+type Chirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+
+	// HiddenAt is set when the chirp is hidden by moderation, only its
+	// author still sees it
+	HiddenAt *time.Time
+	// DeletedAt is set when the author or a moderator, DeletedBy,
+	// deletes the chirp, it is purged after the retention period
+	DeletedAt *time.Time
+	DeletedBy uuid.UUID
+
+	// ReplyToID is the chirp this one replies to, uuid.Nil when it
+	// replies to none or the chirp was purged
+	ReplyToID uuid.UUID
+	// Attachments are the URLs of the media of the chirp
+	Attachments []string
+}
+
+// ScheduledChirp is a chirp waiting for PublishAt, it is published as
+// a chirp with the same ID
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	PublishAt time.Time
+}
+
+// Draft is an unfinished chirp, only its author can read it
+type Draft struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+
+	// ReplyToID and Attachments are those of the chirp once published
+	ReplyToID   uuid.UUID
+	Attachments []string
+}
+
+// LinkPreview is the card of a URL linked by chirps, built from the
+// Open Graph or Twitter Card metadata of the page
+type LinkPreview struct {
+	URL         string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Status      string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+	FetchedAt   *time.Time
+}
+
+// Report is a chirp reported by a user, it keeps the author and body
+// the chirp had when it was reported
+type Report struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ChirpID is uuid.Nil once the chirp is purged
+	ChirpID    uuid.UUID
+	AuthorID   uuid.UUID
+	ReporterID uuid.UUID
+	ChirpBody  string
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt *time.Time
+}
+
+// ModerationAction is an entry of the audit log of moderation, the IDs
+// it does not concern are uuid.Nil
+type ModerationAction struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	// ModeratorID is uuid.Nil for the actions Chirpy takes on its own
+	ModeratorID uuid.UUID
+	Action      string
+	ReportID    uuid.UUID
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	Note        string
+}
+
+type RefreshToken struct {
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+type SigningKey struct {
+	ID        string
+	Secret    string
+	CreatedAt time.Time
+	RetiredAt *time.Time
+	// PastLifetime is set by ListSigningKeys when the key was created
+	// more than the lifetime ago
+	PastLifetime bool
+}
+
+// Stats counts the rows reported by `chirpy admin stats`
+type Stats struct {
+	Users               int64
+	ChirpyRedUsers      int64
+	SuspendedUsers      int64
+	Chirps              int64
+	DeletedChirps       int64
+	ActiveRefreshTokens int64
+}
+
+// Users stores accounts, emails are unique
+type Users interface {
+	CreateUser(ctx context.Context, email, hashedPassword string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByHandle(ctx context.Context, handle string) (User, error)
+	UpdateUserEmailAndPassword(ctx context.Context, id uuid.UUID, email, hashedPassword string) (User, error)
+	UpdateUserPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	// UpdateUser writes the email, password and profile of user.ID
+	UpdateUser(ctx context.Context, user User) (User, error)
+	UpgradeUser(ctx context.Context, id uuid.UUID) error
+	// SuspendUser blocks the logins of the user, its refresh tokens are
+	// left to the caller
+	SuspendUser(ctx context.Context, id uuid.UUID) error
+	UnsuspendUser(ctx context.Context, id uuid.UUID) error
+	// SetModerator grants or revokes the review of reports
+	SetModerator(ctx context.Context, id uuid.UUID, moderator bool) error
+	// DeleteUser also deletes the chirps and refresh tokens of the user
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	// SoftDeleteUser marks the account for deletion, hiding its chirps,
+	// it returns ErrNotFound when the account is already marked. The
+	// account is purged once grace has passed since its DeletedAt.
+	SoftDeleteUser(ctx context.Context, id uuid.UUID) (User, error)
+	// RestoreUser cancels the deletion of an account during its grace
+	// period, it returns ErrNotFound for accounts not pending deletion
+	RestoreUser(ctx context.Context, id uuid.UUID) (User, error)
+	// PurgeDeletedUsers deletes the accounts marked more than grace ago
+	// like DeleteUser and returns how many were deleted. The store
+	// measures the age with the clock that set deleted_at.
+	PurgeDeletedUsers(ctx context.Context, grace time.Duration) (int64, error)
+}
+
+// Chirps stores chirps, listed from the oldest to the newest.
+//
+// Reads skip the chirps of accounts pending deletion and of authors
+// blocking viewerID, which is uuid.Nil for anonymous readers, and the
+// chirps hidden by moderation unless viewerID is their author.
+type Chirps interface {
+	// CreateChirp creates a chirp with the body, reply target and
+	// attachments of chirp for its UserID
+	CreateChirp(ctx context.Context, chirp Chirp) (Chirp, error)
+	// GetChirp returns ErrDeleted for a deleted chirp that is not
+	// purged yet, lists skip deleted chirps
+	GetChirp(ctx context.Context, id, viewerID uuid.UUID) (Chirp, error)
+	// ListChirps is the timeline of viewerID, it also skips the authors
+	// viewerID blocked or muted
+	ListChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error)
+	ListChirpsByUser(ctx context.Context, userID, viewerID uuid.UUID) ([]Chirp, error)
+	// CountChirpsByUser returns how many chirps ListChirpsByUser lists
+	// for their author, without loading them
+	CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	// DeleteChirp marks the chirp as deleted by deletedBy, who is
+	// either its author or a moderator, the caller checks which. It
+	// returns ErrNotFound when the chirp is already deleted.
+	DeleteChirp(ctx context.Context, id, deletedBy uuid.UUID) error
+	// RestoreChirp undoes the deletion of a chirp by its author userID,
+	// when it was deleted less than window ago. The store measures the
+	// age with the clock that set deleted_at.
+	RestoreChirp(ctx context.Context, id, userID uuid.UUID, window time.Duration) (Chirp, error)
+	// PurgeDeletedChirps deletes for good the chirps deleted more than
+	// retention ago and returns how many were deleted
+	PurgeDeletedChirps(ctx context.Context, retention time.Duration) (int64, error)
+	// DeleteAllChirps and DeleteUserChirps delete chirps for good,
+	// deleted or not, and return how many were deleted
+	DeleteAllChirps(ctx context.Context) (int64, error)
+	DeleteUserChirps(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// ScheduledChirps stores the chirps users scheduled, they are not
+// chirps until they are published
+type ScheduledChirps interface {
+	ScheduleChirp(ctx context.Context, userID uuid.UUID, body string, publishAt time.Time) (ScheduledChirp, error)
+	// ListScheduledChirps returns the chirps the user scheduled, the
+	// next to be published first
+	ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error)
+	// CancelScheduledChirp returns ErrNotFound when the user has no
+	// scheduled chirp with the ID, which includes published ones
+	CancelScheduledChirp(ctx context.Context, id, userID uuid.UUID) error
+	// ClaimDueChirps removes and returns at most limit chirps due now,
+	// by the clock that sets the creation time of chirps. Inside a
+	// transaction, the chirps claimed by another transaction are
+	// skipped, and they are scheduled again when it rolls back. The
+	// chirps of suspended or deleted authors are left scheduled until
+	// the author is reinstated or purged with the account.
+	ClaimDueChirps(ctx context.Context, limit int) ([]ScheduledChirp, error)
+	// PublishScheduledChirp creates the chirp of a claimed scheduled
+	// chirp, created now like any other chirp
+	PublishScheduledChirp(ctx context.Context, scheduled ScheduledChirp) (Chirp, error)
+}
+
+// Drafts stores the unfinished chirps of users, every method but
+// CreateDraft returns ErrNotFound for the drafts of other users
+type Drafts interface {
+	// CreateDraft saves the body, reply target and attachments of the
+	// draft for its UserID
+	CreateDraft(ctx context.Context, draft Draft) (Draft, error)
+	GetDraft(ctx context.Context, id, userID uuid.UUID) (Draft, error)
+	// ListDrafts returns the drafts of the user, the last updated first
+	ListDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error)
+	// UpdateDraft replaces the body, reply target and attachments of the
+	// draft with the ID and UserID of draft
+	UpdateDraft(ctx context.Context, draft Draft) (Draft, error)
+	DeleteDraft(ctx context.Context, id, userID uuid.UUID) error
+	// PublishDraft creates a chirp with the body, reply target and
+	// attachments of the draft, the caller deletes the draft in the same
+	// transaction
+	PublishDraft(ctx context.Context, draft Draft) (Chirp, error)
+}
+
+// LinkPreviews stores the previews of the URLs linked by chirps, shared
+// by every chirp linking to the same URL
+type LinkPreviews interface {
+	// RequestLinkPreviews adds a pending preview of each URL without
+	// one, whatever its status
+	RequestLinkPreviews(ctx context.Context, urls []string) error
+	// ListPendingLinkPreviews returns the URLs of at most limit pending
+	// previews, the oldest requested first
+	ListPendingLinkPreviews(ctx context.Context, limit int) ([]string, error)
+	// SaveLinkPreview stores the fetched preview with its status and
+	// returns ErrNotFound when its URL was never requested
+	SaveLinkPreview(ctx context.Context, preview LinkPreview) error
+	// GetLinkPreviews returns the ready previews among the URLs
+	GetLinkPreviews(ctx context.Context, urls []string) ([]LinkPreview, error)
+}
+
+// Moderation stores the reports of chirps and the audit log of what
+// was done about them, entries of the log are never changed
+type Moderation interface {
+	// CreateReport stores an open report of report.ChirpID, it returns
+	// ErrAlreadyReported when report.ReporterID already reported it
+	CreateReport(ctx context.Context, report Report) (Report, error)
+	GetReport(ctx context.Context, id uuid.UUID) (Report, error)
+	// ListReports returns the reports with the status, oldest first
+	ListReports(ctx context.Context, status string) ([]Report, error)
+	// CountOpenReports returns how many users reported the chirp and
+	// are still waiting for a decision
+	CountOpenReports(ctx context.Context, chirpID uuid.UUID) (int64, error)
+	// ResolveReports gives the status to the report and to every other
+	// open report of its chirp, it returns ErrNotFound when the report
+	// is not open
+	ResolveReports(ctx context.Context, reportID uuid.UUID, status string) error
+	// HideChirp hides the chirp from everyone but its author, hiding
+	// it again is not an error
+	HideChirp(ctx context.Context, id uuid.UUID) error
+	RecordModerationAction(ctx context.Context, action ModerationAction) (ModerationAction, error)
+	// ListModerationActions returns the audit log, newest first
+	ListModerationActions(ctx context.Context) ([]ModerationAction, error)
+}
+
+// Relations stores the users each user blocked or muted, adding a
+// relation twice or removing a missing one is not an error
+type Relations interface {
+	// BlockUser also ends the follows between the two users
+	BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	// ListBlockedUsers returns the users blockerID blocked, from the
+	// oldest block to the newest
+	ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]User, error)
+	MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error
+	UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error
+	// ListMutedUsers returns the users muterID muted, from the oldest
+	// mute to the newest
+	ListMutedUsers(ctx context.Context, muterID uuid.UUID) ([]User, error)
+}
+
+// Tokens stores refresh tokens and JWT signing keys
+type Tokens interface {
+	CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) (RefreshToken, error)
+	// GetUserByRefreshToken only returns the user of a token that is
+	// neither revoked nor expired, and not pending deletion
+	GetUserByRefreshToken(ctx context.Context, token string) (User, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	// RevokeUserRefreshTokens revokes every active token of the user
+	// and returns how many were revoked
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+	// ListRefreshTokens returns every token of the user, revoked and
+	// expired ones included, from the oldest to the newest
+	ListRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	// ListSigningKeys returns the keys that are active or were retired
+	// less than lifetime ago, newest first. The store measures the ages
+	// with the clock that set created_at and retired_at.
+	ListSigningKeys(ctx context.Context, lifetime time.Duration) ([]SigningKey, error)
+	// RotateSigningKey stores a new key and retires every other active
+	// key, callers run it in a transaction so servers never see two
+	// active keys, nor none
+	RotateSigningKey(ctx context.Context, id, secret string) (SigningKey, error)
+}
+
+// Store groups every repository of a backend
+type Store interface {
+	Users
+	Chirps
+	ScheduledChirps
+	Drafts
+	LinkPreviews
+	Relations
+	Moderation
+	Tokens
+
+	// GetStats counts the users, chirps and active refresh tokens
+	GetStats(ctx context.Context) (Stats, error)
+
+	// InTx runs fn with a Store whose reads and writes all happen in a
+	// single transaction, committed when fn returns nil. fn may run
+	// more than once when the transaction conflicts with another one.
+	// Calling InTx on the Store of a transaction reuses it.
+	InTx(ctx context.Context, fn func(Store) error) error
+	// ClaimTx runs fn like InTx for the transactions that claim rows
+	// with ClaimDueChirps. Concurrent claims skip each other's rows, so
+	// the transaction runs at READ COMMITTED where the backend has it
+	// instead of aborting all but one of them.
+	ClaimTx(ctx context.Context, fn func(Store) error) error
+}
@@ -0,0 +1,1251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/fixtures"
+	"github.com/luis-octavius/chirpy/internal/migrate"
+)
+
+// backends returns every Store the tests run against, Postgres only
+// when TEST_DATABASE_URL points to a database the tests may empty
+func backends(t *testing.T) map[string]func(t *testing.T) Store {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemory() },
+		"sqlite": newTestSQLite,
+	}
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		return stores
+	}
+
+	stores["postgres"] = func(t *testing.T) Store {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatalf("error opening test database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		ctx := context.Background()
+		if err := migrate.Up(ctx, db, config.BackendPostgres); err != nil {
+			t.Fatalf("error migrating test database: %v", err)
+		}
+		if err := fixtures.Reset(ctx, fixtures.Postgres(database.New(db))); err != nil {
+			t.Fatalf("error emptying test database: %v", err)
+		}
+
+		return NewPostgres(db)
+	}
+
+	return stores
+}
+
+// newTestSQLite returns a store on a migrated database file that is
+// removed with the test
+func newTestSQLite(t *testing.T) Store {
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(filepath.Join(t.TempDir(), "chirpy.db")))
+	if err != nil {
+		t.Fatalf("error opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate.Up(context.Background(), db, config.BackendSQLite); err != nil {
+		t.Fatalf("error migrating SQLite database: %v", err)
+	}
+
+	return NewSQLite(db)
+}
+
+func TestStore(t *testing.T) {
+	tests := map[string]func(t *testing.T, s Store){
+		"unique email":         testUniqueEmail,
+		"update user":          testUpdateUser,
+		"user profiles":        testUserProfiles,
+		"chirps":               testChirps,
+		"delete chirp":         testDeleteChirp,
+		"scheduled chirps":     testScheduledChirps,
+		"concurrent claims":    testConcurrentClaims,
+		"claims skip authors":  testClaimsSkipSuspendedAuthors,
+		"drafts":               testDrafts,
+		"link previews":        testLinkPreviews,
+		"refresh tokens":       testRefreshTokens,
+		"delete user cascades": testDeleteUserCascades,
+		"soft delete user":     testSoftDeleteUser,
+		"restore user":         testRestoreUser,
+		"blocks and mutes":     testBlocksAndMutes,
+		"moderation":           testModeration,
+		"admin":                testAdmin,
+		"transactions":         testInTx,
+	}
+
+	for backend, newStore := range backends(t) {
+		for name, test := range tests {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				test(t, newStore(t))
+			})
+		}
+	}
+}
+
+func mustCreateUser(t *testing.T, s Store, email string) User {
+	t.Helper()
+
+	user, err := s.CreateUser(context.Background(), email, "hash")
+	if err != nil {
+		t.Fatalf("CreateUser(%q) returned error: %v", email, err)
+	}
+	return user
+}
+
+func testUniqueEmail(t *testing.T, s Store) {
+	ctx := context.Background()
+	user := mustCreateUser(t, s, "walt@example.com")
+
+	if _, err := s.CreateUser(ctx, "walt@example.com", "hash"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected %v, got %v", ErrEmailTaken, err)
+	}
+
+	found, err := s.GetUserByEmail(ctx, "walt@example.com")
+	if err != nil || found.ID != user.ID {
+		t.Errorf("expected user %v, got %v (err %v)", user.ID, found.ID, err)
+	}
+
+	if _, err := s.GetUserByEmail(ctx, "jesse@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func testUpdateUser(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	mustCreateUser(t, s, "jesse@example.com")
+
+	updated, err := s.UpdateUserEmailAndPassword(ctx, walt.ID, "heisenberg@example.com", "new hash")
+	if err != nil {
+		t.Fatalf("UpdateUserEmailAndPassword returned error: %v", err)
+	}
+	if updated.Email != "heisenberg@example.com" || updated.HashedPassword != "new hash" {
+		t.Errorf("expected updated email and password, got %+v", updated)
+	}
+
+	if _, err := s.UpdateUserEmailAndPassword(ctx, walt.ID, "jesse@example.com", "hash"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected %v, got %v", ErrEmailTaken, err)
+	}
+
+	if err := s.UpdateUserPassword(ctx, walt.ID, "rehashed"); err != nil {
+		t.Fatalf("UpdateUserPassword returned error: %v", err)
+	}
+	if user, _ := s.GetUserByID(ctx, walt.ID); user.HashedPassword != "rehashed" {
+		t.Errorf("expected %v, got %v", "rehashed", user.HashedPassword)
+	}
+	if err := s.UpdateUserPassword(ctx, uuid.New(), "hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := s.UpgradeUser(ctx, walt.ID); err != nil {
+		t.Fatalf("UpgradeUser returned error: %v", err)
+	}
+	if user, _ := s.GetUserByID(ctx, walt.ID); !user.IsChirpyRed {
+		t.Errorf("expected user to be upgraded")
+	}
+
+	if err := s.UpgradeUser(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func testUserProfiles(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	walt.Handle = "heisenberg"
+	walt.DisplayName = "Walter White"
+	walt.Bio = "I am the one who knocks"
+	walt.Location = "Albuquerque"
+	updated, err := s.UpdateUser(ctx, walt)
+	if err != nil {
+		t.Fatalf("UpdateUser returned error: %v", err)
+	}
+	if updated.Handle != "heisenberg" || updated.DisplayName != "Walter White" || updated.Email != walt.Email {
+		t.Errorf("expected updated profile, got %+v", updated)
+	}
+
+	found, err := s.GetUserByHandle(ctx, "heisenberg")
+	if err != nil || found.ID != walt.ID {
+		t.Errorf("expected user %v, got %v (err %v)", walt.ID, found.ID, err)
+	}
+	if _, err := s.GetUserByHandle(ctx, "capncook"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// users without a handle do not collide
+	jesse.DisplayName = "Jesse Pinkman"
+	if _, err := s.UpdateUser(ctx, jesse); err != nil {
+		t.Fatalf("UpdateUser returned error: %v", err)
+	}
+
+	jesse.Handle = "heisenberg"
+	if _, err := s.UpdateUser(ctx, jesse); !errors.Is(err, ErrHandleTaken) {
+		t.Errorf("expected %v, got %v", ErrHandleTaken, err)
+	}
+
+	jesse.Handle = ""
+	jesse.Email = walt.Email
+	if _, err := s.UpdateUser(ctx, jesse); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected %v, got %v", ErrEmailTaken, err)
+	}
+}
+
+func testChirps(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	for _, author := range []User{walt, jesse, walt} {
+		if _, err := s.CreateChirp(ctx, Chirp{UserID: author.ID, Body: "chirp of " + author.Email}); err != nil {
+			t.Fatalf("CreateChirp returned error: %v", err)
+		}
+		// distinct timestamps keep the expected order deterministic
+		time.Sleep(time.Millisecond)
+	}
+
+	all, err := s.ListChirps(ctx, uuid.Nil)
+	if err != nil {
+		t.Fatalf("ListChirps returned error: %v", err)
+	}
+	if len(all) != 3 || all[1].UserID != jesse.ID {
+		t.Errorf("expected 3 chirps in creation order, got %+v", all)
+	}
+
+	byWalt, err := s.ListChirpsByUser(ctx, walt.ID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("ListChirpsByUser returned error: %v", err)
+	}
+	if len(byWalt) != 2 {
+		t.Errorf("expected 2 chirps, got %v", len(byWalt))
+	}
+
+	if _, err := s.GetChirp(ctx, uuid.New(), uuid.Nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	// a chirp keeps its reply target and attachments
+	attachments := []string{"https://example.com/a.png", "https://example.com/b.png"}
+	reply, err := s.CreateChirp(ctx, Chirp{UserID: jesse.ID, Body: "yeah science", ReplyToID: all[0].ID, Attachments: attachments})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	if got, err := s.GetChirp(ctx, reply.ID, uuid.Nil); err != nil || got.ReplyToID != all[0].ID || !slices.Equal(got.Attachments, attachments) {
+		t.Errorf("expected the reply target and attachments, got %+v (err %v)", got, err)
+	}
+	if _, err := s.CreateChirp(ctx, Chirp{UserID: jesse.ID, Body: "yeah science", ReplyToID: uuid.New()}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v replying to a missing chirp, got %v", ErrNotFound, err)
+	}
+}
+
+func testDeleteChirp(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	kept, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "I am the one who knocks"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+
+	if err := s.DeleteChirp(ctx, chirp.ID, walt.ID); err != nil {
+		t.Fatalf("DeleteChirp returned error: %v", err)
+	}
+	if err := s.DeleteChirp(ctx, chirp.ID, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v deleting twice, got %v", ErrNotFound, err)
+	}
+
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); !errors.Is(err, ErrDeleted) {
+		t.Errorf("expected %v, got %v", ErrDeleted, err)
+	}
+	if chirps, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(chirps) != 1 || chirps[0].ID != kept.ID {
+		t.Errorf("expected only the kept chirp, got %+v (err %v)", chirps, err)
+	}
+	if chirps, err := s.ListChirpsByUser(ctx, walt.ID, walt.ID); err != nil || len(chirps) != 1 {
+		t.Errorf("expected only the kept chirp of walt, got %+v (err %v)", chirps, err)
+	}
+	if count, err := s.CountChirpsByUser(ctx, walt.ID); err != nil || count != 1 {
+		t.Errorf("expected 1 chirp of walt, got %v (err %v)", count, err)
+	}
+
+	// only the author restores a chirp, and only within the undo window
+	if _, err := s.RestoreChirp(ctx, chirp.ID, jesse.ID, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v restoring another user's chirp, got %v", ErrNotFound, err)
+	}
+	if _, err := s.RestoreChirp(ctx, chirp.ID, walt.ID, -time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v restoring after the undo window, got %v", ErrNotFound, err)
+	}
+	restored, err := s.RestoreChirp(ctx, chirp.ID, walt.ID, time.Minute)
+	if err != nil || restored.DeletedAt != nil || restored.Body != chirp.Body {
+		t.Fatalf("expected the chirp to be restored, got %+v (err %v)", restored, err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); err != nil {
+		t.Errorf("GetChirp returned error: %v", err)
+	}
+
+	// a chirp deleted by a moderator cannot be restored by its author
+	if err := s.DeleteChirp(ctx, chirp.ID, jesse.ID); err != nil {
+		t.Fatalf("DeleteChirp returned error: %v", err)
+	}
+	if _, err := s.RestoreChirp(ctx, chirp.ID, walt.ID, time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v restoring a chirp deleted by another user, got %v", ErrNotFound, err)
+	}
+
+	if purged, err := s.PurgeDeletedChirps(ctx, time.Minute); err != nil || purged != 0 {
+		t.Errorf("expected no chirp deleted before the retention, got %v (err %v)", purged, err)
+	}
+	if purged, err := s.PurgeDeletedChirps(ctx, -time.Minute); err != nil || purged != 1 {
+		t.Errorf("expected 1 purged chirp, got %v (err %v)", purged, err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v after the purge, got %v", ErrNotFound, err)
+	}
+	if _, err := s.GetChirp(ctx, kept.ID, uuid.Nil); err != nil {
+		t.Errorf("expected the kept chirp to survive the purge, got %v", err)
+	}
+}
+
+func testScheduledChirps(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	later, err := s.ScheduleChirp(ctx, walt.ID, "I am the one who knocks", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+	due, err := s.ScheduleChirp(ctx, walt.ID, "say my name", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+
+	scheduled, err := s.ListScheduledChirps(ctx, walt.ID)
+	if err != nil || len(scheduled) != 2 || scheduled[0].ID != due.ID || scheduled[1].ID != later.ID {
+		t.Errorf("expected the due chirp before the later one, got %+v (err %v)", scheduled, err)
+	}
+	if chirps, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(chirps) != 0 {
+		t.Errorf("expected no published chirps, got %+v (err %v)", chirps, err)
+	}
+
+	if err := s.CancelScheduledChirp(ctx, later.ID, jesse.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v canceling another user's chirp, got %v", ErrNotFound, err)
+	}
+
+	// a rolled back claim leaves the chirp scheduled
+	rollback := errors.New("rollback")
+	err = s.InTx(ctx, func(tx Store) error {
+		claimed, err := tx.ClaimDueChirps(ctx, 10)
+		if err != nil || len(claimed) != 1 {
+			t.Errorf("expected 1 claimed chirp, got %+v (err %v)", claimed, err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected %v, got %v", rollback, err)
+	}
+
+	var published Chirp
+	err = s.InTx(ctx, func(tx Store) error {
+		claimed, err := tx.ClaimDueChirps(ctx, 10)
+		if err != nil {
+			return err
+		}
+		if len(claimed) != 1 || claimed[0].ID != due.ID {
+			t.Fatalf("expected the due chirp to be claimed, got %+v", claimed)
+		}
+		published, err = tx.PublishScheduledChirp(ctx, claimed[0])
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTx returned error: %v", err)
+	}
+	if published.ID != due.ID || published.Body != due.Body || published.CreatedAt.Before(due.PublishAt) {
+		t.Errorf("expected the chirp to be published once due, got %+v", published)
+	}
+
+	if chirps, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(chirps) != 1 || chirps[0].ID != due.ID {
+		t.Errorf("expected the published chirp, got %+v (err %v)", chirps, err)
+	}
+	if claimed, err := s.ClaimDueChirps(ctx, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("expected nothing left to claim, got %+v (err %v)", claimed, err)
+	}
+
+	if err := s.CancelScheduledChirp(ctx, due.ID, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v canceling a published chirp, got %v", ErrNotFound, err)
+	}
+	if err := s.CancelScheduledChirp(ctx, later.ID, walt.ID); err != nil {
+		t.Errorf("CancelScheduledChirp returned error: %v", err)
+	}
+	if scheduled, err := s.ListScheduledChirps(ctx, walt.ID); err != nil || len(scheduled) != 0 {
+		t.Errorf("expected no scheduled chirps, got %+v (err %v)", scheduled, err)
+	}
+}
+
+// testConcurrentClaims publishes the due chirps from two publishers at
+// once, like two replicas of the server, each chirp must be published
+// exactly once and neither publisher may fail on the other's claims
+func testConcurrentClaims(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+
+	const total = 30
+	for i := range total {
+		if _, err := s.ScheduleChirp(ctx, walt.ID, fmt.Sprintf("chirp %d", i), time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("ScheduleChirp returned error: %v", err)
+		}
+	}
+
+	publish := func() ([]uuid.UUID, error) {
+		var published []uuid.UUID
+		for {
+			var batch []uuid.UUID
+			err := s.ClaimTx(ctx, func(tx Store) error {
+				batch = nil
+
+				claimed, err := tx.ClaimDueChirps(ctx, 2)
+				if err != nil {
+					return err
+				}
+				for _, scheduled := range claimed {
+					chirp, err := tx.PublishScheduledChirp(ctx, scheduled)
+					if err != nil {
+						return err
+					}
+					batch = append(batch, chirp.ID)
+				}
+				return nil
+			})
+			if err != nil || len(batch) == 0 {
+				return published, err
+			}
+			published = append(published, batch...)
+		}
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]uuid.UUID, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = publish()
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("publisher returned error: %v", err)
+		}
+	}
+
+	published := slices.Concat(results...)
+	slices.SortFunc(published, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	if len(published) != total || len(slices.Compact(published)) != total {
+		t.Errorf("expected %v chirps published once, got %v", total, len(published))
+	}
+	if chirps, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(chirps) != total {
+		t.Errorf("expected %v chirps, got %v (err %v)", total, len(chirps), err)
+	}
+}
+
+func testClaimsSkipSuspendedAuthors(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	suspended, err := s.ScheduleChirp(ctx, walt.ID, "say my name", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+	if _, err := s.ScheduleChirp(ctx, jesse.ID, "yeah science", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+
+	if err := s.SuspendUser(ctx, walt.ID); err != nil {
+		t.Fatalf("SuspendUser returned error: %v", err)
+	}
+	if _, err := s.SoftDeleteUser(ctx, jesse.ID); err != nil {
+		t.Fatalf("SoftDeleteUser returned error: %v", err)
+	}
+	if claimed, err := s.ClaimDueChirps(ctx, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("expected nothing claimed, got %+v (err %v)", claimed, err)
+	}
+
+	// the chirps are still scheduled once the author is reinstated
+	if err := s.UnsuspendUser(ctx, walt.ID); err != nil {
+		t.Fatalf("UnsuspendUser returned error: %v", err)
+	}
+	if claimed, err := s.ClaimDueChirps(ctx, 10); err != nil || len(claimed) != 1 || claimed[0].ID != suspended.ID {
+		t.Errorf("expected the chirp of walt to be claimed, got %+v (err %v)", claimed, err)
+	}
+}
+
+func testDrafts(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	first, err := s.CreateDraft(ctx, Draft{UserID: walt.ID, Body: "say my"})
+	if err != nil {
+		t.Fatalf("CreateDraft returned error: %v", err)
+	}
+	second, err := s.CreateDraft(ctx, Draft{UserID: walt.ID, Body: "I am the one"})
+	if err != nil {
+		t.Fatalf("CreateDraft returned error: %v", err)
+	}
+	if len(first.Attachments) != 0 || first.ReplyToID != uuid.Nil {
+		t.Errorf("expected no reply target nor attachments, got %+v", first)
+	}
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: jesse.ID, Body: "yeah science"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	attachments := []string{"https://example.com/a.png", "https://example.com/b.png"}
+
+	updated, err := s.UpdateDraft(ctx, Draft{ID: first.ID, UserID: walt.ID, Body: "say my name", ReplyToID: chirp.ID, Attachments: attachments})
+	if err != nil || updated.Body != "say my name" || updated.UpdatedAt.Before(first.UpdatedAt) {
+		t.Fatalf("expected the updated draft, got %+v (err %v)", updated, err)
+	}
+	if got, err := s.GetDraft(ctx, first.ID, walt.ID); err != nil || got.ReplyToID != chirp.ID || !slices.Equal(got.Attachments, attachments) {
+		t.Errorf("expected the reply target and attachments, got %+v (err %v)", got, err)
+	}
+	if _, err := s.UpdateDraft(ctx, Draft{ID: first.ID, UserID: walt.ID, ReplyToID: uuid.New()}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v replying to a missing chirp, got %v", ErrNotFound, err)
+	}
+
+	drafts, err := s.ListDrafts(ctx, walt.ID)
+	if err != nil || len(drafts) != 2 || drafts[0].ID != first.ID || drafts[1].ID != second.ID {
+		t.Errorf("expected the last updated draft first, got %+v (err %v)", drafts, err)
+	}
+	if drafts, err := s.ListDrafts(ctx, jesse.ID); err != nil || len(drafts) != 0 {
+		t.Errorf("expected no drafts for jesse, got %+v (err %v)", drafts, err)
+	}
+
+	// the drafts of other users do not exist for them
+	if _, err := s.GetDraft(ctx, first.ID, jesse.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v reading another user's draft, got %v", ErrNotFound, err)
+	}
+	if _, err := s.UpdateDraft(ctx, Draft{ID: first.ID, UserID: jesse.ID, Body: "yeah science"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v updating another user's draft, got %v", ErrNotFound, err)
+	}
+	if err := s.DeleteDraft(ctx, first.ID, jesse.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v deleting another user's draft, got %v", ErrNotFound, err)
+	}
+
+	if err := s.DeleteDraft(ctx, first.ID, walt.ID); err != nil {
+		t.Errorf("DeleteDraft returned error: %v", err)
+	}
+	if _, err := s.GetDraft(ctx, first.ID, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := s.DeleteDraft(ctx, first.ID, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v deleting twice, got %v", ErrNotFound, err)
+	}
+
+	// the published chirp keeps the reply target and attachments
+	published, err := s.PublishDraft(ctx, updated)
+	if err != nil {
+		t.Fatalf("PublishDraft returned error: %v", err)
+	}
+	if published.UserID != walt.ID || published.Body != "say my name" || published.ReplyToID != chirp.ID || !slices.Equal(published.Attachments, attachments) {
+		t.Errorf("unexpected published chirp %+v", published)
+	}
+	if got, err := s.GetChirp(ctx, published.ID, uuid.Nil); err != nil || got.ReplyToID != chirp.ID || !slices.Equal(got.Attachments, attachments) {
+		t.Errorf("expected the reply target and attachments, got %+v (err %v)", got, err)
+	}
+
+	// purging the chirp replied to unlinks the replies and drafts
+	reply, err := s.UpdateDraft(ctx, Draft{ID: second.ID, UserID: walt.ID, Body: second.Body, ReplyToID: chirp.ID})
+	if err != nil {
+		t.Fatalf("UpdateDraft returned error: %v", err)
+	}
+	if err := s.DeleteChirp(ctx, chirp.ID, jesse.ID); err != nil {
+		t.Fatalf("DeleteChirp returned error: %v", err)
+	}
+	if _, err := s.PurgeDeletedChirps(ctx, -time.Minute); err != nil {
+		t.Fatalf("PurgeDeletedChirps returned error: %v", err)
+	}
+	if got, err := s.GetChirp(ctx, published.ID, uuid.Nil); err != nil || got.ReplyToID != uuid.Nil {
+		t.Errorf("expected the reply without its target, got %+v (err %v)", got, err)
+	}
+	if got, err := s.GetDraft(ctx, reply.ID, walt.ID); err != nil || got.ReplyToID != uuid.Nil {
+		t.Errorf("expected the draft without its target, got %+v (err %v)", got, err)
+	}
+}
+
+func testLinkPreviews(t *testing.T, s Store) {
+	ctx := context.Background()
+	const (
+		gray   = "https://graymatter.example/about"
+		pollos = "https://lospollos.example/"
+		broken = "https://broken.example/"
+	)
+
+	if err := s.RequestLinkPreviews(ctx, []string{gray, pollos}); err != nil {
+		t.Fatalf("RequestLinkPreviews returned error: %v", err)
+	}
+	if err := s.RequestLinkPreviews(ctx, []string{pollos, broken}); err != nil {
+		t.Fatalf("RequestLinkPreviews returned error requesting a URL again: %v", err)
+	}
+
+	pending, err := s.ListPendingLinkPreviews(ctx, 10)
+	if err != nil || len(pending) != 3 {
+		t.Fatalf("expected 3 pending previews, got %v (err %v)", pending, err)
+	}
+	if pending, err := s.ListPendingLinkPreviews(ctx, 2); err != nil || len(pending) != 2 {
+		t.Errorf("expected the limit to be honored, got %v (err %v)", pending, err)
+	}
+
+	err = s.SaveLinkPreview(ctx, LinkPreview{
+		URL:         gray,
+		Status:      LinkPreviewReady,
+		Title:       "Gray Matter Technologies",
+		Description: "Crystal clear science",
+		ImageURL:    "https://graymatter.example/logo.png",
+		SiteName:    "Gray Matter",
+	})
+	if err != nil {
+		t.Fatalf("SaveLinkPreview returned error: %v", err)
+	}
+	if err := s.SaveLinkPreview(ctx, LinkPreview{URL: broken, Status: LinkPreviewFailed}); err != nil {
+		t.Fatalf("SaveLinkPreview returned error: %v", err)
+	}
+	if err := s.SaveLinkPreview(ctx, LinkPreview{URL: "https://unknown.example/", Status: LinkPreviewReady}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v saving a URL never requested, got %v", ErrNotFound, err)
+	}
+
+	if pending, err := s.ListPendingLinkPreviews(ctx, 10); err != nil || len(pending) != 1 || pending[0] != pollos {
+		t.Errorf("expected only %v to be pending, got %v (err %v)", pollos, pending, err)
+	}
+
+	// requesting a fetched URL again does not fetch it again
+	if err := s.RequestLinkPreviews(ctx, []string{gray, broken}); err != nil {
+		t.Fatalf("RequestLinkPreviews returned error: %v", err)
+	}
+	if pending, err := s.ListPendingLinkPreviews(ctx, 10); err != nil || len(pending) != 1 {
+		t.Errorf("expected fetched previews to stay fetched, got %v (err %v)", pending, err)
+	}
+
+	// only ready previews are returned
+	previews, err := s.GetLinkPreviews(ctx, []string{gray, pollos, broken})
+	if err != nil || len(previews) != 1 {
+		t.Fatalf("expected only the ready preview, got %+v (err %v)", previews, err)
+	}
+	if got := previews[0]; got.URL != gray || got.Title != "Gray Matter Technologies" || got.SiteName != "Gray Matter" || got.ImageURL != "https://graymatter.example/logo.png" || got.FetchedAt == nil {
+		t.Errorf("unexpected preview %+v", got)
+	}
+	if previews, err := s.GetLinkPreviews(ctx, nil); err != nil || len(previews) != 0 {
+		t.Errorf("expected no previews without URLs, got %+v (err %v)", previews, err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+
+	if _, err := s.CreateRefreshToken(ctx, "valid", walt.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken returned error: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, "expired", walt.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken returned error: %v", err)
+	}
+
+	if user, err := s.GetUserByRefreshToken(ctx, "valid"); err != nil || user.ID != walt.ID {
+		t.Errorf("expected user %v, got %v (err %v)", walt.ID, user.ID, err)
+	}
+
+	if _, err := s.GetUserByRefreshToken(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for an expired token, got %v", ErrNotFound, err)
+	}
+
+	if err := s.RevokeRefreshToken(ctx, "valid"); err != nil {
+		t.Fatalf("RevokeRefreshToken returned error: %v", err)
+	}
+	if _, err := s.GetUserByRefreshToken(ctx, "valid"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a revoked token, got %v", ErrNotFound, err)
+	}
+}
+
+func testDeleteUserCascades(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, "token", walt.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken returned error: %v", err)
+	}
+	if _, err := s.ScheduleChirp(ctx, walt.ID, "I am the danger", time.Now()); err != nil {
+		t.Fatalf("ScheduleChirp returned error: %v", err)
+	}
+	draft, err := s.CreateDraft(ctx, Draft{UserID: walt.ID, Body: "I am the one who"})
+	if err != nil {
+		t.Fatalf("CreateDraft returned error: %v", err)
+	}
+
+	if err := s.DeleteUser(ctx, walt.ID); err != nil {
+		t.Fatalf("DeleteUser returned error: %v", err)
+	}
+
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected chirp to be deleted, got %v", err)
+	}
+	if _, err := s.GetUserByRefreshToken(ctx, "token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected refresh token to be deleted, got %v", err)
+	}
+	if claimed, err := s.ClaimDueChirps(ctx, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("expected scheduled chirp to be deleted, got %+v (err %v)", claimed, err)
+	}
+	if _, err := s.GetDraft(ctx, draft.ID, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected draft to be deleted, got %v", err)
+	}
+
+	// the email is free again
+	mustCreateUser(t, s, "walt@example.com")
+
+	if err := s.DeleteUser(ctx, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func testSoftDeleteUser(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	if _, err := s.CreateChirp(ctx, Chirp{UserID: jesse.ID, Body: "yeah science"}); err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	for _, token := range []string{"first", "second"} {
+		if _, err := s.CreateRefreshToken(ctx, token, walt.ID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("CreateRefreshToken returned error: %v", err)
+		}
+	}
+
+	deleted, err := s.SoftDeleteUser(ctx, walt.ID)
+	if err != nil {
+		t.Fatalf("SoftDeleteUser returned error: %v", err)
+	}
+	if !deleted.Deleted() {
+		t.Errorf("expected the user to be pending deletion")
+	}
+	if _, err := s.SoftDeleteUser(ctx, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v deleting twice, got %v", ErrNotFound, err)
+	}
+
+	// the account is kept, its chirps are hidden
+	if user, err := s.GetUserByID(ctx, walt.ID); err != nil || !user.Deleted() {
+		t.Errorf("expected the user pending deletion, got %+v (err %v)", user, err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if all, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(all) != 1 || all[0].UserID != jesse.ID {
+		t.Errorf("expected only the chirp of jesse, got %+v (err %v)", all, err)
+	}
+	if byWalt, err := s.ListChirpsByUser(ctx, walt.ID, uuid.Nil); err != nil || len(byWalt) != 0 {
+		t.Errorf("expected no chirps, got %+v (err %v)", byWalt, err)
+	}
+	if _, err := s.GetUserByRefreshToken(ctx, "first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if revoked, err := s.RevokeUserRefreshTokens(ctx, walt.ID); err != nil || revoked != 2 {
+		t.Fatalf("expected 2 tokens revoked, got %v (err %v)", revoked, err)
+	}
+	tokens, err := s.ListRefreshTokens(ctx, walt.ID)
+	if err != nil {
+		t.Fatalf("ListRefreshTokens returned error: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %v", len(tokens))
+	}
+	for _, token := range tokens {
+		if token.RevokedAt == nil {
+			t.Errorf("expected token %v to be revoked", token.Token)
+		}
+	}
+
+	if purged, err := s.PurgeDeletedUsers(ctx, time.Minute); err != nil || purged != 0 {
+		t.Errorf("expected no user purged within the grace period, got %v (err %v)", purged, err)
+	}
+	if purged, err := s.PurgeDeletedUsers(ctx, -time.Minute); err != nil || purged != 1 {
+		t.Errorf("expected 1 user purged, got %v (err %v)", purged, err)
+	}
+
+	if _, err := s.GetUserByID(ctx, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if tokens, err := s.ListRefreshTokens(ctx, walt.ID); err != nil || len(tokens) != 0 {
+		t.Errorf("expected the tokens to be deleted, got %+v (err %v)", tokens, err)
+	}
+	if _, err := s.GetUserByID(ctx, jesse.ID); err != nil {
+		t.Errorf("expected jesse to be kept, got %v", err)
+	}
+}
+
+func testRestoreUser(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+
+	if _, err := s.RestoreUser(ctx, walt.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v restoring an active account, got %v", ErrNotFound, err)
+	}
+
+	if _, err := s.SoftDeleteUser(ctx, walt.ID); err != nil {
+		t.Fatalf("SoftDeleteUser returned error: %v", err)
+	}
+	restored, err := s.RestoreUser(ctx, walt.ID)
+	if err != nil {
+		t.Fatalf("RestoreUser returned error: %v", err)
+	}
+	if restored.Deleted() {
+		t.Errorf("expected the user not to be pending deletion, got %+v", restored)
+	}
+
+	// the chirps hidden by the deletion are listed again
+	if _, err := s.GetChirp(ctx, chirp.ID, uuid.Nil); err != nil {
+		t.Errorf("expected the chirp to be visible, got %v", err)
+	}
+	if byWalt, err := s.ListChirpsByUser(ctx, walt.ID, uuid.Nil); err != nil || len(byWalt) != 1 {
+		t.Errorf("expected 1 chirp, got %+v (err %v)", byWalt, err)
+	}
+	if purged, err := s.PurgeDeletedUsers(ctx, -time.Minute); err != nil || purged != 0 {
+		t.Errorf("expected the restored user not to be purged, got %v (err %v)", purged, err)
+	}
+}
+
+func testBlocksAndMutes(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+	hank := mustCreateUser(t, s, "hank@example.com")
+
+	chirps := map[uuid.UUID]Chirp{}
+	for _, author := range []User{walt, jesse, hank} {
+		chirp, err := s.CreateChirp(ctx, Chirp{UserID: author.ID, Body: "chirp of " + author.Email})
+		if err != nil {
+			t.Fatalf("CreateChirp returned error: %v", err)
+		}
+		chirps[author.ID] = chirp
+	}
+
+	// walt blocks hank and mutes jesse, twice is not an error
+	for range 2 {
+		if err := s.BlockUser(ctx, walt.ID, hank.ID); err != nil {
+			t.Fatalf("BlockUser returned error: %v", err)
+		}
+		if err := s.MuteUser(ctx, walt.ID, jesse.ID); err != nil {
+			t.Fatalf("MuteUser returned error: %v", err)
+		}
+	}
+	if err := s.BlockUser(ctx, walt.ID, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v blocking an unknown user, got %v", ErrNotFound, err)
+	}
+
+	if blocked, err := s.ListBlockedUsers(ctx, walt.ID); err != nil || len(blocked) != 1 || blocked[0].ID != hank.ID {
+		t.Errorf("expected hank to be blocked, got %+v (err %v)", blocked, err)
+	}
+	if muted, err := s.ListMutedUsers(ctx, walt.ID); err != nil || len(muted) != 1 || muted[0].ID != jesse.ID {
+		t.Errorf("expected jesse to be muted, got %+v (err %v)", muted, err)
+	}
+
+	// the timeline of walt hides both, hank cannot see walt at all
+	if timeline, err := s.ListChirps(ctx, walt.ID); err != nil || len(timeline) != 1 || timeline[0].UserID != walt.ID {
+		t.Errorf("expected only the chirp of walt, got %+v (err %v)", timeline, err)
+	}
+	if timeline, err := s.ListChirps(ctx, hank.ID); err != nil || len(timeline) != 2 {
+		t.Errorf("expected the chirps of jesse and hank, got %+v (err %v)", timeline, err)
+	}
+	if _, err := s.GetChirp(ctx, chirps[walt.ID].ID, hank.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if byWalt, err := s.ListChirpsByUser(ctx, walt.ID, hank.ID); err != nil || len(byWalt) != 0 {
+		t.Errorf("expected no chirps, got %+v (err %v)", byWalt, err)
+	}
+
+	// a mute only hides the timeline
+	if _, err := s.GetChirp(ctx, chirps[jesse.ID].ID, walt.ID); err != nil {
+		t.Errorf("GetChirp returned error: %v", err)
+	}
+	if byJesse, err := s.ListChirpsByUser(ctx, jesse.ID, walt.ID); err != nil || len(byJesse) != 1 {
+		t.Errorf("expected the chirp of jesse, got %+v (err %v)", byJesse, err)
+	}
+
+	if all, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(all) != 3 {
+		t.Errorf("expected anonymous readers to see 3 chirps, got %+v (err %v)", all, err)
+	}
+
+	if err := s.UnblockUser(ctx, walt.ID, hank.ID); err != nil {
+		t.Fatalf("UnblockUser returned error: %v", err)
+	}
+	if err := s.UnmuteUser(ctx, walt.ID, jesse.ID); err != nil {
+		t.Fatalf("UnmuteUser returned error: %v", err)
+	}
+	if err := s.UnmuteUser(ctx, walt.ID, jesse.ID); err != nil {
+		t.Errorf("expected unmuting twice not to fail, got %v", err)
+	}
+	if timeline, err := s.ListChirps(ctx, walt.ID); err != nil || len(timeline) != 3 {
+		t.Errorf("expected 3 chirps, got %+v (err %v)", timeline, err)
+	}
+}
+
+func testModeration(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+	hank := mustCreateUser(t, s, "hank@example.com")
+
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: "say my name"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+
+	report := func(reporter User) (Report, error) {
+		return s.CreateReport(ctx, Report{
+			ChirpID:    chirp.ID,
+			AuthorID:   walt.ID,
+			ReporterID: reporter.ID,
+			ChirpBody:  chirp.Body,
+			Reason:     "harassment",
+		})
+	}
+
+	first, err := report(jesse)
+	if err != nil {
+		t.Fatalf("CreateReport returned error: %v", err)
+	}
+	if first.Status != ReportOpen || first.ChirpBody != chirp.Body {
+		t.Errorf("expected an open report of the chirp, got %+v", first)
+	}
+	if _, err := report(jesse); !errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("expected %v, got %v", ErrAlreadyReported, err)
+	}
+	if _, err := report(hank); err != nil {
+		t.Fatalf("CreateReport returned error: %v", err)
+	}
+
+	if count, err := s.CountOpenReports(ctx, chirp.ID); err != nil || count != 2 {
+		t.Errorf("expected 2 open reports, got %v (err %v)", count, err)
+	}
+
+	// hidden chirps are only shown to their author
+	for range 2 {
+		if err := s.HideChirp(ctx, chirp.ID); err != nil {
+			t.Fatalf("HideChirp returned error: %v", err)
+		}
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID, jesse.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if all, err := s.ListChirps(ctx, uuid.Nil); err != nil || len(all) != 0 {
+		t.Errorf("expected no chirps, got %+v (err %v)", all, err)
+	}
+	if own, err := s.GetChirp(ctx, chirp.ID, walt.ID); err != nil || own.HiddenAt == nil {
+		t.Errorf("expected the author to see the hidden chirp, got %+v (err %v)", own, err)
+	}
+
+	// resolving a report resolves every open report of the chirp
+	if err := s.ResolveReports(ctx, first.ID, ReportActioned); err != nil {
+		t.Fatalf("ResolveReports returned error: %v", err)
+	}
+	if err := s.ResolveReports(ctx, first.ID, ReportDismissed); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v resolving twice, got %v", ErrNotFound, err)
+	}
+	if open, err := s.ListReports(ctx, ReportOpen); err != nil || len(open) != 0 {
+		t.Errorf("expected no open reports, got %+v (err %v)", open, err)
+	}
+	actioned, err := s.ListReports(ctx, ReportActioned)
+	if err != nil || len(actioned) != 2 || actioned[0].ID != first.ID || actioned[0].ResolvedAt == nil {
+		t.Errorf("expected 2 actioned reports, got %+v (err %v)", actioned, err)
+	}
+
+	// deleting the chirp keeps its reports, purging it unlinks them
+	if err := s.DeleteChirp(ctx, chirp.ID, walt.ID); err != nil {
+		t.Fatalf("DeleteChirp returned error: %v", err)
+	}
+	if got, err := s.GetReport(ctx, first.ID); err != nil || got.ChirpID != chirp.ID {
+		t.Errorf("expected the report of the deleted chirp, got %+v (err %v)", got, err)
+	}
+	if _, err := s.PurgeDeletedChirps(ctx, -time.Minute); err != nil {
+		t.Fatalf("PurgeDeletedChirps returned error: %v", err)
+	}
+	if got, err := s.GetReport(ctx, first.ID); err != nil || got.ChirpID != uuid.Nil || got.ChirpBody != chirp.Body {
+		t.Errorf("expected the report without its chirp, got %+v (err %v)", got, err)
+	}
+
+	if err := s.SuspendUser(ctx, walt.ID); err != nil {
+		t.Fatalf("SuspendUser returned error: %v", err)
+	}
+	if user, err := s.GetUserByID(ctx, walt.ID); err != nil || !user.Suspended() {
+		t.Errorf("expected walt to be suspended, got %+v (err %v)", user, err)
+	}
+
+	recorded, err := s.RecordModerationAction(ctx, ModerationAction{
+		ModeratorID: hank.ID,
+		Action:      "suspend_author",
+		ReportID:    first.ID,
+		UserID:      walt.ID,
+		Note:        "repeat offender",
+	})
+	if err != nil {
+		t.Fatalf("RecordModerationAction returned error: %v", err)
+	}
+	actions, err := s.ListModerationActions(ctx)
+	if err != nil || len(actions) == 0 || actions[0].ID != recorded.ID {
+		t.Fatalf("expected the newest action first, got %+v (err %v)", actions, err)
+	}
+	if actions[0].ChirpID != uuid.Nil || actions[0].ModeratorID != hank.ID || actions[0].Note != "repeat offender" {
+		t.Errorf("expected the recorded action, got %+v", actions[0])
+	}
+
+	// the audit log outlives the users it is about
+	if err := s.DeleteUser(ctx, walt.ID); err != nil {
+		t.Fatalf("DeleteUser returned error: %v", err)
+	}
+	if _, err := s.GetReport(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the reports to be deleted with the author, got %v", err)
+	}
+	if actions, err := s.ListModerationActions(ctx); err != nil || len(actions) == 0 || actions[0].ID != recorded.ID {
+		t.Errorf("expected the action to be kept, got %+v (err %v)", actions, err)
+	}
+}
+
+func testAdmin(t *testing.T, s Store) {
+	ctx := context.Background()
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	if err := s.SuspendUser(ctx, walt.ID); err != nil {
+		t.Fatalf("SuspendUser returned error: %v", err)
+	}
+	if err := s.UpgradeUser(ctx, jesse.ID); err != nil {
+		t.Fatalf("UpgradeUser returned error: %v", err)
+	}
+	if err := s.SetModerator(ctx, jesse.ID, true); err != nil {
+		t.Fatalf("SetModerator returned error: %v", err)
+	}
+	if user, err := s.GetUserByID(ctx, jesse.ID); err != nil || !user.IsModerator {
+		t.Errorf("expected jesse to be a moderator, got %+v (err %v)", user, err)
+	}
+	if err := s.SetModerator(ctx, uuid.New(), true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	for _, body := range []string{"first", "second"} {
+		if _, err := s.CreateChirp(ctx, Chirp{UserID: walt.ID, Body: body}); err != nil {
+			t.Fatalf("CreateChirp returned error: %v", err)
+		}
+	}
+	chirp, err := s.CreateChirp(ctx, Chirp{UserID: jesse.ID, Body: "third"})
+	if err != nil {
+		t.Fatalf("CreateChirp returned error: %v", err)
+	}
+	if err := s.DeleteChirp(ctx, chirp.ID, jesse.ID); err != nil {
+		t.Fatalf("DeleteChirp returned error: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, "active", walt.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken returned error: %v", err)
+	}
+	if _, err := s.CreateRefreshToken(ctx, "expired", walt.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("CreateRefreshToken returned error: %v", err)
+	}
+
+	stats, err := s.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats returned error: %v", err)
+	}
+	expected := Stats{Users: 2, ChirpyRedUsers: 1, SuspendedUsers: 1, Chirps: 2, DeletedChirps: 1, ActiveRefreshTokens: 1}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	if err := s.UnsuspendUser(ctx, walt.ID); err != nil {
+		t.Fatalf("UnsuspendUser returned error: %v", err)
+	}
+	if user, err := s.GetUserByID(ctx, walt.ID); err != nil || user.Suspended() {
+		t.Errorf("expected walt not to be suspended, got %+v (err %v)", user, err)
+	}
+
+	if deleted, err := s.DeleteUserChirps(ctx, walt.ID); err != nil || deleted != 2 {
+		t.Errorf("expected 2 chirps deleted, got %v (err %v)", deleted, err)
+	}
+	if deleted, err := s.DeleteAllChirps(ctx); err != nil || deleted != 1 {
+		t.Errorf("expected the deleted chirp to be purged, got %v (err %v)", deleted, err)
+	}
+
+	first, err := s.RotateSigningKey(ctx, "first", "first-secret")
+	if err != nil {
+		t.Fatalf("RotateSigningKey returned error: %v", err)
+	}
+	if _, err := s.RotateSigningKey(ctx, "second", "second-secret"); err != nil {
+		t.Fatalf("RotateSigningKey returned error: %v", err)
+	}
+	keys, err := s.ListSigningKeys(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("ListSigningKeys returned error: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "second" || keys[0].RetiredAt != nil || keys[1].ID != first.ID || keys[1].RetiredAt == nil {
+		t.Fatalf("expected second active and first retired, got %+v", keys)
+	}
+	if keys[1].PastLifetime {
+		t.Errorf("expected first to be within its lifetime, got %+v", keys[1])
+	}
+	if keys, err := s.ListSigningKeys(ctx, 0); err != nil || len(keys) != 1 || keys[0].ID != "second" || !keys[0].PastLifetime {
+		t.Errorf("expected only second, past a zero lifetime, got %+v (err %v)", keys, err)
+	}
+}
+
+func testInTx(t *testing.T, s Store) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := s.InTx(ctx, func(tx Store) error {
+		if _, err := tx.CreateUser(ctx, "walt@example.com", "hash"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected %v, got %v", errAbort, err)
+	}
+
+	if _, err := s.GetUserByEmail(ctx, "walt@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the aborted transaction to be rolled back, got %v", err)
+	}
+
+	err = s.InTx(ctx, func(tx Store) error {
+		user, err := tx.CreateUser(ctx, "walt@example.com", "hash")
+		if err != nil {
+			return err
+		}
+
+		// a nested transaction joins the current one
+		return tx.InTx(ctx, func(tx Store) error {
+			_, err := tx.CreateChirp(ctx, Chirp{UserID: user.ID, Body: "say my name"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx returned error: %v", err)
+	}
+
+	user, err := s.GetUserByEmail(ctx, "walt@example.com")
+	if err != nil {
+		t.Fatalf("expected the transaction to be committed, got %v", err)
+	}
+	if chirps, _ := s.ListChirpsByUser(ctx, user.ID, uuid.Nil); len(chirps) != 1 {
+		t.Errorf("expected 1 chirp, got %v", len(chirps))
+	}
+}
+
+// TestSQLiteBlockEndsFollows checks the triggers that keep blocked
+// users from following the blocker
+func TestSQLiteBlockEndsFollows(t *testing.T) {
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(filepath.Join(t.TempDir(), "chirpy.db")))
+	if err != nil {
+		t.Fatalf("error opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	if err := migrate.Up(ctx, db, config.BackendSQLite); err != nil {
+		t.Fatalf("error migrating SQLite database: %v", err)
+	}
+
+	s := NewSQLite(db)
+	walt := mustCreateUser(t, s, "walt@example.com")
+	jesse := mustCreateUser(t, s, "jesse@example.com")
+
+	follow := func(follower, followee User) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO follows(follower_id, followee_id, created_at) VALUES (?, ?, ?)", follower.ID, followee.ID, now())
+		return err
+	}
+	if err := follow(jesse, walt); err != nil {
+		t.Fatalf("error following: %v", err)
+	}
+
+	if err := s.BlockUser(ctx, walt.ID, jesse.ID); err != nil {
+		t.Fatalf("BlockUser returned error: %v", err)
+	}
+
+	var follows int
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM follows").Scan(&follows); err != nil {
+		t.Fatalf("error counting follows: %v", err)
+	}
+	if follows != 0 {
+		t.Errorf("expected the block to end the follow, got %v follows", follows)
+	}
+
+	if err := follow(jesse, walt); err == nil {
+		t.Errorf("expected the blocked user not to be able to follow the blocker")
+	}
+	if err := follow(walt, jesse); err != nil {
+		t.Errorf("expected the blocker to be able to follow, got %v", err)
+	}
+}
+
+func TestSQLiteModerationActionsImmutable(t *testing.T) {
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(filepath.Join(t.TempDir(), "chirpy.db")))
+	if err != nil {
+		t.Fatalf("error opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	if err := migrate.Up(ctx, db, config.BackendSQLite); err != nil {
+		t.Fatalf("error migrating SQLite database: %v", err)
+	}
+
+	s := NewSQLite(db)
+	action, err := s.RecordModerationAction(ctx, ModerationAction{Action: "auto_hide", ChirpID: uuid.New()})
+	if err != nil {
+		t.Fatalf("RecordModerationAction returned error: %v", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE moderation_actions SET note = 'edited' WHERE id = ?", action.ID); err == nil {
+		t.Errorf("expected updating the audit log to fail")
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM moderation_actions WHERE id = ?", action.ID); err == nil {
+		t.Errorf("expected deleting from the audit log to fail")
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// maxTxAttempts bounds how many times Tx runs a transaction that keeps
+// conflicting with concurrent ones
+const maxTxAttempts = 5
+
+// Postgres error codes of transactions aborted by a concurrent one
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// Tx runs fn in a serializable transaction of db, committed when fn
+// succeeds and rolled back otherwise.
+//
+// A transaction aborted because it conflicted with a concurrent one is
+// retried from the start with a growing backoff, so fn must not have
+// side effects outside of tx. ctx cancels both the transaction and the
+// wait between attempts.
+func Tx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return retryTx(ctx, db, sql.LevelSerializable, fn)
+}
+
+// ReadCommittedTx runs fn like Tx at READ COMMITTED, for transactions
+// that claim rows with FOR UPDATE SKIP LOCKED. Serializable claims of
+// disjoint rows still conflict on the rows they read, and all but one
+// of them would be aborted and retried.
+func ReadCommittedTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return retryTx(ctx, db, sql.LevelReadCommitted, fn)
+}
+
+func retryTx(ctx context.Context, db *sql.DB, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	backoff := 10 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, isolation, fn)
+		if err == nil || !retryable(err) || attempt >= maxTxAttempts {
+			return err
+		}
+
+		slog.WarnContext(ctx, "transaction conflicted, retrying", "attempt", attempt, "backoff", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryable reports whether err aborted a transaction only because of
+// a concurrent one, so running it again can succeed
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: &pq.Error{Code: serializationFailure}, expected: true},
+		{err: fmt.Errorf("error storing token: %w", &pq.Error{Code: deadlockDetected}), expected: true},
+		{err: &pq.Error{Code: uniqueViolation}, expected: false},
+		{err: ErrNotFound, expected: false},
+		{err: errors.New("connection refused"), expected: false},
+	}
+
+	for _, c := range cases {
+		if actual := retryable(c.err); actual != c.expected {
+			t.Errorf("retryable(%v): expected %v, got %v", c.err, c.expected, actual)
+		}
+	}
+}
+
+func TestPgError(t *testing.T) {
+	other := &pq.Error{Code: uniqueViolation, Constraint: "signing_keys_pkey"}
+
+	cases := []struct {
+		err      error
+		expected error
+	}{
+		{err: &pq.Error{Code: uniqueViolation, Constraint: emailConstraint}, expected: ErrEmailTaken},
+		{err: &pq.Error{Code: uniqueViolation, Constraint: handleConstraint}, expected: ErrHandleTaken},
+		{err: &pq.Error{Code: uniqueViolation, Constraint: reportConstraint}, expected: ErrAlreadyReported},
+		{err: other, expected: other},
+	}
+
+	for _, c := range cases {
+		if actual := pgError(c.err); !errors.Is(actual, c.expected) {
+			t.Errorf("pgError(%v): expected %v, got %v", c.err, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/luis-octavius/chirpy/internal/database"
+)
+
+// Database systems of the spans of WrapDB
+var (
+	SystemPostgreSQL = semconv.DBSystemNamePostgreSQL
+	SystemSQLite     = semconv.DBSystemNameSQLite
+)
+
+// DB wraps a database.DBTX and records a client span for every query
+// issued through it, named after the sqlc query
+type DB struct {
+	db     database.DBTX
+	system attribute.KeyValue
+}
+
+// WrapDB returns db instrumented with tracing, system is the
+// SystemPostgreSQL or SystemSQLite database behind it
+func WrapDB(db database.DBTX, system attribute.KeyValue) *DB {
+	return &DB{db: db, system: system}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := d.startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+
+	return result, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := d.startQuerySpan(ctx, query)
+	defer span.End()
+
+	stmt, err := d.db.PrepareContext(ctx, query)
+	recordError(span, err)
+
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := d.startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := d.startQuerySpan(ctx, query)
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+
+	return row
+}
+
+func (d *DB) startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := QueryName(query)
+
+	return Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			d.system,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil || err == sql.ErrNoRows {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// QueryName extracts the query name from the "-- name: X :kind" header
+// sqlc puts at the top of every generated query, falling back to "query"
+// for hand written SQL
+func QueryName(query string) string {
+	header, _, _ := strings.Cut(query, "\n")
+
+	fields := strings.Fields(header)
+	if len(fields) < 3 || fields[0] != "--" || fields[1] != "name:" {
+		return "query"
+	}
+
+	return fields[2]
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "-- name: GetChirpByID :one\nSELECT id FROM chirps WHERE id = $1\n",
+			expected: "GetChirpByID",
+		},
+		{
+			input:    "-- name: DeleteAllUsers :exec\nDELETE FROM users\n",
+			expected: "DeleteAllUsers",
+		},
+		{
+			input:    "SELECT 1",
+			expected: "query",
+		},
+		{
+			input:    "",
+			expected: "query",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := QueryName(c.input); actual != c.expected {
+			t.Errorf("expected %v, got %v", c.expected, actual)
+		}
+	}
+}
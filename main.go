@@ -1,25 +1,48 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
-	"github.com/luis-octavius/chirpy/internal/database"
+	"github.com/luis-octavius/chirpy/internal/auth"
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/health"
+	"github.com/luis-octavius/chirpy/internal/linkpreview"
+	"github.com/luis-octavius/chirpy/internal/logging"
+	"github.com/luis-octavius/chirpy/internal/metrics"
+	"github.com/luis-octavius/chirpy/internal/migrate"
+	"github.com/luis-octavius/chirpy/internal/password"
+	"github.com/luis-octavius/chirpy/internal/store"
+	"github.com/luis-octavius/chirpy/internal/tracing"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	queries        *database.Queries
-	platform       string
-	secret         string
-	apiKey         string
+	db        *sql.DB
+	store     store.Store
+	keys      *auth.Keyring
+	hasher    *auth.Hasher
+	passwords password.Policy
+	metrics   *metrics.Metrics
+	health    *health.Registry
+	config    config.Config
+	exports   exportJobs
+
+	// previews fetches the previews of the URLs of chirps, nil when
+	// they are disabled, new chirps wake the fetcher up through
+	// previewsRequested
+	previews          linkpreview.Fetcher
+	previewsRequested chan struct{}
 }
 
 type User struct {
@@ -30,6 +53,23 @@ type User struct {
 	Token        string    `json:"token,omitempty"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Handle       string    `json:"handle"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatar_url"`
+	Location     string    `json:"location"`
+}
+
+// Profile is the public view of a user, it never holds the email
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Location    string    `json:"location"`
 }
 
 type Chirp struct {
@@ -38,64 +78,291 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	// HiddenAt is only shown to the author of a chirp hidden by
+	// moderation, nobody else can read it
+	HiddenAt *time.Time `json:"hidden_at,omitempty"`
+	// ReplyToID is the chirp this one replies to, left out once that
+	// chirp is purged
+	ReplyToID   *uuid.UUID `json:"reply_to_id,omitempty"`
+	Attachments []string   `json:"attachments,omitempty"`
+	// Previews are the cards of the URLs of the chirp that were
+	// fetched, URLs without one are left out
+	Previews []LinkPreview `json:"previews,omitempty"`
+}
+
+// newUser returns the public fields of a stored user
+func newUser(u store.User) User {
+	return User{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		IsChirpyRed: u.IsChirpyRed,
+		Handle:      u.Handle,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		Location:    u.Location,
+	}
+}
+
+func newProfile(u store.User) Profile {
+	return Profile{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		IsChirpyRed: u.IsChirpyRed,
+		Handle:      u.Handle,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		Location:    u.Location,
+	}
+}
+
+func newChirp(c store.Chirp) Chirp {
+	return Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		HiddenAt:  c.HiddenAt,
+
+		ReplyToID:   optionalID(c.ReplyToID),
+		Attachments: c.Attachments,
+	}
+}
+
+func newChirps(chirps []store.Chirp) []Chirp {
+	result := make([]Chirp, 0, len(chirps))
+	for _, c := range chirps {
+		result = append(result, newChirp(c))
+	}
+	return result
 }
 
 func main() {
-	godotenv.Load()
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("chirpy stopped with error", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches to the subcommand named by the first argument,
+// starting the server when there is none
+func run(args []string) error {
+	// configuration comes from the environment, .env, secret files and CONFIG_FILE
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
 
-	// get the url of database from .env
-	dbUrl := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
+	// every log line is structured JSON, redacted and tagged with the request ID
+	logger := logging.New(os.Stdout, cfg.LogLevel)
+	slog.SetDefault(logger)
 
-	// open the connection with the database url
-	db, err := sql.Open("postgres", dbUrl)
+	// SIGINT and SIGTERM start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// initialize the holding of all queries made with sqlc
-	dbQueries := database.New(db)
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			return runMigrate(ctx, cfg, args[1:])
+		case "admin":
+			return runAdmin(ctx, cfg, args[1:], os.Stdout)
+		}
+	}
 
-	mux := http.NewServeMux()
+	return runServer(ctx, cfg, args)
+}
+
+// runServer starts the HTTP API and blocks until ctx is canceled
+func runServer(ctx context.Context, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	migrateOnStart := flags.Bool("migrate-on-start", cfg.MigrateOnStart, "apply pending migrations before serving")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// spans are exported to OTLP, stderr or nowhere based on OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TraceExporter, "chirpy")
+	if err != nil {
+		return fmt.Errorf("error setting up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// open the connection with the Postgres or SQLite database
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *migrateOnStart {
+		if err := migrate.Up(ctx, db, cfg.DatabaseBackend); err != nil {
+			return fmt.Errorf("error migrating database: %w", err)
+		}
+	}
 
+	// handlers go through the store of the configured backend
 	var apiCfg apiConfig
-	apiCfg.queries = dbQueries
-	apiCfg.platform = platform
-	apiCfg.secret = os.Getenv("SECRET")
-	apiCfg.apiKey = os.Getenv("POLKA_KEY")
+	apiCfg.db = db
+	apiCfg.store = newStore(cfg.DatabaseBackend, db)
+	apiCfg.metrics = metrics.New(db)
+	apiCfg.config = cfg
+
+	apiCfg.hasher = newHasher(cfg.Password)
+	apiCfg.passwords, err = newPasswordPolicy(cfg.Password)
+	if err != nil {
+		return err
+	}
+
+	// JWTs are signed with the newest key in signing_keys, or with
+	// SECRET until a key has been rotated in with `chirpy admin keys rotate`
+	apiCfg.keys = auth.NewKeyring(cfg.JWTSecret)
+	if err := apiCfg.loadSigningKeys(ctx); err != nil {
+		return err
+	}
+	apiCfg.keys.OnUnknownKey(apiCfg.reloadSigningKeys, signingKeyReloadInterval)
+	go apiCfg.refreshSigningKeys(ctx, signingKeyRefreshInterval)
+
+	// accounts deleted by their users are purged after the grace period
+	go apiCfg.purgeDeletedUsers(ctx, cfg.Accounts.PurgeInterval)
+
+	// deleted chirps are purged after the retention period
+	go apiCfg.purgeDeletedChirps(ctx, cfg.Chirps.PurgeInterval)
+
+	// scheduled chirps are published once due, by whichever replica
+	// claims them first
+	go apiCfg.publishScheduledChirps(ctx, cfg.Chirps.SchedulerInterval)
+
+	// link previews are fetched in the background, chirps are shown
+	// without them until they are
+	apiCfg.previews = newLinkPreviewFetcher(cfg.LinkPreviews)
+	if apiCfg.previews != nil {
+		apiCfg.previewsRequested = make(chan struct{}, 1)
+		go apiCfg.fetchLinkPreviews(ctx, cfg.LinkPreviews.Interval)
+	}
+
+	// readiness depends on every checker registered here
+	apiCfg.health = health.NewRegistry(cfg.HealthCheckTimeout)
+	apiCfg.health.Register(health.Database(db))
+	apiCfg.health.Register(health.Migrations(db, migrate.Latest(cfg.DatabaseBackend)))
 
 	// server config
-	server := http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	server := newServer(cfg.HTTP, apiCfg.routes())
+
+	// serve blocks until a shutdown signal arrives and in-flight requests drain
+	return serve(ctx, server, cfg.HTTP.ShutdownTimeout)
+}
+
+// newPasswordPolicy returns the policy of new passwords, checking
+// breached passwords only when a file of their hashes is configured
+func newPasswordPolicy(cfg config.Password) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength: cfg.MinLength,
+		MaxLength: cfg.MaxLength,
+	}
+
+	if cfg.BreachFile != "" {
+		breached, err := password.LoadOffline(cfg.BreachFile)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.Breached = breached
 	}
 
-	appHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("."))))
+	return policy, nil
+}
+
+// newHasher returns the hasher of passwords with the configured
+// Argon2id parameters, Config.Validate keeps them within the ranges of
+// their argon2id types
+func newHasher(cfg config.Password) *auth.Hasher {
+	params := *argon2id.DefaultParams
+	params.Memory = uint32(cfg.Argon2Memory)
+	params.Iterations = uint32(cfg.Argon2Iterations)
+	params.Parallelism = uint8(cfg.Argon2Parallelism)
+
+	return auth.NewHasher(params, cfg.HashWorkers)
+}
+
+// routes returns the handler of every endpoint wrapped in the request
+// middlewares
+func (cfg *apiConfig) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
 
 	// endpoints
 	mux.Handle("/app/", appHandler)
 	mux.Handle("GET /api/healthz", handlerHealthz())
+	mux.Handle("GET /api/healthz/live", handlerHealthz())
+	mux.Handle("GET /api/healthz/ready", cfg.handlerReadiness())
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+	mux.Handle("GET /api/config", cfg.handlerGetConfig())
 
-	// admin endpoints
-	mux.Handle("GET /admin/metrics", apiCfg.handlerMetrics())
-	mux.Handle("POST /admin/reset", apiCfg.handlerReset())
+	// admin endpoints, only on the dev platform and with ADMIN_TOKEN
+	mux.Handle("POST /admin/reset", cfg.middlewareAdmin(cfg.handlerReset()))
+	mux.Handle("POST /admin/seed", cfg.middlewareAdmin(cfg.handlerSeed()))
+
+	// moderation endpoints, for the users granted the moderator role
+	mux.Handle("GET /admin/reports", cfg.handlerListReports())
+	mux.Handle("POST /admin/reports/{reportID}/actions", cfg.handlerModerateReport())
+	mux.Handle("GET /admin/audit-log", cfg.handlerListModerationActions())
 
 	// users endpoints
-	mux.Handle("POST /api/users", apiCfg.handlerCreateUser())
-	mux.Handle("POST /api/login", apiCfg.handlerUserLogin())
-	mux.Handle("PUT /api/users", apiCfg.handlerUpdateUser())
-	mux.Handle("POST /api/polka/webhooks", apiCfg.handlerUpgradeUser())
+	mux.Handle("POST /api/users", cfg.handlerCreateUser())
+	mux.Handle("POST /api/login", cfg.handlerUserLogin())
+	mux.Handle("PUT /api/users", cfg.handlerUpdateUser())
+	mux.Handle("PATCH /api/users/me", cfg.handlerPatchUser())
+	mux.Handle("DELETE /api/users/me", cfg.handlerDeleteUser())
+	mux.Handle("POST /api/users/restore", cfg.handlerRestoreUser())
+	mux.Handle("GET /api/users/me/export", cfg.handlerExportUser())
+	mux.Handle("GET /api/users/me/export/{exportID}", cfg.handlerGetExport())
+	mux.Handle("GET /api/users/me/blocks", cfg.handlerListRelations(cfg.store.ListBlockedUsers))
+	mux.Handle("PUT /api/users/me/blocks/{userID}", cfg.handlerAddRelation(cfg.store.BlockUser))
+	mux.Handle("DELETE /api/users/me/blocks/{userID}", cfg.handlerRemoveRelation(cfg.store.UnblockUser))
+	mux.Handle("GET /api/users/me/mutes", cfg.handlerListRelations(cfg.store.ListMutedUsers))
+	mux.Handle("PUT /api/users/me/mutes/{userID}", cfg.handlerAddRelation(cfg.store.MuteUser))
+	mux.Handle("DELETE /api/users/me/mutes/{userID}", cfg.handlerRemoveRelation(cfg.store.UnmuteUser))
+	mux.Handle("GET /api/users/me/scheduled-chirps", cfg.handlerListScheduledChirps())
+	mux.Handle("DELETE /api/users/me/scheduled-chirps/{chirpID}", cfg.handlerCancelScheduledChirp())
+	mux.Handle("GET /api/users/me/drafts", cfg.handlerListDrafts())
+	mux.Handle("POST /api/users/me/drafts", cfg.handlerCreateDraft())
+	mux.Handle("GET /api/users/me/drafts/{draftID}", cfg.handlerGetDraft())
+	mux.Handle("PUT /api/users/me/drafts/{draftID}", cfg.handlerUpdateDraft())
+	mux.Handle("DELETE /api/users/me/drafts/{draftID}", cfg.handlerDeleteDraft())
+	mux.Handle("POST /api/users/me/drafts/{draftID}/publish", cfg.handlerPublishDraft())
+	mux.Handle("GET /api/users/{handleOrID}", cfg.handlerGetProfile())
+	mux.Handle("POST /api/polka/webhooks", cfg.handlerUpgradeUser())
 
 	// chirps endpoints
-	mux.Handle("GET /api/chirps", apiCfg.handlerGetAllChirps())
-	mux.Handle("POST /api/chirps", apiCfg.handlerAddChirps())
-	mux.Handle("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp())
-	mux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp())
+	mux.Handle("GET /api/chirps", cfg.handlerGetAllChirps())
+	mux.Handle("POST /api/chirps", cfg.handlerAddChirps())
+	mux.Handle("GET /api/chirps/{chirpID}", cfg.handlerGetChirp())
+	mux.Handle("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp())
+	mux.Handle("POST /api/chirps/{chirpID}/restore", cfg.handlerRestoreChirp())
+	mux.Handle("POST /api/chirps/{chirpID}/reports", cfg.handlerReportChirp())
 
 	// token endpoints
-	mux.Handle("POST /api/refresh", apiCfg.handlerRefreshToken())
-	mux.Handle("POST /api/revoke", apiCfg.handlerRevokeToken())
+	mux.Handle("POST /api/refresh", cfg.handlerRefreshToken())
+	mux.Handle("POST /api/revoke", cfg.handlerRevokeToken())
 
-	// ListenAndServe starts a server with an address and a handler
-	err = server.ListenAndServe()
-	if err != nil {
-		log.Fatal("error listening on server: %w", err)
+	return middlewareRequestID(middlewareTracing(middlewareAccessLog(cfg.middlewareMetrics(mux))))
+}
+
+// newLinkPreviewFetcher returns the fetcher of link previews, nil when
+// they are disabled
+func newLinkPreviewFetcher(cfg config.LinkPreviews) linkpreview.Fetcher {
+	if !cfg.Enabled {
+		return nil
 	}
+
+	return linkpreview.NewHTTPFetcher(linkpreview.Options{
+		Timeout:      cfg.Timeout,
+		MaxBytes:     cfg.MaxBytes,
+		MaxRedirects: cfg.MaxRedirects,
+	})
 }
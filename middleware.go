@@ -1,62 +1,271 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luis-octavius/chirpy/internal/apierror"
+	"github.com/luis-octavius/chirpy/internal/auth"
+	"github.com/luis-octavius/chirpy/internal/logging"
+	"github.com/luis-octavius/chirpy/internal/store"
+	"github.com/luis-octavius/chirpy/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// middlewareMetricsInc is a wrapper that adds one to the count of
-// the times that /app endpoint has been hit
-func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
+// requestIDHeader carries the request ID between clients, proxies
+// and Chirpy
+const requestIDHeader = "X-Request-ID"
+
+// traceIDHeader exposes the trace ID of the request to clients, so a
+// bug report can be matched with its trace
+const traceIDHeader = "X-Trace-ID"
+
+// codeAdminDisabled is returned by admin endpoints outside of the dev
+// platform or without an ADMIN_TOKEN
+const codeAdminDisabled = "admin_disabled"
+
+// middlewareAdmin only lets requests through on the dev platform and
+// with the ADMIN_TOKEN as Bearer token, admin endpoints are disabled
+// when no token is configured
+func (cfg *apiConfig) middlewareAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		if !cfg.config.IsDev() || cfg.config.AdminToken == "" {
+			apierror.Write(w, r, apierror.Forbidden(codeAdminDisabled, "admin endpoints are disabled"))
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			apierror.Write(w, r, apierror.Unauthorized(apierror.CodeMissingToken, "missing Bearer token").Wrap(err))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.config.AdminToken)) != 1 {
+			apierror.Write(w, r, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid admin token"))
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-// handlerMetrics returns html code to render how many times
-// /app endpoint has been hit
-func (cfg *apiConfig) handlerMetrics() http.Handler {
+// authenticate returns the user of the access token sent as Bearer
+// token and records it for the access log
+//
+// Returns a 401 missing_token or invalid_token error otherwise
+func (cfg *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, apierror.Unauthorized(apierror.CodeMissingToken, "missing Bearer token").Wrap(err)
+	}
+
+	userID, err := cfg.keys.ValidateJWT(token)
+	if err != nil {
+		return uuid.Nil, apierror.Unauthorized(apierror.CodeInvalidToken, "invalid or expired access token").Wrap(err)
+	}
+	logging.SetUserID(r.Context(), userID)
+
+	return userID, nil
+}
+
+// codeNotModerator is returned by moderation endpoints to users without
+// the moderator role
+const codeNotModerator = "not_moderator"
+
+// authenticateModerator returns the moderator of the access token, the
+// role is read from the store so revoking it takes effect at once
+//
+// Returns a 401 error without a valid JWT, and a 403 not_moderator
+// error for users that are not moderators, suspended or deleted
+func (cfg *apiConfig) authenticateModerator(r *http.Request) (uuid.UUID, error) {
+	userID, err := cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return uuid.Nil, apierror.Internal(err)
+	}
+	if err != nil || !user.IsModerator || user.Suspended() || user.Deleted() {
+		return uuid.Nil, apierror.Forbidden(codeNotModerator, "moderation requires the moderator role")
+	}
+
+	return userID, nil
+}
+
+// viewer returns the user reading public data, uuid.Nil for anonymous
+// requests, so blocks and mutes apply to signed in users
+//
+// Returns a 401 invalid_token error if the request has an invalid JWT
+func (cfg *apiConfig) viewer(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	return cfg.authenticate(r)
+}
+
+// middlewareMetrics records the count, latency and status code of
+// every request served by next, labeled by the matched route pattern.
+// A request that panics leaves the in-flight gauge but is not counted,
+// it has no status code.
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 
-		html := fmt.Sprintf(`<html>
-  <body>
-    <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p>
-  </body>
-</html>`, cfg.fileserverHits.Load())
+		cfg.metrics.RequestStarted()
+		defer cfg.metrics.RequestDone()
+		next.ServeHTTP(rec, r)
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(html))
+		cfg.metrics.RequestFinished(r.Method, routePattern(r), rec.status, time.Since(start))
 	})
 }
 
-// handlerReset reset the count of times that app endpoint has been hit
-// it also delete all users from database based on the platform that are
-// developing the app
-func (cfg *apiConfig) handlerReset() http.Handler {
+// middlewareRequestID makes sure every request carries an ID, taken
+// from the X-Request-ID header when the client sends a valid one or
+// generated otherwise, and echoes it back in the response
+func middlewareRequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Swap(0)
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
 
-		if cfg.platform != "dev" {
-			w.WriteHeader(http.StatusForbidden)
-			return
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// validRequestID accepts IDs up to 128 printable ASCII characters, so a
+// client cannot inject arbitrary content into our logs and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
 		}
+	}
 
-		err := cfg.queries.DeleteAllUsers(r.Context())
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	return true
+}
+
+// middlewareTracing starts a server span for every request, continuing
+// the trace propagated by the client when there is one, and names it
+// after the matched route once the mux has served the request
+func middlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			w.Header().Set(traceIDHeader, sc.TraceID().String())
 		}
 
-		w.WriteHeader(http.StatusOK)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(rec, r)
+
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(rec.status),
+		)
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
 	})
 }
 
+// middlewareAccessLog writes one structured log line per request with
+// its method, route, status, latency and authenticated user
+func middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.LogAttrs(r.Context(), level, "request completed",
+			slog.String("method", r.Method),
+			slog.String("route", routePattern(r)),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+		)
+	})
+}
+
+// routePattern returns the path of the ServeMux pattern that matched r,
+// so metrics are labeled by route rather than by raw URL
+//
+// the pattern is only known after the mux has served the request
+func routePattern(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+
+	_, path, found := strings.Cut(r.Pattern, " ")
+	if !found {
+		return r.Pattern
+	}
+
+	return path
+}
+
+// statusRecorder is a ResponseWriter that remembers the status code
+// written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 // writeJSON is a helper function that marshals data
 // and write the same data into the ResponseWriter
 //
@@ -65,9 +274,10 @@ func (cfg *apiConfig) handlerReset() http.Handler {
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	data, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
+		slog.Error("error marshaling JSON", "err", err)
+		w.Header().Set("Content-Type", apierror.ContentType)
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		w.Write([]byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"code":"internal_error"}`))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
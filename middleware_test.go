@@ -1,7 +1,14 @@
 package main
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/metrics"
 )
 
 func TestValidateMessage(t *testing.T) {
@@ -35,3 +42,113 @@ func TestValidateMessage(t *testing.T) {
 		}
 	}
 }
+
+func TestMiddlewareMetrics(t *testing.T) {
+	cfg := apiConfig{metrics: metrics.New(nil)}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/chirps/{chirpID}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+
+	server := httptest.NewServer(cfg.middlewareMetrics(mux))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/chirps/123")
+	if err != nil {
+		t.Fatalf("GET chirp returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	// methods are client input, unknown ones share a single label value
+	req, err := http.NewRequest("HEISENBERG", server.URL+"/api/chirps/123", nil)
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("HEISENBERG chirp returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading metrics body returned error: %v", err)
+	}
+
+	for _, expected := range []string{
+		`chirpy_http_requests_total{code="404",method="GET",route="/api/chirps/{chirpID}"} 1`,
+		`method="other"`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected metrics to contain %q", expected)
+		}
+	}
+	if strings.Contains(string(body), "HEISENBERG") {
+		t.Error("expected the unknown method not to be a label value")
+	}
+}
+
+func TestMiddlewareMetrics_Panic(t *testing.T) {
+	cfg := apiConfig{metrics: metrics.New(nil)}
+	handler := cfg.middlewareMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to reach the server")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/chirps", nil))
+	}()
+
+	rec := httptest.NewRecorder()
+	cfg.metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "chirpy_http_requests_in_flight 0") {
+		t.Errorf("expected no request in flight after the panic, got\n%v", rec.Body.String())
+	}
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	cases := []struct {
+		name     string
+		platform string
+		token    string
+		header   string
+		expected int
+	}{
+		{name: "prod platform", platform: config.PlatformProd, token: "admin", header: "Bearer admin", expected: http.StatusForbidden},
+		{name: "no admin token configured", platform: config.PlatformDev, token: "", header: "Bearer ", expected: http.StatusForbidden},
+		{name: "missing token", platform: config.PlatformDev, token: "admin", header: "", expected: http.StatusUnauthorized},
+		{name: "wrong token", platform: config.PlatformDev, token: "admin", header: "Bearer nope", expected: http.StatusUnauthorized},
+		{name: "valid token", platform: config.PlatformDev, token: "admin", header: "Bearer admin", expected: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		cfg := apiConfig{config: config.Config{Platform: c.platform, AdminToken: c.token}}
+		handler := cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest("POST", "/admin/reset", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.expected {
+			t.Errorf("%v: expected status %v, got %v", c.name, c.expected, rec.Code)
+		}
+	}
+}
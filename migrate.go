@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/migrate"
+)
+
+// runMigrate implements `chirpy migrate up|down|status|redo` using the
+// migrations embedded in the binary
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: chirpy migrate up|down|status|redo")
+	}
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrate.Run(ctx, db, cfg.DatabaseBackend, args[0], os.Stdout)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+	"github.com/luis-octavius/chirpy/internal/store"
+)
+
+// newServer builds the HTTP server with the timeouts and size limits
+// from the configuration, so slow or oversized clients cannot hold
+// connections and memory forever
+func newServer(s config.HTTP, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              s.Addr(),
+		Handler:           http.MaxBytesHandler(handler, s.MaxBodyBytes),
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+}
+
+// serve listens on the address of the server and runs it until ctx is
+// canceled, see serveListener
+func serve(ctx context.Context, server *http.Server, shutdownTimeout time.Duration) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on server: %w", err)
+	}
+
+	return serveListener(ctx, server, ln, shutdownTimeout)
+}
+
+// serveListener runs the server on ln until ctx is canceled, then stops
+// accepting connections and waits up to shutdownTimeout for in-flight
+// requests to drain
+func serveListener(ctx context.Context, server *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", ln.Addr().String())
+		serveErr <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error listening on server: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server", "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down server: %w", err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error listening on server: %w", err)
+	}
+
+	slog.Info("server stopped")
+	return nil
+}
+
+// openDB opens the connection pool with the tuning from s and
+// pings the database until it answers, backing off exponentially
+// between attempts, so the server does not start against a database
+// that is still booting
+func openDB(ctx context.Context, driver, url string, s config.DB) (*sql.DB, error) {
+	db, err := sql.Open(driver, url)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(s.ConnMaxIdleTime)
+
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 10 * time.Second
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+
+		if attempt >= s.ConnectAttempts {
+			db.Close()
+			return nil, fmt.Errorf("error connecting to database after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("database not ready, retrying", "attempt", attempt, "backoff", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// openDatabase opens the database of the configured backend
+func openDatabase(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+	if cfg.DatabaseBackend == config.BackendSQLite {
+		return openDB(ctx, store.SQLiteDriver, store.SQLiteDSN(cfg.DatabaseURL), cfg.DB)
+	}
+
+	return openDB(ctx, "postgres", cfg.DatabaseURL, cfg.DB)
+}
+
+// newStore returns the store of the configured backend
+func newStore(backend string, db *sql.DB) store.Store {
+	if backend == config.BackendSQLite {
+		return store.NewSQLite(db)
+	}
+
+	return store.NewPostgres(db)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/config"
+)
+
+func TestServe_DrainsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	server := newServer(config.Default().HTTP, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveListener(ctx, server, ln, 5*time.Second) }()
+
+	var resp *http.Response
+	respDone := make(chan struct{})
+	go func() {
+		defer close(respDone)
+		for range 50 {
+			resp, err = http.Get("http://" + ln.Addr().String())
+			if err == nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	<-started
+	cancel()
+
+	<-respDone
+	if err != nil {
+		t.Fatalf("in-flight request returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %v, got %v", http.StatusNoContent, resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("serve returned error: %v", err)
+	}
+}
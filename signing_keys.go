@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/luis-octavius/chirpy/internal/auth"
+)
+
+// accessTokenLifetime is how long a JWT access token is valid
+const accessTokenLifetime = 1 * time.Hour
+
+// signingKeyRefreshInterval is how often the server picks up keys
+// rotated by `chirpy admin keys rotate`
+const signingKeyRefreshInterval = time.Minute
+
+// signingKeyReloadInterval rate limits the reloads of the keys caused
+// by tokens signed with a key the server does not know, such as one
+// another server just picked up
+const signingKeyReloadInterval = 5 * time.Second
+
+// signingKeyReloadTimeout bounds a reload made during a request
+const signingKeyReloadTimeout = 5 * time.Second
+
+// loadSigningKeys replaces the keyring with the keys in the database,
+// a retired key is kept for one access token lifetime so the tokens it
+// signed stay valid until they expire.
+//
+// SECRET is retired by the first rotation and treated the same way.
+// A rotation retires the previous keys when it creates the new one, so
+// a key created before the cutoff is either still listed or retired by
+// a listed key also created before the cutoff: SECRET is past its
+// lifetime once a listed key is.
+//
+// The store measures the ages of the keys with the clock of the
+// database, which sets created_at and retired_at.
+func (cfg *apiConfig) loadSigningKeys(ctx context.Context) error {
+	rows, err := cfg.store.ListSigningKeys(ctx, accessTokenLifetime)
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+
+	acceptFallback := true
+	keys := make([]auth.SigningKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, auth.SigningKey{ID: row.ID, Secret: row.Secret})
+		if row.PastLifetime {
+			acceptFallback = false
+		}
+	}
+
+	cfg.keys.Replace(keys, acceptFallback)
+	return nil
+}
+
+// reloadSigningKeys loads the signing keys for a request carrying a
+// token signed by an unknown key
+func (cfg *apiConfig) reloadSigningKeys() error {
+	ctx, cancel := context.WithTimeout(context.Background(), signingKeyReloadTimeout)
+	defer cancel()
+
+	if err := cfg.loadSigningKeys(ctx); err != nil {
+		slog.ErrorContext(ctx, "error reloading signing keys", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// refreshSigningKeys reloads the signing keys every interval until ctx
+// is canceled
+func (cfg *apiConfig) refreshSigningKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			previous := cfg.keys.CurrentKeyID()
+			if err := cfg.loadSigningKeys(ctx); err != nil {
+				slog.ErrorContext(ctx, "error refreshing signing keys", "err", err)
+				continue
+			}
+
+			if current := cfg.keys.CurrentKeyID(); current != previous {
+				slog.InfoContext(ctx, "signing key rotated", "key_id", current)
+			}
+		}
+	}
+}
@@ -1,32 +1,96 @@
 -- name: CreateChirp :one 
-INSERT INTO chirps(id, created_at, updated_at, body, user_id) 
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, reply_to_id, attachments) 
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4
 )
 RETURNING *;
 
+-- chirps of accounts pending deletion are hidden until they are purged,
+-- and so are the chirps of authors blocking the viewer. The timeline
+-- also hides the authors the viewer blocked or muted. viewer_id is the
+-- nil UUID for anonymous requests, which no block or mute matches.
+-- Chirps hidden by moderation are only shown to their author. Deleted
+-- chirps are kept until they are purged, GetChirpByID returns them so
+-- they can be told apart from the ones that never existed.
+
 -- name: GetAllChirps :many 
 SELECT * FROM chirps
+WHERE user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id)::uuid)
+AND user_id NOT IN (SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = sqlc.arg(viewer_id)::uuid)
+AND user_id NOT IN (SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = sqlc.arg(viewer_id)::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid)
+AND chirps.deleted_at IS NULL
 ORDER BY created_at;
 
 
 -- name: GetChirpByID :one 
 SELECT * FROM chirps 
-WHERE id = $1;
+WHERE chirps.id = sqlc.arg(id)
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id)::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid);
 
 
 -- name: GetChirpsByUserID :many 
 SELECT * FROM chirps 
-WHERE user_id = $1; 
+WHERE user_id = sqlc.arg(user_id)
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id)::uuid)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid)
+AND chirps.deleted_at IS NULL
+ORDER BY created_at;
+
+
+-- name: CountChirpsByUserID :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND deleted_at IS NULL;
+
+
+-- name: SoftDeleteChirpByID :execrows
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = sqlc.arg(deleted_by)
+WHERE id = sqlc.arg(id)
+AND deleted_at IS NULL;
+
+-- only the author can restore a chirp, and only when the author
+-- deleted it. The cutoffs are computed from NOW() like deleted_at, so
+-- they do not depend on the clock of the server.
+
+-- name: RestoreChirpByID :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id)
+AND deleted_by = user_id
+AND deleted_at > NOW() - sqlc.arg(window_seconds)::float8 * INTERVAL '1 second'
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - sqlc.arg(retention_seconds)::float8 * INTERVAL '1 second';
 
+-- name: DeleteAllChirps :execrows
+DELETE FROM chirps;
 
--- name: DeleteChirpByID :exec 
+-- name: DeleteChirpsByUserID :execrows
 DELETE FROM chirps
-USING users 
-WHERE chirps.user_id = users.id 
-AND chirps.id = $1 
-AND users.id = $2;
+WHERE user_id = $1;
+
+-- name: SeedChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4
+)
+RETURNING *;
@@ -0,0 +1,51 @@
+-- name: CreateDraft :one
+INSERT INTO drafts(id, created_at, updated_at, user_id, body, reply_to_id, attachments)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  sqlc.arg(user_id),
+  sqlc.arg(body),
+  sqlc.arg(reply_to_id),
+  sqlc.arg(attachments)
+)
+RETURNING *;
+
+-- drafts are only ever read by their author
+
+-- name: GetDraftByID :one
+SELECT * FROM drafts
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id);
+
+-- name: GetDraftsByUserID :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = sqlc.arg(body), reply_to_id = sqlc.arg(reply_to_id), attachments = sqlc.arg(attachments), updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id);
+
+-- the caller deletes the draft in the same transaction
+
+-- name: PublishDraft :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, reply_to_id, attachments)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  sqlc.arg(body),
+  sqlc.arg(user_id),
+  sqlc.arg(reply_to_id),
+  sqlc.arg(attachments)
+)
+RETURNING *;
@@ -0,0 +1,10 @@
+-- name: CreateFollow :exec
+INSERT INTO follows(follower_id, followee_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+);
+
+-- name: DeleteAllFollows :exec
+DELETE FROM follows;
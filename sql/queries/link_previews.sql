@@ -0,0 +1,33 @@
+-- name: CreateLinkPreview :exec
+INSERT INTO link_previews(url, created_at, updated_at)
+VALUES (
+  sqlc.arg(url),
+  NOW(),
+  NOW()
+)
+ON CONFLICT (url) DO NOTHING;
+
+-- name: GetPendingLinkPreviews :many
+SELECT url FROM link_previews
+WHERE status = 'pending'
+ORDER BY created_at
+LIMIT sqlc.arg(max_count);
+
+-- name: UpdateLinkPreview :execrows
+UPDATE link_previews
+SET status = sqlc.arg(status),
+  title = sqlc.arg(title),
+  description = sqlc.arg(description),
+  image_url = sqlc.arg(image_url),
+  site_name = sqlc.arg(site_name),
+  fetched_at = NOW(),
+  updated_at = NOW()
+WHERE url = sqlc.arg(url);
+
+-- name: GetReadyLinkPreviews :many
+SELECT * FROM link_previews
+WHERE url = ANY(sqlc.arg(urls)::text[])
+AND status = 'ready';
+
+-- name: DeleteAllLinkPreviews :exec
+DELETE FROM link_previews;
@@ -0,0 +1,63 @@
+-- name: CreateReport :one
+INSERT INTO reports(id, created_at, updated_at, chirp_id, author_id, reporter_id, chirp_body, reason, details)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+)
+RETURNING *;
+
+-- name: GetReportByID :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY created_at;
+
+-- name: CountOpenReports :one
+SELECT COUNT(*) FROM reports
+WHERE chirp_id = $1
+AND status = 'open';
+
+-- a decision on a report applies to every open report of its chirp
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET status = sqlc.arg(status), resolved_at = NOW(), updated_at = NOW()
+WHERE status = 'open'
+AND (
+  reports.id = sqlc.arg(id)::uuid
+  OR reports.chirp_id IN (SELECT r.chirp_id FROM reports r WHERE r.id = sqlc.arg(id)::uuid)
+);
+
+-- name: HideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = COALESCE(hidden_at, NOW())
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions(id, created_at, moderator_id, action, report_id, chirp_id, user_id, note)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC;
@@ -0,0 +1,59 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps(id, created_at, updated_at, user_id, body, publish_at)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  sqlc.arg(user_id),
+  sqlc.arg(body),
+  sqlc.arg(publish_at)
+)
+RETURNING *;
+
+-- name: GetScheduledChirpsByUserID :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id);
+
+-- the due chirps are removed as they are claimed, SKIP LOCKED lets
+-- every replica claim a different batch, and a rolled back claim
+-- leaves them to the next run. publish_at is stored in UTC, so it is
+-- compared with the clock of the database read in UTC. The chirps of
+-- suspended or deleted authors are skipped, they wait until the author
+-- is reinstated or purged with the account.
+
+-- name: ClaimDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE id IN (
+  SELECT due.id FROM scheduled_chirps AS due
+  WHERE due.publish_at <= NOW() AT TIME ZONE 'UTC'
+  AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = due.user_id
+    AND users.suspended_at IS NULL
+    AND users.deleted_at IS NULL
+  )
+  ORDER BY due.publish_at
+  LIMIT sqlc.arg(max_chirps)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- a published chirp is created with NOW() like every other chirp, so
+-- it sorts among them
+
+-- name: PublishScheduledChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+  sqlc.arg(id),
+  NOW(),
+  NOW(),
+  sqlc.arg(body),
+  sqlc.arg(user_id)
+)
+RETURNING *;
@@ -0,0 +1,25 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys(id, secret, created_at, retired_at)
+VALUES (
+  $1,
+  $2,
+  NOW(),
+  NULL
+)
+RETURNING *;
+
+-- name: RetireSigningKeysExcept :exec
+UPDATE signing_keys
+SET retired_at = NOW()
+WHERE id <> $1
+AND retired_at IS NULL;
+
+-- the keys are measured against NOW() like retired_at and created_at,
+-- so they do not depend on the clock of the server
+
+-- name: GetValidSigningKeys :many
+SELECT *, created_at < NOW() - sqlc.arg(lifetime_seconds)::float8 * INTERVAL '1 second' AS past_lifetime
+FROM signing_keys
+WHERE retired_at IS NULL
+OR retired_at > NOW() - sqlc.arg(lifetime_seconds)::float8 * INTERVAL '1 second'
+ORDER BY created_at DESC;
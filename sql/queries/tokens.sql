@@ -21,10 +21,36 @@ INNER JOIN refresh_tokens
 ON refresh_tokens.user_id = users.id
 WHERE refresh_tokens.token = $1
 AND refresh_tokens.revoked_at IS NULL
-AND refresh_tokens.expires_at > NOW();
+AND refresh_tokens.expires_at > NOW()
+AND users.deleted_at IS NULL;
 
 -- name: RevokeRefreshToken :exec 
 UPDATE refresh_tokens 
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1; 
 
+
+-- name: RevokeAllRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUserID :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: SeedRefreshToken :exec
+INSERT INTO refresh_tokens(token, user_id, expires_at, revoked_at, created_at, updated_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  NOW(),
+  NOW()
+);
+
+-- name: DeleteAllRefreshTokens :exec
+DELETE FROM refresh_tokens;
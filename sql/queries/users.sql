@@ -16,16 +16,103 @@ DELETE FROM users;
 SELECT * FROM users 
 WHERE email = $1; 
 
--- name: UpdateUserEmailAndPass :exec 
+-- name: UpdateUserEmailAndPass :one 
 UPDATE users 
 SET hashed_password = $1, email = $2, updated_at = NOW()
-WHERE id = $3; 
+WHERE id = $3
+RETURNING *; 
+
+-- name: UpdateUserPassword :execrows
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2;
 
 -- name: GetUserByID :one 
 SELECT * FROM users 
 WHERE id = $1; 
 
--- name: UpgradeUserByID :exec 
+-- name: UpgradeUserByID :execrows 
 UPDATE users 
 SET is_chirpy_red = true 
 WHERE id = $1; 
+
+-- name: SuspendUserByID :execrows
+UPDATE users
+SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnsuspendUserByID :execrows
+UPDATE users
+SET suspended_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUserByID :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM users) AS users,
+  (SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS chirpy_red_users,
+  (SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) AS suspended_users,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NULL) AS chirps,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NOT NULL) AS deleted_chirps,
+  (SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > NOW()) AS active_refresh_tokens;
+
+-- name: SeedUser :one
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+VALUES (
+  $1,
+  $2,
+  $2,
+  $3,
+  $4,
+  $5
+)
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = $2,
+  hashed_password = $3,
+  handle = $4,
+  display_name = $5,
+  bio = $6,
+  avatar_url = $7,
+  location = $8,
+  updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE handle = $1;
+
+-- deleted_at is stored in UTC like publish_at, so it reads back as the
+-- instant of the deletion whatever the time zone of the session
+
+-- name: SoftDeleteUserByID :one
+UPDATE users
+SET deleted_at = NOW() AT TIME ZONE 'UTC', updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NULL
+RETURNING *;
+
+-- the cutoff is computed from NOW() like deleted_at, so it does not
+-- depend on the clock of the server
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() AT TIME ZONE 'UTC' - sqlc.arg(grace_seconds)::float8 * INTERVAL '1 second';
+
+-- name: RestoreUserByID :one
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: SetUserModerator :execrows
+UPDATE users
+SET is_moderator = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE signing_keys (
+  id TEXT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  retired_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE signing_keys;
@@ -0,0 +1,62 @@
+-- +goose Up
+CREATE TABLE blocks (
+  blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (blocker_id, blocked_id),
+  CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+  muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (muter_id, muted_id),
+  CHECK (muter_id <> muted_id)
+);
+
+-- a block ends the follows between the two users, and the blocked
+-- user cannot follow the blocker again
+
+-- +goose StatementBegin
+CREATE FUNCTION unfollow_blocked() RETURNS trigger AS $$
+BEGIN
+  DELETE FROM follows
+  WHERE (follower_id = NEW.blocker_id AND followee_id = NEW.blocked_id)
+  OR (follower_id = NEW.blocked_id AND followee_id = NEW.blocker_id);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER blocks_unfollow
+AFTER INSERT ON blocks
+FOR EACH ROW EXECUTE FUNCTION unfollow_blocked();
+
+-- +goose StatementBegin
+CREATE FUNCTION reject_blocked_follow() RETURNS trigger AS $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = NEW.followee_id AND blocked_id = NEW.follower_id
+  ) THEN
+    RAISE EXCEPTION 'the followee blocked the follower' USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_reject_blocked
+BEFORE INSERT ON follows
+FOR EACH ROW EXECUTE FUNCTION reject_blocked_follow();
+
+-- +goose Down
+DROP TRIGGER follows_reject_blocked ON follows;
+DROP FUNCTION reject_blocked_follow();
+DROP TRIGGER blocks_unfollow ON blocks;
+DROP FUNCTION unfollow_blocked();
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,59 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_moderator BOOLEAN NOT NULL DEFAULT false;
+
+-- hidden chirps are only shown to their author
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+-- a report keeps the body and author of the chirp it was filed
+-- against, so it can be reviewed after the chirp is edited or deleted
+CREATE TABLE reports (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_body TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  details TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'open',
+  resolved_at TIMESTAMP,
+  UNIQUE (chirp_id, reporter_id),
+  CHECK (status IN ('open', 'actioned', 'dismissed'))
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+-- the audit log of moderation references nothing, so its entries
+-- outlive the reports, chirps and users they are about, and it
+-- rejects every UPDATE and DELETE
+CREATE TABLE moderation_actions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  moderator_id UUID,
+  action TEXT NOT NULL,
+  report_id UUID,
+  chirp_id UUID,
+  user_id UUID,
+  note TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION reject_moderation_action_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'moderation actions cannot be changed' USING ERRCODE = 'restrict_violation';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER moderation_actions_immutable
+BEFORE UPDATE OR DELETE ON moderation_actions
+FOR EACH ROW EXECUTE FUNCTION reject_moderation_action_change();
+
+-- +goose Down
+DROP TRIGGER moderation_actions_immutable ON moderation_actions;
+DROP FUNCTION reject_moderation_action_change();
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN is_moderator;
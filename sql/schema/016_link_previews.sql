@@ -0,0 +1,20 @@
+-- +goose Up
+-- a preview is shared by every chirp linking to its URL, it is
+-- fetched once in the background and failed fetches are not retried
+CREATE TABLE link_previews (
+  url TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  title TEXT NOT NULL DEFAULT '',
+  description TEXT NOT NULL DEFAULT '',
+  image_url TEXT NOT NULL DEFAULT '',
+  site_name TEXT NOT NULL DEFAULT '',
+  fetched_at TIMESTAMP,
+  CHECK (status IN ('pending', 'ready', 'failed'))
+);
+
+CREATE INDEX link_previews_status_idx ON link_previews (status, created_at);
+
+-- +goose Down
+DROP TABLE link_previews;
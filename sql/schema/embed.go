@@ -0,0 +1,10 @@
+// Package schema embeds the goose migrations of the Postgres schema,
+// so the server binary can migrate the database by itself
+package schema
+
+import "embed"
+
+// FS holds every migration in this directory
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,39 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks(blocker_id, blocked_id, created_at)
+VALUES (
+  sqlc.arg(blocker_id),
+  sqlc.arg(blocked_id),
+  sqlc.arg(now)
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :exec
+DELETE FROM blocks
+WHERE blocker_id = sqlc.arg(blocker_id)
+AND blocked_id = sqlc.arg(blocked_id);
+
+-- name: GetBlockedUsers :many
+SELECT users.* FROM blocks
+JOIN users ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = ?
+ORDER BY blocks.created_at;
+
+-- name: CreateMute :exec
+INSERT INTO mutes(muter_id, muted_id, created_at)
+VALUES (
+  sqlc.arg(muter_id),
+  sqlc.arg(muted_id),
+  sqlc.arg(now)
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMute :exec
+DELETE FROM mutes
+WHERE muter_id = sqlc.arg(muter_id)
+AND muted_id = sqlc.arg(muted_id);
+
+-- name: GetMutedUsers :many
+SELECT users.* FROM mutes
+JOIN users ON users.id = mutes.muted_id
+WHERE mutes.muter_id = ?
+ORDER BY mutes.created_at;
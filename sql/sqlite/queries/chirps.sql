@@ -0,0 +1,90 @@
+-- name: CreateChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, reply_to_id, attachments)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(body),
+  sqlc.arg(user_id),
+  sqlc.arg(reply_to_id),
+  sqlc.arg(attachments)
+)
+RETURNING *;
+
+-- chirps of accounts pending deletion are hidden until they are purged,
+-- and so are the chirps of authors blocking the viewer. The timeline
+-- also hides the authors the viewer blocked or muted. viewer_id is the
+-- nil UUID for anonymous requests, which no block or mute matches.
+-- Chirps hidden by moderation are only shown to their author. Deleted
+-- chirps are kept until they are purged, GetChirpByID returns them so
+-- they can be told apart from the ones that never existed.
+
+-- name: GetAllChirps :many
+SELECT * FROM chirps
+WHERE user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id))
+AND user_id NOT IN (SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = sqlc.arg(viewer_id))
+AND user_id NOT IN (SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = sqlc.arg(viewer_id))
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id))
+AND chirps.deleted_at IS NULL
+ORDER BY created_at;
+
+-- name: GetChirpByID :one
+SELECT * FROM chirps
+WHERE chirps.id = sqlc.arg(id)
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id))
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id));
+
+-- name: GetChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+AND user_id IN (SELECT users.id FROM users WHERE users.deleted_at IS NULL)
+AND user_id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg(viewer_id))
+AND (chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id))
+AND chirps.deleted_at IS NULL
+ORDER BY created_at;
+
+-- name: CountChirpsByUserID :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+AND deleted_at IS NULL;
+
+-- name: SoftDeleteChirpByID :execrows
+UPDATE chirps
+SET deleted_at = sqlc.arg(now), deleted_by = sqlc.arg(deleted_by)
+WHERE id = sqlc.arg(id)
+AND deleted_at IS NULL;
+
+-- only the author can restore a chirp, and only when the author
+-- deleted it
+
+-- name: RestoreChirpByID :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id)
+AND deleted_by = user_id
+AND deleted_at > sqlc.arg(deleted_after)
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < sqlc.arg(deleted_before);
+
+-- name: DeleteAllChirps :execrows
+DELETE FROM chirps;
+
+-- name: DeleteChirpsByUserID :execrows
+DELETE FROM chirps
+WHERE user_id = ?;
+
+-- name: SeedChirp :exec
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(created_at),
+  sqlc.arg(created_at),
+  sqlc.arg(body),
+  sqlc.arg(user_id)
+);
@@ -0,0 +1,10 @@
+-- name: CreateFollow :exec
+INSERT INTO follows(follower_id, followee_id, created_at)
+VALUES (
+  sqlc.arg(follower_id),
+  sqlc.arg(followee_id),
+  sqlc.arg(now)
+);
+
+-- name: DeleteAllFollows :exec
+DELETE FROM follows;
@@ -0,0 +1,63 @@
+-- name: CreateReport :one
+INSERT INTO reports(id, created_at, updated_at, chirp_id, author_id, reporter_id, chirp_body, reason, details)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(chirp_id),
+  sqlc.arg(author_id),
+  sqlc.arg(reporter_id),
+  sqlc.arg(chirp_body),
+  sqlc.arg(reason),
+  sqlc.arg(details)
+)
+RETURNING *;
+
+-- name: GetReportByID :one
+SELECT * FROM reports
+WHERE id = ?;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports
+WHERE status = ?
+ORDER BY created_at;
+
+-- name: CountOpenReports :one
+SELECT COUNT(*) FROM reports
+WHERE chirp_id = ?
+AND status = 'open';
+
+-- a decision on a report applies to every open report of its chirp
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET status = sqlc.arg(status), resolved_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE status = 'open'
+AND (
+  reports.id = sqlc.arg(id)
+  OR reports.chirp_id IN (SELECT r.chirp_id FROM reports r WHERE r.id = sqlc.arg(id))
+);
+
+-- name: HideChirpByID :execrows
+UPDATE chirps
+SET hidden_at = COALESCE(hidden_at, sqlc.arg(now))
+WHERE id = sqlc.arg(id)
+AND deleted_at IS NULL;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions(id, created_at, moderator_id, action, report_id, chirp_id, user_id, note)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(moderator_id),
+  sqlc.arg(action),
+  sqlc.arg(report_id),
+  sqlc.arg(chirp_id),
+  sqlc.arg(user_id),
+  sqlc.arg(note)
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC;
@@ -0,0 +1,56 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps(id, created_at, updated_at, user_id, body, publish_at)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(user_id),
+  sqlc.arg(body),
+  sqlc.arg(publish_at)
+)
+RETURNING *;
+
+-- name: GetScheduledChirpsByUserID :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = ?
+ORDER BY publish_at;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id);
+
+-- the due chirps are removed as they are claimed, SQLite has a single
+-- writer so the delete is enough to keep other processes from claiming
+-- them, and a rolled back claim leaves them to the next run. The chirps
+-- of suspended or deleted authors are skipped.
+
+-- name: ClaimDueScheduledChirps :many
+DELETE FROM scheduled_chirps
+WHERE id IN (
+  SELECT due.id FROM scheduled_chirps AS due
+  WHERE due.publish_at <= sqlc.arg(due_before)
+  AND EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = due.user_id
+    AND users.suspended_at IS NULL
+    AND users.deleted_at IS NULL
+  )
+  ORDER BY due.publish_at
+  LIMIT sqlc.arg(max_chirps)
+)
+RETURNING *;
+
+-- a published chirp is created now like every other chirp, so it sorts
+-- among them
+
+-- name: PublishScheduledChirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(body),
+  sqlc.arg(user_id)
+)
+RETURNING *;
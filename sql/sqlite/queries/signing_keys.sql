@@ -0,0 +1,21 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys(id, secret, created_at, retired_at)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(secret),
+  sqlc.arg(now),
+  NULL
+)
+RETURNING *;
+
+-- name: RetireSigningKeysExcept :exec
+UPDATE signing_keys
+SET retired_at = sqlc.arg(now)
+WHERE id <> sqlc.arg(id)
+AND retired_at IS NULL;
+
+-- name: GetValidSigningKeys :many
+SELECT * FROM signing_keys
+WHERE retired_at IS NULL
+OR retired_at > sqlc.arg(retired_after)
+ORDER BY created_at DESC;
@@ -0,0 +1,50 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens(token, user_id, expires_at, revoked_at, created_at, updated_at)
+VALUES (
+  sqlc.arg(token),
+  sqlc.arg(user_id),
+  sqlc.arg(expires_at),
+  NULL,
+  sqlc.arg(now),
+  sqlc.arg(now)
+)
+RETURNING *;
+
+-- name: GetUserByRefreshToken :one
+SELECT users.* FROM users
+INNER JOIN refresh_tokens
+ON refresh_tokens.user_id = users.id
+WHERE refresh_tokens.token = sqlc.arg(token)
+AND refresh_tokens.revoked_at IS NULL
+AND refresh_tokens.expires_at > sqlc.arg(now)
+AND users.deleted_at IS NULL;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token);
+
+-- name: RevokeAllRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUserID :many
+SELECT * FROM refresh_tokens
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: SeedRefreshToken :exec
+INSERT INTO refresh_tokens(token, user_id, expires_at, revoked_at, created_at, updated_at)
+VALUES (
+  sqlc.arg(token),
+  sqlc.arg(user_id),
+  sqlc.arg(expires_at),
+  sqlc.arg(revoked_at),
+  sqlc.arg(now),
+  sqlc.arg(now)
+);
+
+-- name: DeleteAllRefreshTokens :exec
+DELETE FROM refresh_tokens;
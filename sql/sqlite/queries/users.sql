@@ -0,0 +1,111 @@
+-- name: CreateUser :one
+INSERT INTO users(id, created_at, updated_at, email, hashed_password)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(now),
+  sqlc.arg(now),
+  sqlc.arg(email),
+  sqlc.arg(hashed_password)
+)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = ?;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: UpdateUserEmailAndPass :one
+UPDATE users
+SET hashed_password = sqlc.arg(hashed_password), email = sqlc.arg(email), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateUserPassword :execrows
+UPDATE users
+SET hashed_password = sqlc.arg(hashed_password), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: UpgradeUserByID :execrows
+UPDATE users
+SET is_chirpy_red = true, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteUserByID :execrows
+DELETE FROM users
+WHERE id = ?;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = sqlc.arg(email),
+  hashed_password = sqlc.arg(hashed_password),
+  handle = sqlc.arg(handle),
+  display_name = sqlc.arg(display_name),
+  bio = sqlc.arg(bio),
+  avatar_url = sqlc.arg(avatar_url),
+  location = sqlc.arg(location),
+  updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE handle = ?;
+
+-- name: SoftDeleteUserByID :one
+UPDATE users
+SET deleted_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+AND deleted_at IS NULL
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < sqlc.arg(deleted_before);
+
+-- name: RestoreUserByID :one
+UPDATE users
+SET deleted_at = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: SuspendUserByID :execrows
+UPDATE users
+SET suspended_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: UnsuspendUserByID :execrows
+UPDATE users
+SET suspended_at = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: SetUserModerator :execrows
+UPDATE users
+SET is_moderator = sqlc.arg(is_moderator), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: GetStats :one
+SELECT
+  (SELECT COUNT(*) FROM users) AS users,
+  (SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS chirpy_red_users,
+  (SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL) AS suspended_users,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NULL) AS chirps,
+  (SELECT COUNT(*) FROM chirps WHERE deleted_at IS NOT NULL) AS deleted_chirps,
+  (SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > sqlc.arg(now)) AS active_refresh_tokens;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: SeedUser :exec
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, is_chirpy_red)
+VALUES (
+  sqlc.arg(id),
+  sqlc.arg(created_at),
+  sqlc.arg(created_at),
+  sqlc.arg(email),
+  sqlc.arg(hashed_password),
+  sqlc.arg(is_chirpy_red)
+);
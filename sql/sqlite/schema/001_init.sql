@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE users (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  email TEXT NOT NULL UNIQUE,
+  hashed_password TEXT NOT NULL DEFAULT 'unset',
+  is_chirpy_red BOOLEAN NOT NULL DEFAULT false,
+  suspended_at TIMESTAMP
+);
+
+CREATE TABLE chirps (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  body TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE refresh_tokens (
+  token TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP
+);
+
+CREATE TABLE signing_keys (
+  id TEXT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  retired_at TIMESTAMP
+);
+
+CREATE TABLE follows (
+  follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (follower_id, followee_id),
+  CHECK (follower_id <> followee_id)
+);
+
+-- +goose Down
+DROP TABLE follows;
+DROP TABLE signing_keys;
+DROP TABLE refresh_tokens;
+DROP TABLE chirps;
+DROP TABLE users;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT;
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+-- SQLite cannot add a UNIQUE column
+CREATE UNIQUE INDEX users_handle_key ON users (handle);
+
+-- +goose Down
+DROP INDEX users_handle_key;
+
+ALTER TABLE users DROP COLUMN handle;
+ALTER TABLE users DROP COLUMN display_name;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN location;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- a chirp may reply to another chirp and attach up to four media URLs,
+-- drafts hold both until they are published. SQLite has no arrays, the
+-- attachments are their URLs separated by newlines, which URLs cannot
+-- hold.
+ALTER TABLE chirps ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD COLUMN attachments TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE drafts ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE drafts ADD COLUMN attachments TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id) WHERE reply_to_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_reply_to_id_idx;
+
+ALTER TABLE drafts DROP COLUMN attachments;
+ALTER TABLE drafts DROP COLUMN reply_to_id;
+
+ALTER TABLE chirps DROP COLUMN attachments;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
@@ -0,0 +1,13 @@
+// Package schema embeds the goose migrations of the SQLite schema.
+//
+// SQLite has no UUID or timestamp functions, so every ID and time is
+// generated by the application and the columns only declare UUID and
+// TIMESTAMP for sqlc and the driver to map them to Go types.
+package schema
+
+import "embed"
+
+// FS holds every migration in this directory
+//
+//go:embed *.sql
+var FS embed.FS